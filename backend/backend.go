@@ -1,8 +1,290 @@
+// Package backend runs the stack of an app on the developer machine. A Runtime drives
+// one container engine, Services translates a stack to the containers every Runtime runs.
 package backend
 
-import "github.com/cnupp/appssdk/api"
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
+	"github.com/cnupp/appssdk/api"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// RuntimeService is the service the code of the app runs in, started from the build
+// image of the buildable service of the stack.
+const RuntimeService = "runtime"
+
+// CodebasePath is where the app directory is mounted in the runtime service.
+const CodebasePath = "/codebase"
+
+// Project is the stack of an app run locally.
+type Project struct {
+	// Name namespaces the containers, it is the name of the app.
+	Name  string
+	Stack api.Stack
+	// Dir is the app directory, mounted in the runtime service. Relative host paths of
+	// volumes are resolved in its .local directory.
+	Dir string
+}
+
+// LocalDir is the directory holding the files generated for project.
+func (p Project) LocalDir() string {
+	return filepath.Join(p.Dir, ".local")
+}
+
+// Service is a container of a project.
+type Service struct {
+	Name       string
+	Image      string
+	Entrypoint []string
+	Command    []string
+	// Volumes are written "host:container[:mode]", or "container" for anonymous ones.
+	Volumes []string
+	Links   []string
+	Env     map[string]string
+	Ports   []int
+}
+
+// ServiceStatus is the state of the container of a service.
+type ServiceStatus struct {
+	Service   string `json:"service"`
+	Container string `json:"container"`
+	// State is the state reported by the engine, e.g. running or exited, "" when the
+	// container does not exist.
+	State string `json:"state"`
+	// Ports maps the exposed ports of the container to the ports published on the host.
+	Ports map[int]int `json:"ports"`
+}
+
+// Running tells whether the container of the service is running.
+func (s ServiceStatus) Running() bool {
+	return s.State == "running"
+}
+
+// Runtime runs projects with a container engine.
 type Runtime interface {
-	Up()
-	ToComposeFile(api.Stack) string
+	// Up creates the containers of project that do not exist yet and starts them all.
+	Up(project Project) error
+	// Down stops the containers of project.
+	Down(project Project) error
+	// Destroy removes the containers of project, with their volumes, network and images.
+	Destroy(project Project) error
+	// Status returns the state of every service of project, sorted by service name.
+	Status(project Project) ([]ServiceStatus, error)
+	// Exec runs command in the container of service with streams attached, and returns
+	// its exit code.
+	Exec(project Project, service string, command []string, streams Streams) (int, error)
+	// Run runs command in a new container of service, removed once command exited, and
+	// returns its exit code. command replaces the entrypoint and the command of service,
+	// the container gets its environment and volumes, and reaches the other services.
+	Run(project Project, service string, command []string, streams Streams) (int, error)
+	// Logs prints the logs of service, of every service when empty, and keeps printing
+	// them as they come when follow is set.
+	Logs(project Project, service string, follow bool) error
+	// Env returns the variables the code of the app needs to reach the services linked to
+	// it from the host.
+	Env(project Project) (map[string]string, error)
+}
+
+// Services translates the stack of project to the containers to run, sorted by name.
+// The buildable service becomes RuntimeService, idling with the app directory mounted
+// until commands are run in it.
+func Services(project Project) []Service {
+	stackServices := project.Stack.GetServices()
+
+	var services []Service
+	for name, service := range stackServices {
+		var volumes []string
+		for _, volume := range service.GetVolumes() {
+			volumes = append(volumes, volumeSpec(project, volume))
+		}
+
+		result := Service{
+			Name:    name,
+			Image:   service.GetImage(),
+			Links:   service.GetLinks(),
+			Volumes: volumes,
+			Env:     make(map[string]string),
+			Ports:   exposes(service),
+		}
+
+		for name, value := range service.GetEnv() {
+			result.Env[name] = value
+		}
+		for name, value := range linkedEnv(stackServices, service, func(link string) string { return link }, exposedPort) {
+			result.Env[name] = value
+		}
+
+		if service.IsBuildable() {
+			result.Name = RuntimeService
+			result.Image = service.GetBuild().Name
+			result.Entrypoint = []string{"/bin/sh"}
+			result.Command = []string{"-c", "tail -f /dev/null"}
+			result.Volumes = append(result.Volumes,
+				"/var/run/docker.sock:/var/run/docker.sock",
+				fmt.Sprintf("%s:%s", project.Dir, CodebasePath))
+		}
+
+		services = append(services, result)
+	}
+
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+	return services
+}
+
+// CodebaseCommand returns the command running command from CodebasePath, as the commands
+// run in RuntimeService expect.
+func CodebaseCommand(command []string) []string {
+	return append([]string{"/bin/sh", "-c", fmt.Sprintf(`cd %s && exec "$@"`, CodebasePath), "sh"}, command...)
+}
+
+// Streams are attached to the commands run in containers. Commands do not read any input
+// when Stdin is nil, and their output is discarded when Stdout or Stderr is.
+type Streams struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// StandardStreams attaches commands to the standard streams of the cli.
+func StandardStreams() Streams {
+	return Streams{Stdin: os.Stdin, Stdout: os.Stdout, Stderr: os.Stderr}
+}
+
+// Terminal tells whether commands get a terminal, when their input is one.
+func (s Streams) Terminal() bool {
+	file, ok := s.Stdin.(*os.File)
+	return ok && terminal.IsTerminal(int(file.Fd()))
+}
+
+// StartOrder sorts services so that every service comes after the services it links to.
+func StartOrder(services []Service) []Service {
+	byName := make(map[string]Service, len(services))
+	for _, service := range services {
+		byName[service.Name] = service
+	}
+
+	var ordered []Service
+	visited := make(map[string]bool)
+	var visit func(service Service)
+	visit = func(service Service) {
+		if visited[service.Name] {
+			return
+		}
+		visited[service.Name] = true
+		for _, link := range service.Links {
+			if linked, ok := byName[link]; ok {
+				visit(linked)
+			}
+		}
+		ordered = append(ordered, service)
+	}
+	for _, service := range services {
+		visit(service)
+	}
+	return ordered
+}
+
+// HostEnv returns the variables reaching the services linked to the buildable service
+// through the ports published on the host, see Runtime.Env.
+func HostEnv(project Project, statuses []ServiceStatus) (map[string]string, error) {
+	published := make(map[string]map[int]int, len(statuses))
+	for _, status := range statuses {
+		published[status.Service] = status.Ports
+	}
+
+	stackServices := project.Stack.GetServices()
+	for _, service := range stackServices {
+		if !service.IsBuildable() {
+			continue
+		}
+
+		var missing error
+		host := publishedHost()
+		env := linkedEnv(stackServices, service, func(string) string { return host }, func(link string, linked api.Service) string {
+			hostPort, ok := published[link][firstExpose(linked)]
+			if !ok && missing == nil {
+				missing = fmt.Errorf("Cannot find the port of service %s published on the host, is it up?", link)
+			}
+			return fmt.Sprintf("%d", hostPort)
+		})
+		return env, missing
+	}
+	return map[string]string{}, nil
+}
+
+// publishedHost is the host the ports published by the container engine are reached on:
+// the host of DOCKER_HOST when the engine is remote, the local machine otherwise.
+func publishedHost() string {
+	u, err := url.Parse(os.Getenv("DOCKER_HOST"))
+	if err != nil || (u.Scheme != "tcp" && u.Scheme != "http") || u.Hostname() == "" {
+		return "localhost"
+	}
+	return u.Hostname()
+}
+
+// LinkedEnv returns the variables reaching the services service links to, each on the
+// host given by host and the first port it exposes.
+func LinkedEnv(stack api.Stack, service api.Service, host func(link string) string) map[string]string {
+	return linkedEnv(stack.GetServices(), service, host, exposedPort)
+}
+
+// linkedEnv returns the host, port and environment of the services service links to, as
+// LINK_HOST, LINK_PORT and LINK_NAME.
+func linkedEnv(services map[string]api.Service, service api.Service, host func(link string) string, port func(link string, linked api.Service) string) map[string]string {
+	env := make(map[string]string)
+	for _, link := range service.GetLinks() {
+		prefix := strings.ToUpper(link)
+		env[prefix+"_HOST"] = host(link)
+		linked, ok := services[link]
+		if !ok {
+			continue
+		}
+		env[prefix+"_PORT"] = port(link, linked)
+		for name, value := range linked.GetEnv() {
+			env[fmt.Sprintf("%s_%s", prefix, strings.ToUpper(name))] = value
+		}
+	}
+	return env
+}
+
+func exposedPort(_ string, linked api.Service) string {
+	return fmt.Sprintf("%d", firstExpose(linked))
+}
+
+func firstExpose(service api.Service) int {
+	if ports := exposes(service); len(ports) > 0 {
+		return ports[0]
+	}
+	return 0
+}
+
+func exposes(service api.Service) []int {
+	var ports []int
+	for _, port := range service.GetExpose() {
+		if port > 0 {
+			ports = append(ports, port)
+		}
+	}
+	return ports
+}
+
+func volumeSpec(project Project, volume api.Volume) string {
+	if volume.HostPath == "" {
+		return volume.ContainerPath
+	}
+
+	host := volume.HostPath
+	if !filepath.IsAbs(host) {
+		host = filepath.Join(project.LocalDir(), host)
+	}
+	if volume.Mode == "" {
+		return fmt.Sprintf("%s:%s", host, volume.ContainerPath)
+	}
+	return fmt.Sprintf("%s:%s:%s", host, volume.ContainerPath, strings.ToLower(volume.Mode))
 }
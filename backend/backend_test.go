@@ -0,0 +1,130 @@
+package backend
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/cnupp/appssdk/api"
+)
+
+func testProject() Project {
+	return Project{
+		Name: "demo",
+		Dir:  "/work/demo",
+		Stack: api.StackModel{
+			NameField: "javajersey",
+			Services: map[string]api.ServiceDefinition{
+				"main": {
+					Build: api.Image{Name: "hub.deepi.cn/jersey-mysql-build"},
+					Env:   map[string]string{"APP": "demo"},
+					Links: []string{"db"},
+				},
+				"db": {
+					Image:   "mysql",
+					Exposes: 3306,
+					Env:     map[string]string{"MYSQL_USER": "mysql"},
+					Volumes: []api.Volume{{ContainerPath: "/var/lib/mysql", HostPath: "data", Mode: "RW"}},
+				},
+			},
+		},
+	}
+}
+
+func TestServices(t *testing.T) {
+	services := Services(testProject())
+
+	expected := []Service{
+		{
+			Name:    "db",
+			Image:   "mysql",
+			Volumes: []string{"/work/demo/.local/data:/var/lib/mysql:rw"},
+			Env:     map[string]string{"MYSQL_USER": "mysql"},
+			Ports:   []int{3306},
+		},
+		{
+			Name:       RuntimeService,
+			Image:      "hub.deepi.cn/jersey-mysql-build",
+			Entrypoint: []string{"/bin/sh"},
+			Command:    []string{"-c", "tail -f /dev/null"},
+			Volumes:    []string{"/var/run/docker.sock:/var/run/docker.sock", "/work/demo:/codebase"},
+			Links:      []string{"db"},
+			Env: map[string]string{
+				"APP":           "demo",
+				"DB_HOST":       "db",
+				"DB_PORT":       "3306",
+				"DB_MYSQL_USER": "mysql",
+			},
+		},
+	}
+	if !reflect.DeepEqual(services, expected) {
+		t.Errorf("Services() = %+v, want %+v", services, expected)
+	}
+
+	var order []string
+	for _, service := range StartOrder([]Service{services[1], services[0]}) {
+		order = append(order, service.Name)
+	}
+	if !reflect.DeepEqual(order, []string{"db", RuntimeService}) {
+		t.Errorf("StartOrder() = %v, want db before %s", order, RuntimeService)
+	}
+}
+
+func TestHostEnv(t *testing.T) {
+	dockerHost := os.Getenv("DOCKER_HOST")
+	defer os.Setenv("DOCKER_HOST", dockerHost)
+
+	cases := []struct {
+		dockerHost string
+		statuses   []ServiceStatus
+		env        map[string]string
+		err        bool
+	}{
+		{
+			statuses: []ServiceStatus{{Service: "db", State: "running", Ports: map[int]int{3306: 32768}}},
+			env: map[string]string{
+				"DB_HOST":       "localhost",
+				"DB_PORT":       "32768",
+				"DB_MYSQL_USER": "mysql",
+			},
+		},
+		{
+			dockerHost: "tcp://192.168.99.100:2376",
+			statuses:   []ServiceStatus{{Service: "db", State: "running", Ports: map[int]int{3306: 32768}}},
+			env: map[string]string{
+				"DB_HOST":       "192.168.99.100",
+				"DB_PORT":       "32768",
+				"DB_MYSQL_USER": "mysql",
+			},
+		},
+		{
+			dockerHost: "unix:///var/run/docker.sock",
+			statuses:   []ServiceStatus{{Service: "db", State: "running", Ports: map[int]int{3306: 32768}}},
+			env: map[string]string{
+				"DB_HOST":       "localhost",
+				"DB_PORT":       "32768",
+				"DB_MYSQL_USER": "mysql",
+			},
+		},
+		{
+			statuses: []ServiceStatus{{Service: "db"}},
+			err:      true,
+		},
+	}
+
+	for _, c := range cases {
+		os.Setenv("DOCKER_HOST", c.dockerHost)
+		env, err := HostEnv(testProject(), c.statuses)
+		if c.err {
+			if err == nil {
+				t.Errorf("HostEnv(%+v) succeeded, want an error", c.statuses)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("HostEnv(%+v) failed: %v", c.statuses, err)
+		} else if !reflect.DeepEqual(env, c.env) {
+			t.Errorf("HostEnv(%+v) = %v, want %v", c.statuses, env, c.env)
+		}
+	}
+}
@@ -1,21 +1,30 @@
 package compose
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
-	"github.com/cnupp/cli/backend"
-	"github.com/cnupp/appssdk/api"
-	"gopkg.in/yaml.v2"
+	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/cnupp/cli/backend"
+	"gopkg.in/yaml.v2"
 )
 
+// ComposeBackend runs projects with docker-compose, from a compose file generated in the
+// .local directory of the app.
 type ComposeBackend struct {
 }
+
 type Service struct {
 	Image       string            `json:"image" yaml:"image"`
-	Entrypoint  string            `json:"entrypoint" yaml:"entrypoint,omitempty"`
-	Command     string            `json:"command" yaml:"command,omitempty"`
+	Entrypoint  []string          `json:"entrypoint" yaml:"entrypoint,omitempty"`
+	Command     []string          `json:"command" yaml:"command,omitempty"`
 	Volumes     []string          `json:"volumes" yaml:"volumes,omitempty"`
 	Links       []string          `json:"links" yaml:"links,omitempty"`
 	Ports       []string          `json:"ports" yaml:"ports,omitempty"`
@@ -28,95 +37,153 @@ type ComposeFile struct {
 	Services map[string]Service `json:"services"`
 }
 
-func (cb ComposeBackend) Up() {
+const (
+	composeFileName = "dockercompose.yml"
+	startAttempts   = 100
+)
 
+func NewComposeBackend() backend.Runtime {
+	return ComposeBackend{}
 }
 
-func toString(v api.Volume) string {
-	if "" == v.HostPath {
-		return fmt.Sprintf("%s", v.ContainerPath)
+func (cb ComposeBackend) Up(project backend.Project) error {
+	up, err := cb.compose(project, "up", "-d")
+	if err != nil {
+		return err
+	}
+	up.Stdout = os.Stdout
+	up.Stderr = os.Stderr
+	if err := up.Run(); err != nil {
+		return err
 	}
 
-	if "" == v.Mode {
-		return fmt.Sprintf("%s:%s", v.HostPath, v.ContainerPath)
+	for i := 0; i < startAttempts; i++ {
+		statuses, err := cb.Status(project)
+		if err != nil {
+			return err
+		}
+		if allRunning(statuses) {
+			return nil
+		}
+		fmt.Println("starting...")
+		time.Sleep(1 * time.Second)
 	}
+	return fmt.Errorf("The services of %s are not all running, see cde dev:status", project.Name)
+}
+
+func (cb ComposeBackend) Down(project backend.Project) error {
+	return cb.run(project, "stop")
+}
 
-	var hostpath string
-	dir, err := filepath.Abs(filepath.Dir(os.Args[0]))
+func (cb ComposeBackend) Destroy(project backend.Project) error {
+	return cb.run(project, "down", "-v", "--remove-orphans", "--rmi", "all")
+}
+
+func (cb ComposeBackend) Status(project backend.Project) ([]backend.ServiceStatus, error) {
+	ids, err := cb.containerIds(project)
 	if err != nil {
-		panic("Please ensure the current dir can be accessed")
+		return nil, err
 	}
 
-	if !filepath.IsAbs(v.HostPath) {
-		hostpath = filepath.Join(dir, ".local", v.HostPath)
+	found := make(map[string]backend.ServiceStatus)
+	if len(ids) > 0 {
+		format := `{{.Id}}	{{index .Config.Labels "com.docker.compose.service"}}	{{.State.Status}}	{{json .NetworkSettings.Ports}}`
+		out, err := exec.Command("docker", append([]string{"inspect", "--format", format}, ids...)...).Output()
+		if err != nil {
+			return nil, fmt.Errorf("Cannot inspect the containers of %s: %v", project.Name, err)
+		}
+		for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+			fields := strings.SplitN(line, "\t", 4)
+			if len(fields) != 4 {
+				continue
+			}
+			found[fields[1]] = backend.ServiceStatus{
+				Service:   fields[1],
+				Container: fields[0],
+				State:     fields[2],
+				Ports:     publishedPorts(fields[3]),
+			}
+		}
 	}
 
-	return fmt.Sprintf("%s:%s:%s", hostpath, v.ContainerPath, strings.ToLower(v.Mode))
+	var statuses []backend.ServiceStatus
+	for _, service := range backend.Services(project) {
+		status, ok := found[service.Name]
+		if !ok {
+			status = backend.ServiceStatus{Service: service.Name}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
 }
 
-func (cb ComposeBackend) ToComposeFile(s api.Stack) string {
-	services := s.GetServices()
-	composeServices := make(map[string]Service, 0)
+func (cb ComposeBackend) Exec(project backend.Project, service string, command []string, streams backend.Streams) (int, error) {
+	container, err := cb.container(project, service)
+	if err != nil {
+		return 0, err
+	}
 
-	for name, service := range services {
-		if !service.IsBuildable() {
-			volumes := make([]string, 0)
-			for _, v := range service.GetVolumes() {
-				volumes = append(volumes, toString(v))
-			}
-			env := service.GetEnv()
-			links := service.GetLinks()
-			for _, link := range links {
-				fmt.Println(link)
-				env[fmt.Sprintf("%s_HOST", strings.ToUpper(link))] = link
-				env[fmt.Sprintf("%s_PORT", strings.ToUpper(link))] = fmt.Sprintf("%d", services[link].GetExpose()[0])
-				for envName, envValue := range services[link].GetEnv() {
-					env[fmt.Sprintf("%s_%s", strings.ToUpper(link), strings.ToUpper(envName))] = envValue
-				}
-			}
+	args := []string{"exec"}
+	if streams.Stdin != nil {
+		args = append(args, "-i")
+	}
+	if streams.Terminal() {
+		args = append(args, "-t")
+	}
+	dockerExec := exec.Command("docker", append(append(args, container), command...)...)
+	dockerExec.Stdin = streams.Stdin
+	dockerExec.Stdout = streams.Stdout
+	dockerExec.Stderr = streams.Stderr
+	return exitCode(dockerExec.Run())
+}
 
-			composeServices[name] = Service{
-				Image:       service.GetImage(),
-				Links:       links,
-				Volumes:     volumes,
-				Environment: env,
-				Expose:      service.GetExpose(),
-				Ports:       Map(service.GetExpose(), func(port int) string { return fmt.Sprintf("%d", port) }),
-			}
-		} else {
-			volumes := make([]string, 0)
-			for _, v := range service.GetVolumes() {
-				volumes = append(volumes, toString(v))
-			}
+func (cb ComposeBackend) Run(project backend.Project, service string, command []string, streams backend.Streams) (int, error) {
+	args := []string{"run", "--rm", "--entrypoint", command[0]}
+	if !streams.Terminal() {
+		args = append(args, "-T")
+	}
+	run, err := cb.compose(project, append(append(args, service), command[1:]...)...)
+	if err != nil {
+		return 0, err
+	}
+	run.Stdin = streams.Stdin
+	run.Stdout = streams.Stdout
+	run.Stderr = streams.Stderr
+	return exitCode(run.Run())
+}
 
-			dir, err := filepath.Abs(filepath.Dir(os.Args[0]))
-			if err != nil {
-				panic("Please ensure the current dir can be accessed")
-			}
+func (cb ComposeBackend) Logs(project backend.Project, service string, follow bool) error {
+	args := []string{"logs"}
+	if follow {
+		args = append(args, "-f")
+	}
+	if service != "" {
+		args = append(args, service)
+	}
+	return cb.run(project, args...)
+}
 
-			volumes = append(volumes, "/var/run/docker.sock:/var/run/docker.sock")
-			volumes = append(volumes, fmt.Sprintf("%s:/codebase", dir))
-
-			env := service.GetEnv()
-			links := service.GetLinks()
-			for _, link := range links {
-				env[fmt.Sprintf("%s_HOST", strings.ToUpper(link))] = link
-				env[fmt.Sprintf("%s_PORT", strings.ToUpper(link))] = fmt.Sprintf("%d", services[link].GetExpose()[0])
-				for envName, envValue := range services[link].GetEnv() {
-					env[fmt.Sprintf("%s_%s", strings.ToUpper(link), strings.ToUpper(envName))] = envValue
-				}
-			}
+func (cb ComposeBackend) Env(project backend.Project) (map[string]string, error) {
+	statuses, err := cb.Status(project)
+	if err != nil {
+		return nil, err
+	}
+	return backend.HostEnv(project, statuses)
+}
 
-			composeServices["runtime"] = Service{
-				Image:       service.GetBuild().Name,
-				Entrypoint:  "/bin/sh",
-				Command:     "-c 'tail -f /dev/null'",
-				Volumes:     volumes,
-				Links:       links,
-				Environment: env,
-				Expose:      service.GetExpose(),
-				Ports:       Map(service.GetExpose(), func(port int) string { return fmt.Sprintf("%d", port) }),
-			}
+// ToComposeFile returns the compose file running project.
+func (cb ComposeBackend) ToComposeFile(project backend.Project) string {
+	composeServices := make(map[string]Service)
+	for _, service := range backend.Services(project) {
+		composeServices[service.Name] = Service{
+			Image:       service.Image,
+			Entrypoint:  service.Entrypoint,
+			Command:     service.Command,
+			Volumes:     service.Volumes,
+			Links:       service.Links,
+			Environment: service.Env,
+			Expose:      service.Ports,
+			Ports:       Map(service.Ports, func(port int) string { return fmt.Sprintf("%d", port) }),
 		}
 	}
 	composeFile := ComposeFile{
@@ -132,8 +199,100 @@ func (cb ComposeBackend) ToComposeFile(s api.Stack) string {
 	return string(out)
 }
 
-func NewComposeBackend() backend.Runtime {
-	return ComposeBackend{}
+// compose returns the docker-compose command running args against the compose file of
+// project, written beforehand.
+func (cb ComposeBackend) compose(project backend.Project, args ...string) (*exec.Cmd, error) {
+	if err := os.MkdirAll(project.LocalDir(), 0755); err != nil {
+		return nil, err
+	}
+	file := filepath.Join(project.LocalDir(), composeFileName)
+	if err := ioutil.WriteFile(file, []byte(cb.ToComposeFile(project)), 0644); err != nil {
+		return nil, err
+	}
+	return exec.Command("docker-compose", append([]string{"-f", file, "-p", project.Name}, args...)...), nil
+}
+
+func (cb ComposeBackend) run(project backend.Project, args ...string) error {
+	command, err := cb.compose(project, args...)
+	if err != nil {
+		return err
+	}
+	command.Stdout = os.Stdout
+	command.Stderr = os.Stderr
+	return command.Run()
+}
+
+// containerIds lists the containers of project, stopped ones included. Older releases of
+// docker-compose list them without --all and do not know the flag.
+func (cb ComposeBackend) containerIds(project backend.Project) ([]string, error) {
+	var out []byte
+	var err error
+	for _, args := range [][]string{{"ps", "-a", "-q"}, {"ps", "-q"}} {
+		var ps *exec.Cmd
+		if ps, err = cb.compose(project, args...); err != nil {
+			return nil, err
+		}
+		var stderr bytes.Buffer
+		ps.Stderr = &stderr
+		if out, err = ps.Output(); err == nil {
+			break
+		}
+		err = fmt.Errorf("Cannot list the containers of %s: %v %s", project.Name, err, strings.TrimSpace(stderr.String()))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(out)), nil
+}
+
+func (cb ComposeBackend) container(project backend.Project, service string) (string, error) {
+	statuses, err := cb.Status(project)
+	if err != nil {
+		return "", err
+	}
+	for _, status := range statuses {
+		if status.Service == service && status.Container != "" {
+			return status.Container, nil
+		}
+	}
+	return "", fmt.Errorf("Cannot find the container of service %s, is the dev env up?", service)
+}
+
+// exitCode returns the exit code of a command that ran with err.
+func exitCode(err error) (int, error) {
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return exitErr.ExitCode(), nil
+	}
+	return 0, err
+}
+
+func allRunning(statuses []backend.ServiceStatus) bool {
+	for _, status := range statuses {
+		if !status.Running() {
+			return false
+		}
+	}
+	return true
+}
+
+// publishedPorts reads the ports of docker inspect, e.g. {"8080/tcp":[{"HostPort":"32768"}]}.
+func publishedPorts(inspected string) map[int]int {
+	var bindings map[string][]struct {
+		HostPort string
+	}
+	json.Unmarshal([]byte(inspected), &bindings)
+
+	ports := make(map[int]int)
+	for exposed, hosts := range bindings {
+		port, err := strconv.Atoi(strings.Split(exposed, "/")[0])
+		if err != nil || len(hosts) == 0 {
+			continue
+		}
+		if hostPort, err := strconv.Atoi(hosts[0].HostPort); err == nil {
+			ports[port] = hostPort
+		}
+	}
+	return ports
 }
 
 func Map(src []int, mapper func(int) string) []string {
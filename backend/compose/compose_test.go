@@ -0,0 +1,215 @@
+package compose
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cnupp/appssdk/api"
+	"github.com/cnupp/cli/backend"
+	"gopkg.in/yaml.v2"
+)
+
+// fakeTool records the arguments docker and docker-compose are run with in the calls
+// file, docker-compose ps lists the containers of $FAKE_IDS, failing on -a when
+// $FAKE_NO_ALL is set, and docker inspect prints $FAKE_INSPECT.
+const fakeTool = `#!/bin/sh
+echo "$(basename "$0") $*" >> "$FAKE_CALLS"
+case "$*" in
+*" ps -a -q") [ -n "$FAKE_NO_ALL" ] && echo "unknown flag -a" >&2 && exit 1; echo "$FAKE_IDS" ;;
+*" ps -q") echo "$FAKE_IDS" ;;
+"inspect "*) printf "$FAKE_INSPECT" ;;
+esac
+`
+
+// startTools puts fake docker and docker-compose first in the PATH and returns the file
+// their calls are recorded in, with a function restoring the environment.
+func startTools(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "compose")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tool := range []string{"docker", "docker-compose"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, tool), []byte(fakeTool), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	variables := map[string]string{
+		"PATH":         dir + string(os.PathListSeparator) + os.Getenv("PATH"),
+		"FAKE_CALLS":   filepath.Join(dir, "calls"),
+		"FAKE_IDS":     "",
+		"FAKE_INSPECT": "",
+		"FAKE_NO_ALL":  "",
+	}
+	previous := make(map[string]string)
+	for name, value := range variables {
+		previous[name] = os.Getenv(name)
+		os.Setenv(name, value)
+	}
+	return variables["FAKE_CALLS"], func() {
+		for name, value := range previous {
+			os.Setenv(name, value)
+		}
+		os.RemoveAll(dir)
+	}
+}
+
+// calls returns the calls recorded since the last one, and forgets them.
+func calls(t *testing.T, file string) []string {
+	out, err := ioutil.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	os.Remove(file)
+	if len(out) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSpace(string(out)), "\n")
+}
+
+func testProject(dir string) backend.Project {
+	return backend.Project{
+		Name: "demo",
+		Dir:  dir,
+		Stack: api.StackModel{
+			Services: map[string]api.ServiceDefinition{
+				"main": {Build: api.Image{Name: "hub.deepi.cn/jersey-build"}, Links: []string{"db"}},
+				"db":   {Image: "mysql:5.7", Exposes: 3306},
+			},
+		},
+	}
+}
+
+func TestCommands(t *testing.T) {
+	file, restore := startTools(t)
+	defer restore()
+	dir, err := ioutil.TempDir("", "demo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	project := testProject(dir)
+	runtime := NewComposeBackend()
+	compose := "docker-compose -f " + filepath.Join(dir, ".local", composeFileName) + " -p demo "
+
+	tests := []struct {
+		name     string
+		run      func() error
+		expected []string
+	}{
+		{"down", func() error { return runtime.Down(project) }, []string{compose + "stop"}},
+		{"destroy", func() error { return runtime.Destroy(project) }, []string{compose + "down -v --remove-orphans --rmi all"}},
+		{"logs", func() error { return runtime.Logs(project, "", false) }, []string{compose + "logs"}},
+		{"logs of a service", func() error { return runtime.Logs(project, "db", true) }, []string{compose + "logs -f db"}},
+		{"run", func() error {
+			_, err := runtime.Run(project, "runtime", []string{"/bin/sh", "-c", "mvn test"}, backend.Streams{})
+			return err
+		}, []string{compose + "run --rm --entrypoint /bin/sh -T runtime -c mvn test"}},
+	}
+	for _, test := range tests {
+		if err := test.run(); err != nil {
+			t.Errorf("%s failed: %v", test.name, err)
+		}
+		if got := calls(t, file); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s ran %q, want %q", test.name, got, test.expected)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, ".local", composeFileName)); err != nil {
+		t.Errorf("the compose file was not written: %v", err)
+	}
+
+	os.Setenv("FAKE_IDS", "c1")
+	os.Setenv("FAKE_INSPECT", `c1\tdb\trunning\t{"3306/tcp":[{"HostIp":"0.0.0.0","HostPort":"32768"}]}\n`)
+	code, err := runtime.Exec(project, "db", []string{"mysql", "-e", "select 1"}, backend.Streams{})
+	if err != nil || code != 0 {
+		t.Errorf("Exec() = %d, %v", code, err)
+	}
+	inspect := `docker inspect --format {{.Id}}	{{index .Config.Labels "com.docker.compose.service"}}	{{.State.Status}}	{{json .NetworkSettings.Ports}} c1`
+	expected := []string{compose + "ps -a -q", inspect, "docker exec c1 mysql -e select 1"}
+	if got := calls(t, file); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Exec ran %q, want %q", got, expected)
+	}
+	if _, err := runtime.Exec(project, "runtime", []string{"ls"}, backend.Streams{}); err == nil || !strings.Contains(err.Error(), "Cannot find the container of service runtime") {
+		t.Errorf("expected the runtime without a container not to be run in, got %v", err)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	file, restore := startTools(t)
+	defer restore()
+	dir, err := ioutil.TempDir("", "demo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	project := testProject(dir)
+
+	// older releases of docker-compose do not know ps -a
+	os.Setenv("FAKE_NO_ALL", "1")
+	os.Setenv("FAKE_IDS", "c1 c2")
+	os.Setenv("FAKE_INSPECT", `c1\tdb\trunning\t{"3306/tcp":[{"HostIp":"0.0.0.0","HostPort":"32768"}]}\nc2\truntime\texited\tnull\n`)
+	statuses, err := NewComposeBackend().Status(project)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	expected := []backend.ServiceStatus{
+		{Service: "db", Container: "c1", State: "running", Ports: map[int]int{3306: 32768}},
+		{Service: "runtime", Container: "c2", State: "exited", Ports: map[int]int{}},
+	}
+	if !reflect.DeepEqual(statuses, expected) {
+		t.Errorf("Status() = %+v, want %+v", statuses, expected)
+	}
+	if got := calls(t, file); len(got) != 3 || !strings.HasSuffix(got[0], "ps -a -q") || !strings.HasSuffix(got[1], "ps -q") {
+		t.Errorf("expected ps to be retried without -a, ran %q", got)
+	}
+
+	os.Setenv("FAKE_IDS", "")
+	statuses, err = NewComposeBackend().Status(project)
+	if err != nil || len(statuses) != 2 || statuses[0].State != "" || statuses[1].Running() {
+		t.Errorf("expected the services to have no container, got %+v (%v)", statuses, err)
+	}
+}
+
+func TestToComposeFile(t *testing.T) {
+	project := testProject("/work/demo")
+	var file struct {
+		Version  string
+		Services map[string]Service
+	}
+	if err := yaml.Unmarshal([]byte(ComposeBackend{}.ToComposeFile(project)), &file); err != nil {
+		t.Fatal(err)
+	}
+
+	if file.Version != "2" || len(file.Services) != 2 {
+		t.Fatalf("unexpected compose file %+v", file)
+	}
+	db := file.Services["db"]
+	if db.Image != "mysql:5.7" || !reflect.DeepEqual(db.Expose, []int{3306}) || !reflect.DeepEqual(db.Ports, []string{"3306"}) {
+		t.Errorf("unexpected db service %+v", db)
+	}
+	runtime := file.Services[backend.RuntimeService]
+	if runtime.Image != "hub.deepi.cn/jersey-build" || !reflect.DeepEqual(runtime.Links, []string{"db"}) ||
+		!reflect.DeepEqual(runtime.Entrypoint, []string{"/bin/sh"}) {
+		t.Errorf("unexpected runtime service %+v", runtime)
+	}
+	if !contains(runtime.Volumes, "/work/demo:"+backend.CodebasePath) {
+		t.Errorf("the app directory is not mounted in the runtime: %v", runtime.Volumes)
+	}
+	if expected := map[string]string{"DB_HOST": "db", "DB_PORT": "3306"}; !reflect.DeepEqual(runtime.Environment, expected) {
+		t.Errorf("the runtime gets %v from its links, want %v", runtime.Environment, expected)
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
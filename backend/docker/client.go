@@ -0,0 +1,223 @@
+package docker
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const (
+	apiVersion  = "v1.24"
+	defaultHost = "unix:///var/run/docker.sock"
+)
+
+// Client talks to the Docker Engine API on the address of DOCKER_HOST, the local socket
+// by default.
+type Client struct {
+	http    *http.Client
+	network string
+	address string
+	base    string
+}
+
+// apiError is an error answered by the engine.
+type apiError struct {
+	status  int
+	message string
+}
+
+func (e apiError) Error() string {
+	return e.message
+}
+
+func isStatus(err error, status int) bool {
+	apiErr, ok := err.(apiError)
+	return ok && apiErr.status == status
+}
+
+func NewClient() (*Client, error) {
+	host := os.Getenv("DOCKER_HOST")
+	if host == "" {
+		host = defaultHost
+	}
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid DOCKER_HOST %s: %v", host, err)
+	}
+
+	client := &Client{base: "http://docker/" + apiVersion}
+	switch u.Scheme {
+	case "unix":
+		client.network, client.address = "unix", u.Path
+	case "tcp", "http":
+		client.network, client.address = "tcp", u.Host
+	default:
+		return nil, fmt.Errorf("DOCKER_HOST %s is not supported, use a unix:// or tcp:// address", host)
+	}
+	client.http = &http.Client{Transport: &http.Transport{Dial: client.dial}}
+	return client, nil
+}
+
+func (c *Client) dial(string, string) (net.Conn, error) {
+	conn, err := net.Dial(c.network, c.address)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot connect to the Docker daemon at %s, is it running? %v", c.address, err)
+	}
+	return conn, nil
+}
+
+func (c *Client) request(method string, path string, query url.Values, body interface{}) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	uri := c.base + path
+	if len(query) > 0 {
+		uri += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, uri, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req, nil
+}
+
+// do sends a request with body encoded as json, and decodes the response in result.
+func (c *Client) do(method string, path string, query url.Values, body interface{}, result interface{}) error {
+	res, err := c.send(method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if result == nil {
+		io.Copy(ioutil.Discard, res.Body)
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(result)
+}
+
+func (c *Client) send(method string, path string, query url.Values, body interface{}) (*http.Response, error) {
+	req, err := c.request(method, path, query, body)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= 400 {
+		defer res.Body.Close()
+		return nil, readError(res)
+	}
+	return res, nil
+}
+
+// stream waits for the end of a request answering a stream of json messages, such as
+// pulling an image, and returns the error reported in the stream.
+func (c *Client) stream(method string, path string, query url.Values) error {
+	res, err := c.send(method, path, query, nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	decoder := json.NewDecoder(res.Body)
+	for {
+		var message struct {
+			Error string `json:"error"`
+		}
+		if err := decoder.Decode(&message); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if message.Error != "" {
+			return apiError{status: http.StatusInternalServerError, message: message.Error}
+		}
+	}
+}
+
+// hijack sends a request that turns the connection into the raw streams of a process,
+// as starting an exec does.
+func (c *Client) hijack(path string, body interface{}) (net.Conn, *bufio.Reader, error) {
+	req, err := c.request("POST", path, nil, body)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "tcp")
+
+	conn, err := c.dial("", "")
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	res, err := http.ReadResponse(reader, req)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	if res.StatusCode != http.StatusSwitchingProtocols && res.StatusCode != http.StatusOK {
+		defer conn.Close()
+		return nil, nil, readError(res)
+	}
+	return conn, reader, nil
+}
+
+func readError(res *http.Response) error {
+	body, _ := ioutil.ReadAll(res.Body)
+	var message struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &message) != nil || message.Message == "" {
+		message.Message = strings.TrimSpace(string(body))
+	}
+	if message.Message == "" {
+		message.Message = res.Status
+	}
+	return apiError{status: res.StatusCode, message: message.Message}
+}
+
+// demultiplex copies the stdout and stderr frames of a process started without a
+// terminal to their destination.
+func demultiplex(reader io.Reader, stdout io.Writer, stderr io.Writer) error {
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(reader, header); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		destination := stdout
+		if header[0] == 2 {
+			destination = stderr
+		}
+		if _, err := io.CopyN(destination, reader, int64(binary.BigEndian.Uint32(header[4:]))); err != nil {
+			return err
+		}
+	}
+}
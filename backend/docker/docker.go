@@ -0,0 +1,553 @@
+// Package docker runs projects through the Docker Engine API, without docker-compose.
+package docker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cnupp/cli/backend"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// The labels finding the containers of a project and their service, or the service of
+// one-off containers.
+const (
+	projectLabel = "com.cnupp.cde.project"
+	serviceLabel = "com.cnupp.cde.service"
+	oneOffLabel  = "com.cnupp.cde.oneoff"
+)
+
+// DockerBackend creates a network per project, where every service is reachable by its
+// name, and a container per service.
+type DockerBackend struct {
+}
+
+func NewDockerBackend() backend.Runtime {
+	return DockerBackend{}
+}
+
+type container struct {
+	Id     string            `json:"Id"`
+	Labels map[string]string `json:"Labels"`
+	State  string            `json:"State"`
+	Ports  []struct {
+		PrivatePort int `json:"PrivatePort"`
+		PublicPort  int `json:"PublicPort"`
+	} `json:"Ports"`
+}
+
+func (db DockerBackend) Up(project backend.Project) error {
+	client, err := NewClient()
+	if err != nil {
+		return err
+	}
+	if err := ensureNetwork(client, project.Name); err != nil {
+		return err
+	}
+	existing, err := containers(client, project)
+	if err != nil {
+		return err
+	}
+
+	for _, service := range backend.StartOrder(backend.Services(project)) {
+		c, ok := existing[service.Name]
+		if !ok {
+			fmt.Printf("Creating %s\n", containerName(project, service.Name))
+			if err := pull(client, service.Image); err != nil {
+				return fmt.Errorf("Cannot pull the image of service %s: %v", service.Name, err)
+			}
+			if c.Id, err = create(client, containerName(project, service.Name), containerConfig(project, service)); err != nil {
+				return fmt.Errorf("Cannot create service %s: %v", service.Name, err)
+			}
+		}
+		if c.State == "running" {
+			continue
+		}
+		fmt.Printf("Starting %s\n", containerName(project, service.Name))
+		if err := client.do("POST", "/containers/"+c.Id+"/start", nil, nil, nil); err != nil {
+			return fmt.Errorf("Cannot start service %s: %v", service.Name, err)
+		}
+	}
+
+	statuses, err := db.Status(project)
+	if err != nil {
+		return err
+	}
+	for _, status := range statuses {
+		if !status.Running() {
+			return fmt.Errorf("Service %s is %s after starting", status.Service, status.State)
+		}
+	}
+	return nil
+}
+
+func (db DockerBackend) Down(project backend.Project) error {
+	client, err := NewClient()
+	if err != nil {
+		return err
+	}
+	existing, err := containers(client, project)
+	if err != nil {
+		return err
+	}
+
+	for _, service := range serviceNames(existing) {
+		if existing[service].State != "running" {
+			continue
+		}
+		fmt.Printf("Stopping %s\n", containerName(project, service))
+		if err := client.do("POST", "/containers/"+existing[service].Id+"/stop", url.Values{"t": {"10"}}, nil, nil); err != nil {
+			return fmt.Errorf("Cannot stop service %s: %v", service, err)
+		}
+	}
+	return nil
+}
+
+func (db DockerBackend) Destroy(project backend.Project) error {
+	client, err := NewClient()
+	if err != nil {
+		return err
+	}
+	existing, err := containers(client, project)
+	if err != nil {
+		return err
+	}
+
+	for _, service := range serviceNames(existing) {
+		fmt.Printf("Removing %s\n", containerName(project, service))
+		query := url.Values{"v": {"1"}, "force": {"1"}}
+		if err := client.do("DELETE", "/containers/"+existing[service].Id, query, nil, nil); err != nil && !isStatus(err, http.StatusNotFound) {
+			return fmt.Errorf("Cannot remove service %s: %v", service, err)
+		}
+	}
+
+	if err := client.do("DELETE", "/networks/"+project.Name, nil, nil, nil); err != nil && !isStatus(err, http.StatusNotFound) {
+		return fmt.Errorf("Cannot remove network %s: %v", project.Name, err)
+	}
+
+	// images still used by other containers stay, as with docker-compose down --rmi all
+	for _, service := range backend.Services(project) {
+		client.do("DELETE", "/images/"+service.Image, nil, nil, nil)
+	}
+	return nil
+}
+
+func (db DockerBackend) Status(project backend.Project) ([]backend.ServiceStatus, error) {
+	client, err := NewClient()
+	if err != nil {
+		return nil, err
+	}
+	existing, err := containers(client, project)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []backend.ServiceStatus
+	for _, service := range backend.Services(project) {
+		status := backend.ServiceStatus{Service: service.Name}
+		if c, ok := existing[service.Name]; ok {
+			status.Container = c.Id
+			status.State = c.State
+			status.Ports = make(map[int]int)
+			for _, port := range c.Ports {
+				if port.PublicPort != 0 {
+					status.Ports[port.PrivatePort] = port.PublicPort
+				}
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func (db DockerBackend) Exec(project backend.Project, service string, command []string, streams backend.Streams) (int, error) {
+	client, err := NewClient()
+	if err != nil {
+		return 0, err
+	}
+	existing, err := containers(client, project)
+	if err != nil {
+		return 0, err
+	}
+	c, ok := existing[service]
+	if !ok || c.State != "running" {
+		return 0, fmt.Errorf("Service %s is not running, is the dev env up?", service)
+	}
+
+	tty := streams.Terminal()
+	var created struct {
+		Id string `json:"Id"`
+	}
+	err = client.do("POST", "/containers/"+c.Id+"/exec", nil, map[string]interface{}{
+		"AttachStdin":  streams.Stdin != nil,
+		"AttachStdout": true,
+		"AttachStderr": true,
+		"Tty":          tty,
+		"Cmd":          command,
+	}, &created)
+	if err != nil {
+		return 0, err
+	}
+
+	conn, reader, err := client.hijack("/exec/"+created.Id+"/start", map[string]interface{}{"Detach": false, "Tty": tty})
+	if err != nil {
+		return 0, err
+	}
+	if err := attach(conn, reader, tty, streams); err != nil {
+		return 0, err
+	}
+
+	var inspected struct {
+		ExitCode int `json:"ExitCode"`
+	}
+	if err := client.do("GET", "/exec/"+created.Id+"/json", nil, nil, &inspected); err != nil {
+		return 0, err
+	}
+	return inspected.ExitCode, nil
+}
+
+func (db DockerBackend) Run(project backend.Project, service string, command []string, streams backend.Streams) (int, error) {
+	client, err := NewClient()
+	if err != nil {
+		return 0, err
+	}
+
+	var definition *backend.Service
+	for _, s := range backend.Services(project) {
+		if s.Name == service {
+			definition = &s
+			break
+		}
+	}
+	if definition == nil {
+		return 0, fmt.Errorf("The stack of %s has no service %s", project.Name, service)
+	}
+	definition.Entrypoint, definition.Command = command[:1], command[1:]
+	if err := pull(client, definition.Image); err != nil {
+		return 0, err
+	}
+
+	tty := streams.Terminal()
+	config := containerConfig(project, *definition)
+	config["Labels"] = map[string]string{projectLabel: project.Name, oneOffLabel: service}
+	config["Tty"] = tty
+	config["OpenStdin"] = streams.Stdin != nil
+	config["StdinOnce"] = streams.Stdin != nil
+	config["AttachStdin"] = streams.Stdin != nil
+	config["AttachStdout"] = true
+	config["AttachStderr"] = true
+	// one-off containers publish no ports and have no alias, they would clash with the
+	// container of the service
+	config["HostConfig"].(map[string]interface{})["PortBindings"] = nil
+	delete(config, "NetworkingConfig")
+
+	name := fmt.Sprintf("%s_%s_run_%d", project.Name, service, time.Now().UnixNano())
+	id, err := create(client, name, config)
+	if err != nil {
+		return 0, err
+	}
+	defer client.do("DELETE", "/containers/"+id, url.Values{"v": {"1"}, "force": {"1"}}, nil, nil)
+
+	conn, reader, err := client.hijack("/containers/"+id+"/attach?stream=1&stdin=1&stdout=1&stderr=1", nil)
+	if err != nil {
+		return 0, err
+	}
+	if err := client.do("POST", "/containers/"+id+"/start", nil, nil, nil); err != nil {
+		conn.Close()
+		return 0, err
+	}
+	if err := attach(conn, reader, tty, streams); err != nil {
+		return 0, err
+	}
+
+	var waited struct {
+		StatusCode int `json:"StatusCode"`
+	}
+	if err := client.do("POST", "/containers/"+id+"/wait", nil, nil, &waited); err != nil {
+		return 0, err
+	}
+	return waited.StatusCode, nil
+}
+
+func (db DockerBackend) Logs(project backend.Project, service string, follow bool) error {
+	client, err := NewClient()
+	if err != nil {
+		return err
+	}
+	existing, err := containers(client, project)
+	if err != nil {
+		return err
+	}
+
+	services := serviceNames(existing)
+	if service != "" {
+		if _, ok := existing[service]; !ok {
+			return fmt.Errorf("Cannot find the container of service %s, is the dev env up?", service)
+		}
+		services = []string{service}
+	}
+
+	width := 0
+	for _, name := range services {
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+
+	// logs of several services are told apart by a prefix, as docker-compose does
+	var output sync.Mutex
+	writer := func(name string) *prefixWriter {
+		prefix := ""
+		if len(services) > 1 {
+			prefix = fmt.Sprintf("%-*s | ", width, name)
+		}
+		return &prefixWriter{prefix: prefix, mutex: &output, out: os.Stdout}
+	}
+
+	query := url.Values{"stdout": {"1"}, "stderr": {"1"}}
+	if !follow {
+		for _, name := range services {
+			if err := logs(client, existing[name].Id, query, writer(name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	query.Set("follow", "1")
+	errs := make(chan error, len(services))
+	for _, name := range services {
+		go func(name string) {
+			errs <- logs(client, existing[name].Id, query, writer(name))
+		}(name)
+	}
+	for range services {
+		if err := <-errs; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db DockerBackend) Env(project backend.Project) (map[string]string, error) {
+	statuses, err := db.Status(project)
+	if err != nil {
+		return nil, err
+	}
+	return backend.HostEnv(project, statuses)
+}
+
+// attach copies streams to and from the hijacked connection of a process until it exits,
+// then closes the connection.
+func attach(conn net.Conn, reader io.Reader, tty bool, streams backend.Streams) error {
+	defer conn.Close()
+
+	if tty {
+		stdin := int(streams.Stdin.(*os.File).Fd())
+		state, err := terminal.MakeRaw(stdin)
+		if err == nil {
+			defer terminal.Restore(stdin, state)
+		}
+	}
+
+	if streams.Stdin != nil {
+		go func() {
+			io.Copy(conn, streams.Stdin)
+			if closer, ok := conn.(interface {
+				CloseWrite() error
+			}); ok {
+				closer.CloseWrite()
+			}
+		}()
+	}
+
+	stdout, stderr := streams.Stdout, streams.Stderr
+	if stdout == nil {
+		stdout = ioutil.Discard
+	}
+	if stderr == nil {
+		stderr = ioutil.Discard
+	}
+	if tty {
+		_, err := io.Copy(stdout, reader)
+		return err
+	}
+	return demultiplex(reader, stdout, stderr)
+}
+
+func logs(client *Client, id string, query url.Values, writer *prefixWriter) error {
+	res, err := client.send("GET", "/containers/"+id+"/logs", query, nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	defer writer.Flush()
+	return demultiplex(res.Body, writer, writer)
+}
+
+// prefixWriter writes the lines written to it to out, each starting with prefix.
+type prefixWriter struct {
+	prefix string
+	mutex  *sync.Mutex
+	out    io.Writer
+	line   []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.line = append(w.line, p...)
+	for {
+		index := bytes.IndexByte(w.line, '\n')
+		if index == -1 {
+			return len(p), nil
+		}
+		if err := w.write(w.line[:index+1]); err != nil {
+			return 0, err
+		}
+		w.line = w.line[index+1:]
+	}
+}
+
+// Flush writes the last line, when it does not end with a new line.
+func (w *prefixWriter) Flush() error {
+	if len(w.line) == 0 {
+		return nil
+	}
+	line := append(w.line, '\n')
+	w.line = nil
+	return w.write(line)
+}
+
+func (w *prefixWriter) write(line []byte) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	_, err := fmt.Fprintf(w.out, "%s%s", w.prefix, line)
+	return err
+}
+
+func containerName(project backend.Project, service string) string {
+	return fmt.Sprintf("%s_%s_1", project.Name, service)
+}
+
+// containers returns the containers of project by service.
+func containers(client *Client, project backend.Project) (map[string]container, error) {
+	filters, err := json.Marshal(map[string][]string{"label": {projectLabel + "=" + project.Name}})
+	if err != nil {
+		return nil, err
+	}
+	var list []container
+	if err := client.do("GET", "/containers/json", url.Values{"all": {"1"}, "filters": {string(filters)}}, nil, &list); err != nil {
+		return nil, err
+	}
+
+	byService := make(map[string]container, len(list))
+	for _, c := range list {
+		if service, ok := c.Labels[serviceLabel]; ok {
+			byService[service] = c
+		}
+	}
+	return byService, nil
+}
+
+func serviceNames(containers map[string]container) []string {
+	var names []string
+	for name := range containers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func ensureNetwork(client *Client, name string) error {
+	err := client.do("GET", "/networks/"+name, nil, nil, nil)
+	if !isStatus(err, http.StatusNotFound) {
+		return err
+	}
+	return client.do("POST", "/networks/create", nil, map[string]interface{}{
+		"Name":           name,
+		"CheckDuplicate": true,
+		"Labels":         map[string]string{projectLabel: name},
+	}, nil)
+}
+
+// pull fetches image unless the engine already has it.
+func pull(client *Client, image string) error {
+	err := client.do("GET", "/images/"+image+"/json", nil, nil, nil)
+	if !isStatus(err, http.StatusNotFound) {
+		return err
+	}
+
+	fmt.Printf("Pulling %s\n", image)
+	repository, tag := image, "latest"
+	if !strings.Contains(image, "@") {
+		if index := strings.LastIndex(image, ":"); index > strings.LastIndex(image, "/") {
+			repository, tag = image[:index], image[index+1:]
+		}
+	}
+	return client.stream("POST", "/images/create", url.Values{"fromImage": {repository}, "tag": {tag}})
+}
+
+// containerConfig returns the configuration of the container of service.
+func containerConfig(project backend.Project, service backend.Service) map[string]interface{} {
+	var env []string
+	for name, value := range service.Env {
+		env = append(env, name+"="+value)
+	}
+	sort.Strings(env)
+
+	exposed := make(map[string]struct{})
+	bindings := make(map[string][]map[string]string)
+	for _, port := range service.Ports {
+		key := fmt.Sprintf("%d/tcp", port)
+		exposed[key] = struct{}{}
+		bindings[key] = []map[string]string{{"HostPort": ""}}
+	}
+
+	var binds []string
+	anonymous := make(map[string]struct{})
+	for _, volume := range service.Volumes {
+		if strings.Contains(volume, ":") {
+			binds = append(binds, volume)
+		} else {
+			anonymous[volume] = struct{}{}
+		}
+	}
+
+	return map[string]interface{}{
+		"Image":        service.Image,
+		"Env":          env,
+		"Entrypoint":   service.Entrypoint,
+		"Cmd":          service.Command,
+		"ExposedPorts": exposed,
+		"Volumes":      anonymous,
+		"Labels":       map[string]string{projectLabel: project.Name, serviceLabel: service.Name},
+		"HostConfig": map[string]interface{}{
+			"Binds":        binds,
+			"PortBindings": bindings,
+			"NetworkMode":  project.Name,
+		},
+		"NetworkingConfig": map[string]interface{}{
+			"EndpointsConfig": map[string]interface{}{
+				project.Name: map[string]interface{}{"Aliases": []string{service.Name}},
+			},
+		},
+	}
+}
+
+func create(client *Client, name string, config map[string]interface{}) (string, error) {
+	var created struct {
+		Id string `json:"Id"`
+	}
+	err := client.do("POST", "/containers/create", url.Values{"name": {name}}, config, &created)
+	return created.Id, err
+}
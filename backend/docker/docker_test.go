@@ -0,0 +1,261 @@
+package docker
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cnupp/appssdk/api"
+	"github.com/cnupp/cli/backend"
+)
+
+// engine fakes the endpoints of the Docker Engine API used by DockerBackend.
+type engine struct {
+	mutex      sync.Mutex
+	networks   map[string]bool
+	images     map[string]bool
+	containers map[string]map[string]interface{}
+	requests   []string
+}
+
+func (e *engine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	path := strings.TrimPrefix(r.URL.Path, "/"+apiVersion)
+	e.requests = append(e.requests, r.Method+" "+path)
+
+	var body map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&body)
+
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case r.Method == "GET" && path == "/containers/json":
+		var list []map[string]interface{}
+		for _, container := range e.containers {
+			list = append(list, container)
+		}
+		writeJson(w, http.StatusOK, list)
+	case r.Method == "POST" && path == "/containers/create":
+		id := fmt.Sprintf("c%d", len(e.containers)+1)
+		var ports []map[string]interface{}
+		for exposed := range body["ExposedPorts"].(map[string]interface{}) {
+			var port int
+			fmt.Sscanf(exposed, "%d/tcp", &port)
+			ports = append(ports, map[string]interface{}{"PrivatePort": port, "PublicPort": 32768 + port})
+		}
+		var command []string
+		for _, key := range []string{"Entrypoint", "Cmd"} {
+			arguments, _ := body[key].([]interface{})
+			for _, argument := range arguments {
+				command = append(command, argument.(string))
+			}
+		}
+		e.containers[id] = map[string]interface{}{"Id": id, "Labels": body["Labels"], "State": "created", "Ports": ports, "Cmd": command}
+		writeJson(w, http.StatusCreated, map[string]string{"Id": id})
+	case segments[0] == "containers" && len(segments) == 3 && segments[2] == "start":
+		e.containers[segments[1]]["State"] = "running"
+		w.WriteHeader(http.StatusNoContent)
+	case segments[0] == "containers" && len(segments) == 3 && segments[2] == "stop":
+		e.containers[segments[1]]["State"] = "exited"
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == "DELETE" && segments[0] == "containers":
+		delete(e.containers, segments[1])
+		w.WriteHeader(http.StatusNoContent)
+	case segments[0] == "containers" && len(segments) == 3 && segments[2] == "logs":
+		w.Write(frame(1, e.containers[segments[1]]["Labels"].(map[string]interface{})[serviceLabel].(string)+" started\npartial"))
+	case segments[0] == "containers" && len(segments) == 3 && segments[2] == "exec":
+		writeJson(w, http.StatusCreated, map[string]string{"Id": "e1"})
+	case path == "/exec/e1/start":
+		conn, buffer, _ := w.(http.Hijacker).Hijack()
+		defer conn.Close()
+		buffer.WriteString("HTTP/1.1 101 UPGRADED\r\nContent-Type: application/vnd.docker.raw-stream\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n")
+		buffer.Write(frame(1, "out\n"))
+		buffer.Write(frame(2, "err\n"))
+		buffer.Flush()
+	case segments[0] == "containers" && len(segments) == 3 && segments[2] == "attach":
+		conn, buffer, _ := w.(http.Hijacker).Hijack()
+		defer conn.Close()
+		buffer.WriteString("HTTP/1.1 101 UPGRADED\r\nContent-Type: application/vnd.docker.raw-stream\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n")
+		buffer.Write(frame(1, strings.Join(e.containers[segments[1]]["Cmd"].([]string), " ")+"\n"))
+		buffer.Flush()
+	case segments[0] == "containers" && len(segments) == 3 && segments[2] == "wait":
+		writeJson(w, http.StatusOK, map[string]int{"StatusCode": 4})
+	case path == "/exec/e1/json":
+		writeJson(w, http.StatusOK, map[string]int{"ExitCode": 3})
+	case r.Method == "GET" && segments[0] == "networks":
+		if !e.networks[segments[1]] {
+			writeJson(w, http.StatusNotFound, map[string]string{"message": "network not found"})
+			return
+		}
+		writeJson(w, http.StatusOK, map[string]string{"Name": segments[1]})
+	case r.Method == "POST" && path == "/networks/create":
+		e.networks[body["Name"].(string)] = true
+		writeJson(w, http.StatusCreated, map[string]string{"Id": "n1"})
+	case r.Method == "DELETE" && segments[0] == "networks":
+		delete(e.networks, segments[1])
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == "GET" && segments[0] == "images":
+		image := strings.TrimSuffix(strings.TrimPrefix(path, "/images/"), "/json")
+		if !e.images[image] {
+			writeJson(w, http.StatusNotFound, map[string]string{"message": "no such image"})
+			return
+		}
+		writeJson(w, http.StatusOK, map[string]string{"Id": image})
+	case r.Method == "POST" && path == "/images/create":
+		e.images[r.URL.Query().Get("fromImage")+":"+r.URL.Query().Get("tag")] = true
+		writeJson(w, http.StatusOK, map[string]string{"status": "Downloaded"})
+	case r.Method == "DELETE" && segments[0] == "images":
+		w.WriteHeader(http.StatusOK)
+	default:
+		writeJson(w, http.StatusNotFound, map[string]string{"message": "page not found"})
+	}
+}
+
+// frame multiplexes data as the engine streams the output of processes without terminal,
+// stream is 1 for stdout and 2 for stderr.
+func frame(stream byte, data string) []byte {
+	header := make([]byte, 8)
+	header[0] = stream
+	binary.BigEndian.PutUint32(header[4:], uint32(len(data)))
+	return append(header, data...)
+}
+
+func writeJson(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func startEngine(t *testing.T) (*engine, func()) {
+	e := &engine{
+		networks:   make(map[string]bool),
+		images:     map[string]bool{"mysql:5.7": true},
+		containers: make(map[string]map[string]interface{}),
+	}
+	server := httptest.NewServer(e)
+	host := os.Getenv("DOCKER_HOST")
+	os.Setenv("DOCKER_HOST", strings.Replace(server.URL, "http://", "tcp://", 1))
+	return e, func() {
+		server.Close()
+		os.Setenv("DOCKER_HOST", host)
+	}
+}
+
+func testProject() backend.Project {
+	return backend.Project{
+		Name: "demo",
+		Dir:  "/work/demo",
+		Stack: api.StackModel{
+			Services: map[string]api.ServiceDefinition{
+				"main": {Build: api.Image{Name: "hub.deepi.cn/jersey-build"}, Links: []string{"db"}},
+				"db":   {Image: "mysql:5.7", Exposes: 3306},
+			},
+		},
+	}
+}
+
+// captureStdout returns what run writes to the standard output.
+func captureStdout(t *testing.T, run func()) string {
+	file, err := ioutil.TempFile("", "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	stdout := os.Stdout
+	os.Stdout = file
+	run()
+	os.Stdout = stdout
+	file.Close()
+	out, _ := ioutil.ReadFile(file.Name())
+	return string(out)
+}
+
+func TestLifecycle(t *testing.T) {
+	e, stop := startEngine(t)
+	defer stop()
+	runtime := NewDockerBackend()
+	project := testProject()
+
+	var err error
+	captureStdout(t, func() { err = runtime.Up(project) })
+	if err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	if !e.networks["demo"] || !e.images["hub.deepi.cn/jersey-build:latest"] {
+		t.Errorf("Up did not create the network and pull the build image: %v %v", e.networks, e.images)
+	}
+
+	statuses, err := runtime.Status(project)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	var states []string
+	for _, status := range statuses {
+		states = append(states, status.Service+" "+status.State)
+	}
+	if !reflect.DeepEqual(states, []string{"db running", "runtime running"}) {
+		t.Errorf("Status() = %v, want db and runtime running", states)
+	}
+
+	env, err := runtime.Env(project)
+	if err != nil {
+		t.Fatalf("Env failed: %v", err)
+	}
+	if env["DB_HOST"] != "127.0.0.1" || env["DB_PORT"] != "36074" {
+		t.Errorf("Env() = %v, want db on the engine host 127.0.0.1:36074", env)
+	}
+
+	out := captureStdout(t, func() { err = runtime.Logs(project, "", false) })
+	if err != nil || out != "db      | db started\ndb      | partial\nruntime | runtime started\nruntime | partial\n" {
+		t.Errorf("Logs() printed %q, %v, want the prefixed lines of every service", out, err)
+	}
+	out = captureStdout(t, func() { err = runtime.Logs(project, "db", true) })
+	if err != nil || out != "db started\npartial\n" {
+		t.Errorf("Logs(db) printed %q, %v, want the lines of db", out, err)
+	}
+
+	var code int
+	out = captureStdout(t, func() {
+		code, err = runtime.Exec(project, backend.RuntimeService, []string{"false"}, backend.StandardStreams())
+	})
+	if err != nil || code != 3 {
+		t.Errorf("Exec() = %d, %v, want exit code 3", code, err)
+	}
+	if out != "out\n" {
+		t.Errorf("Exec printed %q, want the stdout frames only", out)
+	}
+
+	out = captureStdout(t, func() {
+		code, err = runtime.Run(project, "db", []string{"mysql", "-h", "db"}, backend.StandardStreams())
+	})
+	if err != nil || code != 4 || out != "mysql -h db\n" {
+		t.Errorf("Run() = %d, %v and printed %q, want the command run with exit code 4", code, err, out)
+	}
+	if len(e.containers) != 2 {
+		t.Errorf("Run left %d containers, want the one-off container removed", len(e.containers))
+	}
+
+	captureStdout(t, func() { err = runtime.Down(project) })
+	if err != nil {
+		t.Fatalf("Down failed: %v", err)
+	}
+	if _, err := runtime.Exec(project, backend.RuntimeService, []string{"true"}, backend.Streams{}); err == nil {
+		t.Errorf("Exec succeeded once the project is down")
+	}
+
+	captureStdout(t, func() { err = runtime.Destroy(project) })
+	if err != nil {
+		t.Fatalf("Destroy failed: %v", err)
+	}
+	if len(e.containers) != 0 || len(e.networks) != 0 {
+		t.Errorf("Destroy left containers %v and networks %v", e.containers, e.networks)
+	}
+}
@@ -0,0 +1,223 @@
+package backend
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cnupp/appssdk/api"
+)
+
+// The defaults of the health checks of a stack, applied to the values left to zero. They
+// are Marathon's but for the interval, shortened to start quickly.
+const (
+	defaultGrace       = 300
+	defaultInterval    = 2
+	defaultTimeout     = 20
+	defaultMaxFailures = 3
+)
+
+// healthCheckUnit is the unit of the grace, interval and timeout of health checks.
+var healthCheckUnit = time.Second
+
+// HealthError reports the services that did not become healthy.
+type HealthError struct {
+	// Failures holds the reason of every unhealthy service, by service name.
+	Failures map[string]error
+}
+
+func (e HealthError) Error() string {
+	var reasons []string
+	for _, service := range sortedKeys(e.Failures) {
+		reasons = append(reasons, fmt.Sprintf("service %s is unhealthy: %v", service, e.Failures[service]))
+	}
+	return strings.Join(reasons, "\n")
+}
+
+// WaitHealthy runs the health checks of the services of project against the ports
+// published on the host, until every service passed them or one failed. The buildable
+// service is not checked, the code of the app does not run in it yet.
+//
+// As with Marathon, failures during the grace period of a check are ignored, and a check
+// fails after MaxConsecutiveFailures failures. A service whose container is not running
+// fails right away, its checks cannot pass anymore.
+func WaitHealthy(runtime Runtime, project Project) error {
+	statuses, err := runtime.Status(project)
+	if err != nil {
+		return err
+	}
+	published := make(map[string]map[int]int, len(statuses))
+	for _, status := range statuses {
+		published[status.Service] = status.Ports
+	}
+
+	var mutex sync.Mutex
+	var wait sync.WaitGroup
+	failures := make(map[string]error)
+	for name, service := range project.Stack.GetServices() {
+		if service.IsBuildable() || len(service.GetHealthChecks()) == 0 {
+			continue
+		}
+
+		wait.Add(1)
+		go func(name string, service api.Service) {
+			defer wait.Done()
+			fmt.Printf("Waiting for %s to be healthy...\n", name)
+			for _, check := range service.GetHealthChecks() {
+				probe, err := healthProbe(runtime, project, name, service, check, published[name])
+				if err == nil {
+					err = waitPassing(check, serviceRunning(runtime, project, name), probe)
+				}
+				if err != nil {
+					mutex.Lock()
+					failures[name] = err
+					mutex.Unlock()
+					return
+				}
+			}
+			fmt.Printf("%s is healthy\n", name)
+		}(name, service)
+	}
+	wait.Wait()
+
+	if len(failures) > 0 {
+		return HealthError{Failures: failures}
+	}
+	return nil
+}
+
+// healthProbe returns a function running check once against service.
+func healthProbe(runtime Runtime, project Project, name string, service api.Service, check api.HealthCheck, published map[int]int) (func(timeout time.Duration) error, error) {
+	protocol := strings.TrimPrefix(strings.ToUpper(check.Protocol), "MESOS_")
+	if protocol == "COMMAND" {
+		return func(time.Duration) error {
+			code, err := runtime.Exec(project, name, []string{"/bin/sh", "-c", check.Command}, Streams{})
+			if err == nil && code != 0 {
+				err = fmt.Errorf("command %q exited with %d", check.Command, code)
+			}
+			return err
+		}, nil
+	}
+
+	port := check.Port
+	if port == 0 {
+		exposed := exposes(service)
+		if check.PortIndex >= len(exposed) {
+			return nil, fmt.Errorf("%s check: no port exposed at index %d", strings.ToLower(protocol), check.PortIndex)
+		}
+		port = exposed[check.PortIndex]
+	}
+	hostPort, ok := published[port]
+	if !ok {
+		return nil, fmt.Errorf("port %d is not published on the host, is the service up?", port)
+	}
+	address := net.JoinHostPort(publishedHost(), strconv.Itoa(hostPort))
+
+	switch protocol {
+	case "TCP":
+		return func(timeout time.Duration) error {
+			conn, err := net.DialTimeout("tcp", address, timeout)
+			if err != nil {
+				return err
+			}
+			return conn.Close()
+		}, nil
+	case "", "HTTP", "HTTPS":
+		scheme := "http"
+		if protocol == "HTTPS" {
+			scheme = "https"
+		}
+		uri := fmt.Sprintf("%s://%s/%s", scheme, address, strings.TrimPrefix(check.Path, "/"))
+		return func(timeout time.Duration) error {
+			client := &http.Client{
+				Timeout: timeout,
+				// services are reached on localhost, their certificates cannot match
+				Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+			}
+			res, err := client.Get(uri)
+			if err != nil {
+				return err
+			}
+			res.Body.Close()
+			if res.StatusCode < 200 || res.StatusCode >= 400 {
+				return fmt.Errorf("GET %s answered %s", uri, res.Status)
+			}
+			return nil
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported health check protocol %s", check.Protocol)
+	}
+}
+
+// serviceRunning returns a function failing when the container of the service with name
+// is not running.
+func serviceRunning(runtime Runtime, project Project, name string) func() error {
+	return func() error {
+		statuses, err := runtime.Status(project)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			if status.Service != name || status.State == "" {
+				continue
+			}
+			if !status.Running() {
+				return fmt.Errorf("the container is %s", status.State)
+			}
+			return nil
+		}
+		return errors.New("the container does not exist")
+	}
+}
+
+func waitPassing(check api.HealthCheck, running func() error, probe func(timeout time.Duration) error) error {
+	grace := durationOrDefault(check.Grace, defaultGrace)
+	interval := durationOrDefault(check.Interval, defaultInterval)
+	timeout := durationOrDefault(check.Timeout, defaultTimeout)
+	maxFailures := check.MaxConsecutiveFailures
+	if maxFailures == 0 {
+		maxFailures = defaultMaxFailures
+	}
+
+	started := time.Now()
+	failures := 0
+	for {
+		if err := running(); err != nil {
+			return err
+		}
+		err := probe(timeout)
+		if err == nil {
+			return nil
+		}
+		if time.Since(started) >= grace {
+			failures++
+			if failures >= maxFailures {
+				return fmt.Errorf("%d consecutive failures, last one: %v", failures, err)
+			}
+		}
+		time.Sleep(interval)
+	}
+}
+
+func durationOrDefault(value int, defaultValue int) time.Duration {
+	if value == 0 {
+		value = defaultValue
+	}
+	return time.Duration(value) * healthCheckUnit
+}
+
+func sortedKeys(failures map[string]error) []string {
+	var keys []string
+	for key := range failures {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,154 @@
+package backend
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cnupp/appssdk/api"
+)
+
+// fakeRuntime publishes the ports of statuses and answers commands with code.
+type fakeRuntime struct {
+	Runtime
+	statuses []ServiceStatus
+	code     int
+	mutex    sync.Mutex
+	commands []string
+}
+
+func (r *fakeRuntime) Status(Project) ([]ServiceStatus, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]ServiceStatus(nil), r.statuses...), nil
+}
+
+func (r *fakeRuntime) Exec(project Project, service string, command []string, streams Streams) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.commands = append(r.commands, service+" "+strings.Join(command, " "))
+	return r.code, nil
+}
+
+func port(t *testing.T, address string) int {
+	_, value, _ := net.SplitHostPort(strings.TrimPrefix(address, "http://"))
+	port, err := strconv.Atoi(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return port
+}
+
+func TestWaitHealthy(t *testing.T) {
+	previous := healthCheckUnit
+	healthCheckUnit = time.Millisecond
+	defer func() { healthCheckUnit = previous }()
+
+	var mutex sync.Mutex
+	requests := 0
+	web := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if requests++; requests < 3 || r.URL.Path != "/health" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer web.Close()
+	db, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	project := Project{
+		Name: "demo",
+		Stack: api.StackModel{
+			Services: map[string]api.ServiceDefinition{
+				"main": {Build: api.Image{Name: "build"}, Health: []api.HealthCheck{{Protocol: "COMMAND", Command: "false"}}},
+				"web":  {Image: "web", Exposes: 8080, Health: []api.HealthCheck{{Protocol: "HTTP", Path: "/health", Grace: 1000}}},
+				"db":   {Image: "mysql", Exposes: 3306, Health: []api.HealthCheck{{Protocol: "TCP"}}},
+			},
+		},
+	}
+	runtime := &fakeRuntime{statuses: []ServiceStatus{
+		{Service: "web", State: "running", Ports: map[int]int{8080: port(t, web.URL)}},
+		{Service: "db", State: "running", Ports: map[int]int{3306: port(t, db.Addr().String())}},
+	}}
+
+	if err := WaitHealthy(runtime, project); err != nil {
+		t.Fatalf("WaitHealthy failed: %v", err)
+	}
+	if requests != 3 {
+		t.Errorf("web was checked %d times, want it retried until healthy", requests)
+	}
+	if len(runtime.commands) != 0 {
+		t.Errorf("the buildable service was checked with %v", runtime.commands)
+	}
+
+	project.Stack = api.StackModel{
+		Services: map[string]api.ServiceDefinition{
+			"cache": {Image: "redis", Health: []api.HealthCheck{{Protocol: "COMMAND", Command: "redis-cli ping", Grace: 1, MaxConsecutiveFailures: 2}}},
+			"db":    {Image: "mysql", Exposes: 3306, Health: []api.HealthCheck{{Protocol: "TCP"}}},
+		},
+	}
+	runtime.statuses = append(runtime.statuses, ServiceStatus{Service: "cache", State: "running"})
+	runtime.code = 1
+	err = WaitHealthy(runtime, project)
+	failures, ok := err.(HealthError)
+	if !ok || len(failures.Failures) != 1 || failures.Failures["cache"] == nil {
+		t.Fatalf("WaitHealthy() = %v, want cache reported unhealthy", err)
+	}
+	if !strings.Contains(err.Error(), `service cache is unhealthy: 2 consecutive failures, last one: command "redis-cli ping" exited with 1`) {
+		t.Errorf("unexpected report %q", err)
+	}
+}
+
+func TestWaitHealthyStopsOnExitedContainers(t *testing.T) {
+	previous := healthCheckUnit
+	healthCheckUnit = time.Millisecond
+	defer func() { healthCheckUnit = previous }()
+
+	// the grace period is far longer than the test, only the state of the containers ends it
+	check := []api.HealthCheck{{Protocol: "COMMAND", Command: "true", Grace: 60000}}
+	project := Project{
+		Name: "demo",
+		Stack: api.StackModel{
+			Services: map[string]api.ServiceDefinition{
+				"cache":  {Image: "redis", Health: check},
+				"db":     {Image: "mysql", Health: check},
+				"search": {Image: "elasticsearch", Health: check},
+			},
+		},
+	}
+	runtime := &fakeRuntime{code: 1, statuses: []ServiceStatus{
+		{Service: "cache", State: "running"},
+		{Service: "db", State: "exited"},
+	}}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		runtime.mutex.Lock()
+		runtime.statuses[0].State = "dead"
+		runtime.mutex.Unlock()
+	}()
+
+	done := make(chan error)
+	go func() { done <- WaitHealthy(runtime, project) }()
+	select {
+	case err := <-done:
+		expected := []string{
+			"service cache is unhealthy: the container is dead",
+			"service db is unhealthy: the container is exited",
+			"service search is unhealthy: the container does not exist",
+		}
+		if err == nil || err.Error() != strings.Join(expected, "\n") {
+			t.Errorf("WaitHealthy() = %v, want %q", err, expected)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("WaitHealthy kept waiting for containers that are not running")
+	}
+}
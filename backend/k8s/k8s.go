@@ -0,0 +1,223 @@
+// Package k8s translates the stack of an app to Kubernetes manifests.
+package k8s
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/cnupp/appssdk/api"
+	"github.com/cnupp/cli/backend"
+	"gopkg.in/yaml.v2"
+)
+
+type Metadata struct {
+	Name   string            `yaml:"name"`
+	Labels map[string]string `yaml:"labels,omitempty"`
+}
+
+type ConfigMap struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   Metadata          `yaml:"metadata"`
+	Data       map[string]string `yaml:"data"`
+}
+
+type Deployment struct {
+	APIVersion string         `yaml:"apiVersion"`
+	Kind       string         `yaml:"kind"`
+	Metadata   Metadata       `yaml:"metadata"`
+	Spec       DeploymentSpec `yaml:"spec"`
+}
+
+type DeploymentSpec struct {
+	Selector Selector    `yaml:"selector"`
+	Template PodTemplate `yaml:"template"`
+}
+
+type Selector struct {
+	MatchLabels map[string]string `yaml:"matchLabels"`
+}
+
+type PodTemplate struct {
+	Metadata Metadata `yaml:"metadata"`
+	Spec     PodSpec  `yaml:"spec"`
+}
+
+type PodSpec struct {
+	Containers []Container `yaml:"containers"`
+	Volumes    []Volume    `yaml:"volumes,omitempty"`
+}
+
+type Container struct {
+	Name         string          `yaml:"name"`
+	Image        string          `yaml:"image"`
+	Command      []string        `yaml:"command,omitempty"`
+	Args         []string        `yaml:"args,omitempty"`
+	Ports        []ContainerPort `yaml:"ports,omitempty"`
+	EnvFrom      []EnvFrom       `yaml:"envFrom,omitempty"`
+	VolumeMounts []VolumeMount   `yaml:"volumeMounts,omitempty"`
+}
+
+type ContainerPort struct {
+	ContainerPort int `yaml:"containerPort"`
+}
+
+type EnvFrom struct {
+	ConfigMapRef Reference `yaml:"configMapRef"`
+}
+
+type Reference struct {
+	Name string `yaml:"name"`
+}
+
+type VolumeMount struct {
+	Name      string `yaml:"name"`
+	MountPath string `yaml:"mountPath"`
+	ReadOnly  bool   `yaml:"readOnly,omitempty"`
+}
+
+type Volume struct {
+	Name     string    `yaml:"name"`
+	HostPath *HostPath `yaml:"hostPath,omitempty"`
+	EmptyDir *EmptyDir `yaml:"emptyDir,omitempty"`
+}
+
+type HostPath struct {
+	Path string `yaml:"path"`
+}
+
+type EmptyDir struct {
+}
+
+type Service struct {
+	APIVersion string      `yaml:"apiVersion"`
+	Kind       string      `yaml:"kind"`
+	Metadata   Metadata    `yaml:"metadata"`
+	Spec       ServiceSpec `yaml:"spec"`
+}
+
+type ServiceSpec struct {
+	Selector map[string]string `yaml:"selector"`
+	Ports    []ServicePort     `yaml:"ports"`
+}
+
+type ServicePort struct {
+	Name       string `yaml:"name"`
+	Port       int    `yaml:"port"`
+	TargetPort int    `yaml:"targetPort"`
+}
+
+var invalidNameCharacters = regexp.MustCompile("[^a-z0-9-]+")
+
+// ToManifests returns the manifests deploying project, the Kubernetes sibling of
+// compose.ComposeBackend.ToComposeFile. Every service of the stack gets a Deployment with
+// its environment in a ConfigMap, and a Service when it exposes ports. They are named after
+// the project and the service, and links resolve to those Services. Unlike the containers
+// of backend.Services, the buildable service runs its build image with its own command
+// and nothing of the developer machine is mounted.
+func ToManifests(project backend.Project) string {
+	stackServices := project.Stack.GetServices()
+	var names []string
+	for name := range stackServices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	objectName := func(service string) string { return Name(project.Name + "-" + service) }
+
+	var documents []string
+	for _, name := range names {
+		service := stackServices[name]
+		labels := map[string]string{"app": Name(project.Name), "service": Name(name)}
+		var manifests []interface{}
+
+		configMap := ConfigMap{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+			Metadata:   Metadata{Name: objectName(name) + "-env", Labels: labels},
+			Data:       make(map[string]string),
+		}
+		for key, value := range service.GetEnv() {
+			configMap.Data[key] = value
+		}
+		for key, value := range backend.LinkedEnv(project.Stack, service, objectName) {
+			configMap.Data[key] = value
+		}
+		manifests = append(manifests, configMap)
+
+		container := Container{
+			Name:    Name(name),
+			Image:   service.GetImage(),
+			EnvFrom: []EnvFrom{{ConfigMapRef: Reference{Name: configMap.Metadata.Name}}},
+		}
+		if service.IsBuildable() {
+			container.Image = service.GetBuild().Name
+		}
+		var volumes []Volume
+		for i, spec := range service.GetVolumes() {
+			volume, mount := toVolume(fmt.Sprintf("volume-%d", i), spec)
+			volumes = append(volumes, volume)
+			container.VolumeMounts = append(container.VolumeMounts, mount)
+		}
+		var ports []int
+		for _, port := range service.GetExpose() {
+			if port > 0 {
+				ports = append(ports, port)
+				container.Ports = append(container.Ports, ContainerPort{ContainerPort: port})
+			}
+		}
+		manifests = append(manifests, Deployment{
+			APIVersion: "apps/v1",
+			Kind:       "Deployment",
+			Metadata:   Metadata{Name: objectName(name), Labels: labels},
+			Spec: DeploymentSpec{
+				Selector: Selector{MatchLabels: labels},
+				Template: PodTemplate{
+					Metadata: Metadata{Labels: labels},
+					Spec:     PodSpec{Containers: []Container{container}, Volumes: volumes},
+				},
+			},
+		})
+
+		if len(ports) > 0 {
+			var servicePorts []ServicePort
+			for _, port := range ports {
+				servicePorts = append(servicePorts, ServicePort{Name: fmt.Sprintf("port-%d", port), Port: port, TargetPort: port})
+			}
+			manifests = append(manifests, Service{
+				APIVersion: "v1",
+				Kind:       "Service",
+				Metadata:   Metadata{Name: objectName(name), Labels: labels},
+				Spec:       ServiceSpec{Selector: labels, Ports: servicePorts},
+			})
+		}
+
+		for _, manifest := range manifests {
+			out, err := yaml.Marshal(manifest)
+			if err != nil {
+				panic(fmt.Sprintf("Error happend when translate to yaml %v", err))
+			}
+			documents = append(documents, string(out))
+		}
+	}
+	return strings.Join(documents, "---\n")
+}
+
+// Name turns name into a valid name of Kubernetes object, made of lower case
+// alphanumeric characters and dashes.
+func Name(name string) string {
+	return strings.Trim(invalidNameCharacters.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+// toVolume translates a volume of the stack to a pod volume and its mount. Absolute host
+// paths are paths of the node, the other volumes, relative ones included as they point to
+// the developer machine, are empty directories living as long as the pod.
+func toVolume(name string, spec api.Volume) (Volume, VolumeMount) {
+	mount := VolumeMount{Name: name, MountPath: spec.ContainerPath, ReadOnly: strings.EqualFold(spec.Mode, "RO")}
+	if !path.IsAbs(spec.HostPath) {
+		return Volume{Name: name, EmptyDir: &EmptyDir{}}, mount
+	}
+	return Volume{Name: name, HostPath: &HostPath{Path: spec.HostPath}}, mount
+}
@@ -0,0 +1,92 @@
+package k8s
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cnupp/appssdk/api"
+	"github.com/cnupp/cli/backend"
+	"gopkg.in/yaml.v2"
+)
+
+func TestToManifests(t *testing.T) {
+	project := backend.Project{
+		Name: "Demo_App",
+		Dir:  "/work/demo",
+		Stack: api.StackModel{
+			Services: map[string]api.ServiceDefinition{
+				"main": {Build: api.Image{Name: "jersey-build"}, Links: []string{"db"}},
+				"db": {
+					Image:   "mysql",
+					Exposes: 3306,
+					Env:     map[string]string{"MYSQL_USER": "mysql"},
+					Volumes: []api.Volume{
+						{ContainerPath: "/var/lib/mysql"},
+						{ContainerPath: "/etc/mysql", HostPath: "/etc/demo", Mode: "RO"},
+						{ContainerPath: "/backup", HostPath: "backup"},
+					},
+				},
+			},
+		},
+	}
+
+	var kinds []string
+	documents := map[string]map[interface{}]interface{}{}
+	for _, document := range strings.Split(ToManifests(project), "---\n") {
+		var manifest map[interface{}]interface{}
+		if err := yaml.Unmarshal([]byte(document), &manifest); err != nil {
+			t.Fatalf("Cannot parse manifest %s: %v", document, err)
+		}
+		name := manifest["metadata"].(map[interface{}]interface{})["name"].(string)
+		kind := manifest["kind"].(string) + " " + name
+		kinds = append(kinds, kind)
+		documents[kind] = manifest
+	}
+
+	expected := []string{
+		"ConfigMap demo-app-db-env", "Deployment demo-app-db", "Service demo-app-db",
+		"ConfigMap demo-app-main-env", "Deployment demo-app-main",
+	}
+	if !reflect.DeepEqual(kinds, expected) {
+		t.Fatalf("ToManifests() generated %v, want %v", kinds, expected)
+	}
+
+	if data := documents["ConfigMap demo-app-main-env"]["data"]; !reflect.DeepEqual(data, map[interface{}]interface{}{
+		"DB_HOST": "demo-app-db", "DB_PORT": "3306", "DB_MYSQL_USER": "mysql",
+	}) {
+		t.Errorf("the main ConfigMap holds %v, want the variables of the db link", data)
+	}
+
+	deployment := func(name string) PodSpec {
+		var parsed struct {
+			Spec DeploymentSpec `yaml:"spec"`
+		}
+		out, _ := yaml.Marshal(documents["Deployment "+name])
+		yaml.Unmarshal(out, &parsed)
+		return parsed.Spec.Template.Spec
+	}
+	if pod := deployment("demo-app-main"); !reflect.DeepEqual(pod, PodSpec{Containers: []Container{{
+		Name:    "main",
+		Image:   "jersey-build",
+		EnvFrom: []EnvFrom{{ConfigMapRef: Reference{Name: "demo-app-main-env"}}},
+	}}}) {
+		t.Errorf("the main Deployment runs %+v, want the build image alone", pod)
+	}
+
+	pod := deployment("demo-app-db")
+	if !reflect.DeepEqual(pod.Volumes, []Volume{
+		{Name: "volume-0", EmptyDir: &EmptyDir{}},
+		{Name: "volume-1", HostPath: &HostPath{Path: "/etc/demo"}},
+		{Name: "volume-2", EmptyDir: &EmptyDir{}},
+	}) {
+		t.Errorf("the db Deployment has volumes %+v", pod.Volumes)
+	}
+	if mounts := pod.Containers[0].VolumeMounts; !reflect.DeepEqual(mounts, []VolumeMount{
+		{Name: "volume-0", MountPath: "/var/lib/mysql"},
+		{Name: "volume-1", MountPath: "/etc/mysql", ReadOnly: true},
+		{Name: "volume-2", MountPath: "/backup"},
+	}) {
+		t.Errorf("the db container mounts %+v", mounts)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"github.com/docopt/docopt-go"
 	"github.com/fatih/color"
+	"github.com/cnupp/cli/cmd"
+	"github.com/cnupp/cli/config"
 	"github.com/cnupp/cli/parser"
 	"github.com/cnupp/cnup/version"
 	"gopkg.in/urfave/cli.v2"
@@ -25,6 +27,17 @@ func main() {
 			},
 		},
 		EnableShellCompletion: true,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "profile",
+				Usage: "Run the command against the named controller profile",
+			},
+			&cli.StringFlag{
+				Name:  "output",
+				Value: "table",
+				Usage: "Print list and info results as table, json or yaml",
+			},
+		},
 		Commands: []*cli.Command{
 			parser.UpsCommand(),
 			parser.StacksCommand(),
@@ -43,10 +56,27 @@ func main() {
 			parser.DevCommands(),
 			parser.ClustersCommands(),
 			parser.LaunchCommands(),
+			parser.ProfilesCommands(),
+			parser.BuildsCommands(),
+			parser.ReleasesCommands(),
+			parser.EventsCommands(),
 		},
 	}
 
-	commandList := os.Args
+	commandList, err := extractGlobalFlags(os.Args)
+	if err != nil {
+		color.Set(color.FgRed)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		color.Unset()
+		os.Exit(1)
+	}
+	config.PassphrasePrompt = cmd.PassphrasePrompt
+	warn := func(err error) {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	if err := cmd.ConfigureHTTP(config.NewConfigRepository(warn)); err != nil {
+		warn(err)
+	}
 
 	if len(commandList) > 1 && noneMigrated(commandList) {
 		os.Exit(Command(commandList[1:]))
@@ -76,9 +106,50 @@ func noneMigrated(commandList []string) bool {
 		!strings.Contains(commandList[1], "dev") &&
 		!strings.Contains(commandList[1], "clusters") &&
 		!strings.Contains(commandList[1], "launch") &&
+		!strings.Contains(commandList[1], "profiles") &&
+		!strings.Contains(commandList[1], "builds") &&
+		!strings.Contains(commandList[1], "releases") &&
+		!strings.Contains(commandList[1], "events") &&
 		!strings.Contains(commandList[1], "apps")
 }
 
+// extractGlobalFlags removes the flags shared by every command from args and applies them,
+// so that the subcommand parsers only see their own arguments. The value of a flag is the
+// one after "=", or the next argument when there is no "=", and may not be empty.
+func extractGlobalFlags(args []string) ([]string, error) {
+	setters := map[string]func(string){
+		"--profile": config.SelectProfile,
+		"--output":  cmd.SetOutputFormat,
+	}
+
+	remaining := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		// the arguments of commands run in the dev env follow --
+		if args[i] == "--" {
+			return append(remaining, args[i:]...), nil
+		}
+		name, value := args[i], ""
+		index := strings.Index(name, "=")
+		if index != -1 {
+			name, value = args[i][:index], args[i][index+1:]
+		}
+		setter, ok := setters[name]
+		if !ok {
+			remaining = append(remaining, args[i])
+			continue
+		}
+		if index == -1 && i+1 < len(args) {
+			i++
+			value = args[i]
+		}
+		if value == "" {
+			return nil, fmt.Errorf("Please provide a value for %s", name)
+		}
+		setter(value)
+	}
+	return remaining, nil
+}
+
 func preProcessCommand(args []string) (processedArgs []string) {
 	if len(args) == 1 {
 		return args
@@ -101,7 +172,11 @@ func isListShortcutCommand(args []string) bool {
 		args[1] == "domains" ||
 		args[1] == "routes" ||
 		args[1] == "clusters" ||
-		args[1] == "providers"
+		args[1] == "providers" ||
+		args[1] == "profiles" ||
+		args[1] == "builds" ||
+		args[1] == "releases" ||
+		args[1] == "events"
 }
 
 func replaceShortcut(command string) string {
@@ -146,6 +221,10 @@ Subcommands, use 'cde help [subcommand]' to learn more::
   config        manage environment variables that define app config
   ps            manage process status
   providers 	manage providers
+  profiles      manage controller profiles
+  builds        inspect the build history of an app
+  releases      inspect and roll back the releases of an app
+  events        watch build, release and deployment events
 `
 	command, argv := parseArgs(argv)
 
@@ -199,11 +278,18 @@ Subcommands, use 'cde help [subcommand]' to learn more::
 		fmt.Fprintln(os.Stderr, "Usage: cde <command> [<args>...]")
 	}
 
+	code := 1
+	if exitErr, ok := err.(cmd.ExitError); ok {
+		if exitErr.Err == nil {
+			return exitErr.Code
+		}
+		code, err = exitErr.Code, exitErr.Err
+	}
 	if err != nil {
 		color.Set(color.FgRed)
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		color.Unset()
-		return 1
+		return code
 	}
 	return 0
 }
@@ -43,3 +43,44 @@ func TestHelpReformattingWithCommand(t *testing.T) {
 		}
 	}
 }
+
+func TestExtractGlobalFlags(t *testing.T) {
+	tests := [][]string{
+		{"cde", "--profile", "prod", "apps:list"},
+		{"cde", "--profile=prod", "apps:list"},
+		{"cde", "apps:list", "--profile", "prod"},
+		{"cde", "--output", "json", "apps:list", "--profile=prod"},
+	}
+	expected := []string{"cde", "apps:list"}
+
+	for _, test := range tests {
+		actual, err := extractGlobalFlags(test)
+
+		if err != nil || !reflect.DeepEqual(expected, actual) {
+			t.Errorf("Expected %v, Got %v (%v)", expected, actual, err)
+		}
+	}
+}
+
+func TestExtractGlobalFlagsRejectsEmptyValues(t *testing.T) {
+	tests := [][]string{
+		{"cde", "--profile=", "apps:list"},
+		{"cde", "apps:list", "--output"},
+		{"cde", "--output", "", "apps:list"},
+	}
+
+	for _, test := range tests {
+		if actual, err := extractGlobalFlags(test); err == nil {
+			t.Errorf("Expected %v to be rejected, Got %v", test, actual)
+		}
+	}
+}
+
+func TestExtractGlobalFlagsStopsAtDoubleDash(t *testing.T) {
+	actual, err := extractGlobalFlags([]string{"cde", "--profile", "prod", "dev:exec", "--", "ls", "--output", "json"})
+	expected := []string{"cde", "dev:exec", "--", "ls", "--output", "json"}
+
+	if err != nil || !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, Got %v", expected, actual)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"net/url"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/olekukonko/tablewriter"
@@ -76,9 +77,6 @@ func AppCreate(appId string, stackName string, unifiedProcedure, providerName, o
 	appRepository := api.NewAppRepository(configRepository,
 		net.NewCloudControllerGateway(configRepository))
 
-	stackRepo := api.NewStackRepository(configRepository,
-		net.NewCloudControllerGateway(configRepository))
-
 	appName, _ := git.DetectAppName(configRepository.GitHost())
 	if appName != "" {
 		if !askForOverrideExistingApp() {
@@ -91,31 +89,26 @@ func AppCreate(appId string, stackName string, unifiedProcedure, providerName, o
 	var providerLink api.Link
 
 	if len(stackName) != 0 {
-		stacks, err := stackRepo.GetStackByName(stackName)
+		var err error
+		stack, err = resolveStack(configRepository, stackName)
 		if err != nil {
 			return err
 		}
 
-		stack = stacks.Items()[0]
-
 		stackId = stack.Id()
 	} else {
 		gateway := deploymentNet.NewCloudControllerGateway(configRepository)
 		ups := launcherApi.NewUpsRepository(configRepository, gateway)
 		providers := launcherApi.NewProviderRepository(configRepository, gateway)
 
-		unifiedProcedures, err := ups.GetUPByName(unifiedProcedure)
+		up, err := resolveUp(ups, unifiedProcedure)
 		if err != nil {
 			return err
 		}
 
-		if unifiedProcedures.Count() != 1 {
-			return fmt.Errorf("can not find the unified procedure by name given")
-		}
-
-		unifiedProcedureId = unifiedProcedures.Items()[0].Id()
+		unifiedProcedureId = up.Id()
 
-		provider, err := providers.GetProviderByName(providerName)
+		provider, err := resolveProvider(providers, providerName)
 		if err != nil {
 			return err
 		}
@@ -186,12 +179,13 @@ func AppsList() error {
 	if err != nil {
 		return err
 	}
-	fmt.Printf("=== Apps [%d]\n", len(apps.Items()))
+	return render(apps.Items(), func() {
+		fmt.Printf("=== Apps [%d]\n", len(apps.Items()))
 
-	for _, app := range apps.Items() {
-		fmt.Printf("id: %s\n", app.Name())
-	}
-	return nil
+		for _, app := range apps.Items() {
+			fmt.Printf("id: %s\n", app.Name())
+		}
+	})
 }
 
 func GetApp(appId string) error {
@@ -205,19 +199,20 @@ func GetApp(appId string) error {
 	if err != nil {
 		return err
 	}
-	outputDescription(app)
-	outputRoutes(app)
-	outputDependentServices(appId)
-
-	return nil
+	return render(app, func() {
+		outputDescription(app)
+		outputRoutes(app)
+		outputDependentServices(appId)
+	})
 }
 
 func outputDescription(app api.App) {
 	fmt.Printf("--- %s Application\n", app.Name())
-	data := make([][]string, 2)
+	data := make([][]string, 3)
 	data[0] = []string{"ID", app.Name()}
 	stack, _ := app.GetStack()
 	data[1] = []string{"Stack Name", stack.Name()}
+	data[2] = []string{"Stack Version", strconv.Itoa(stack.GetVersion())}
 
 	table := tablewriter.NewWriter(os.Stdout)
 	table.SetAlignment(tablewriter.ALIGN_LEFT)
@@ -230,7 +225,7 @@ func outputDescription(app api.App) {
 
 func outputRoutes(app api.App) {
 	boundRoutes, err := app.GetRoutes()
-	fmt.Println("--- Access routes:\n")
+	fmt.Print("--- Access routes:\n\n")
 
 	if err != nil {
 		fmt.Print(err)
@@ -319,21 +314,64 @@ func DestroyApp(appId string) error {
 	return nil
 }
 
-func SwitchStack(appName string, stackName string) error {
+// SwitchStack switches the app to the stack with name or id stackName, its current stack
+// when empty. The app follows the latest version of the stack, unless version pins it to
+// one of them.
+func SwitchStack(appName string, stackName string, version *int) error {
 	configRepository, appName, err := load(appName)
 	if err != nil {
 		return err
 	}
-	appRepository := api.NewAppRepository(configRepository,
-		net.NewCloudControllerGateway(configRepository))
+	gateway := net.NewCloudControllerGateway(configRepository)
+	appRepository := api.NewAppRepository(configRepository, gateway)
+
+	if version != nil && *version < 1 {
+		return fmt.Errorf("Invalid stack version %d", *version)
+	}
+	var stack api.Stack
+	if stackName == "" {
+		app, err := appRepository.GetApp(appName)
+		if err != nil {
+			return err
+		}
+		stack, err = app.GetStack()
+		if err != nil {
+			return err
+		}
+	} else {
+		stack, err = resolveStack(configRepository, stackName)
+		if err != nil {
+			return err
+		}
+	}
+
 	params := api.UpdateStackParams{
-		Stack: stackName,
+		Stack: stack.Name(),
 	}
+	if version != nil {
+		versions, err := allStackVersions(api.NewStackRepository(configRepository, gateway), stack.Id())
+		if err != nil {
+			return err
+		}
+		found := false
+		for _, v := range versions {
+			found = found || v.Version() == *version
+		}
+		if !found {
+			return fmt.Errorf("Stack '%s' has no version %d, see 'cde stacks:versions %s'", stack.Name(), *version, stack.Name())
+		}
+		params.Version = *version
+	}
+
 	err = appRepository.SwitchStack(appName, params)
 	if err != nil {
 		return err
 	}
-	fmt.Printf("Switch to stack '%s' successfully.\n", stackName)
+	if version == nil {
+		fmt.Printf("Switch to stack '%s' successfully.\n", stack.Name())
+	} else {
+		fmt.Printf("Switch to version %d of stack '%s' successfully.\n", *version, stack.Name())
+	}
 	return nil
 }
 
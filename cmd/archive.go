@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/cnupp/cli/pkg"
+)
+
+// DefaultMaxUploadSize is the largest source archive launch:build uploads, in megabytes.
+const DefaultMaxUploadSize = 100
+
+// sourceArchiveName is the name the packaged working tree is uploaded with.
+const sourceArchiveName = "source.tar.gz"
+
+// packageWorkingTree streams the current git working tree as a tar.gz archive. Files
+// excluded by .gitignore or .cdeignore are left out, and the archive is never written to disk.
+// It fails before streaming anything when the files add up to more than limit bytes.
+func packageWorkingTree(limit int64) (io.ReadCloser, error) {
+	root, files, err := git.WorkingTreeFiles()
+	if err != nil {
+		return nil, err
+	}
+	rules, err := git.LoadIgnoreRules(filepath.Join(root, git.IgnoreFile))
+	if err != nil {
+		return nil, err
+	}
+
+	var included []string
+	var size int64
+	for _, file := range files {
+		if rules.Ignored(file) {
+			continue
+		}
+		included = append(included, file)
+		info, err := os.Lstat(filepath.Join(root, filepath.FromSlash(file)))
+		if err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+	}
+	if err := checkUploadSize("the files of the working tree add up to", size, limit); err != nil {
+		return nil, err
+	}
+
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(writeArchive(writer, root, included))
+	}()
+	return reader, nil
+}
+
+// checkUploadSize fails when size is over limit, describing what weighs size.
+func checkUploadSize(what string, size int64, limit int64) error {
+	if limit > 0 && size > limit {
+		return fmt.Errorf("%s %s, more than the upload limit of %s", what, formatSize(size), formatSize(limit))
+	}
+	return nil
+}
+
+func writeArchive(writer io.Writer, root string, files []string) error {
+	gzipWriter := gzip.NewWriter(writer)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	for _, file := range files {
+		if err := addToArchive(tarWriter, root, file); err != nil {
+			return err
+		}
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return err
+	}
+	return gzipWriter.Close()
+}
+
+func addToArchive(tarWriter *tar.Writer, root string, name string) error {
+	path := filepath.Join(root, filepath.FromSlash(name))
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		// deleted from the working tree but still in the index
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var link string
+	if info.Mode()&os.ModeSymlink != 0 {
+		if link, err = os.Readlink(path); err != nil {
+			return err
+		}
+	}
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(tarWriter, file)
+	return err
+}
+
+// sizeLimitedReader fails once more than limit bytes have been read. The sources are
+// checked before the upload starts, it only guards against files growing meanwhile.
+type sizeLimitedReader struct {
+	reader io.Reader
+	read   int64
+	limit  int64
+}
+
+func (r *sizeLimitedReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.read += int64(n)
+	if r.limit > 0 && r.read > r.limit {
+		return n, fmt.Errorf("source archive exceeds the upload limit of %s", formatSize(r.limit))
+	}
+	return n, err
+}
+
+// progressReader reports on stderr how many bytes of a request body were sent.
+type progressReader struct {
+	body io.ReadCloser
+	read int64
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	r.read += int64(n)
+	fmt.Fprintf(os.Stderr, "\rUploading source... %s", formatSize(r.read))
+	return n, err
+}
+
+func (r *progressReader) Close() error {
+	return r.body.Close()
+}
+
+func (r *progressReader) Done() {
+	fmt.Fprintln(os.Stderr)
+}
+
+func formatSize(bytes int64) string {
+	switch {
+	case bytes >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(bytes)/(1<<20))
+	case bytes >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(bytes)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", bytes)
+	}
+}
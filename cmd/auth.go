@@ -1,25 +1,51 @@
 package cmd
 
 import (
+	"bufio"
 	"bytes"
 	"errors"
 	"fmt"
 	"github.com/cnupp/cli/config"
+	"github.com/cnupp/cli/httpclient"
 	"github.com/cnupp/appssdk/api"
 	"github.com/cnupp/appssdk/net"
 	"golang.org/x/crypto/ssh/terminal"
+	"io"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"syscall"
 )
 
-func Login(controller string, email string, password string) error {
+// Login authenticates against controller and stores the session in the current profile,
+// along with how the certificate of the controller is checked.
+func Login(controller string, email string, password string, options httpclient.TLSOptions) error {
+	if err := checkNoEnvToken(); err != nil {
+		return err
+	}
+
 	formalizedURL, err := formalizeURL(controller)
+	if err != nil {
+		return err
+	}
+
+	if options, err = absoluteTLSOptions(options); err != nil {
+		return err
+	}
+
+	configRepository := config.NewConfigRepository(func(err error) {})
+	if err = configureHTTP(configRepository, options); err != nil {
+		return err
+	}
 
 	if err = IsValidController(formalizedURL); err != nil {
 		return err
 	}
 
+	email, password = credentialsFromEnv(email, password)
+
 	if email == "" {
 		fmt.Print("email: ")
 		fmt.Scanln(&email)
@@ -35,16 +61,65 @@ func Login(controller string, email string, password string) error {
 		}
 	}
 
-	if err != nil {
-		return err
-	}
-
-	configRepository := config.NewConfigRepository(func(err error) {})
 	configRepository.SetEndpoint(formalizedURL.String())
+	configRepository.SetSSLVerify(!options.SkipVerify)
+	configRepository.SetCACert(options.CACert)
+	configRepository.SetClientCert(options.ClientCert, options.ClientKey)
 
 	return doLogin(email, password)
 }
 
+// absoluteTLSOptions resolves the certificate paths, which are stored in the config and
+// used from any directory.
+func absoluteTLSOptions(options httpclient.TLSOptions) (httpclient.TLSOptions, error) {
+	for _, path := range []*string{&options.CACert, &options.ClientCert, &options.ClientKey} {
+		if *path == "" {
+			continue
+		}
+		absolute, err := filepath.Abs(*path)
+		if err != nil {
+			return options, err
+		}
+		*path = absolute
+	}
+	return options, nil
+}
+
+// credentialsFromEnv completes the credentials missing from the command line with
+// CDE_EMAIL and CDE_PASSWORD.
+func credentialsFromEnv(email string, password string) (string, string) {
+	if email == "" {
+		email = os.Getenv("CDE_EMAIL")
+	}
+	if password == "" {
+		password = os.Getenv("CDE_PASSWORD")
+	}
+	return email, password
+}
+
+// checkNoEnvToken refuses to log in, or to regenerate the token, while CDE_TOKEN is set,
+// as the session would only last for the current process.
+func checkNoEnvToken() error {
+	if os.Getenv(config.TokenEnv) != "" {
+		return fmt.Errorf("%s is set, the session comes from the environment. Unset it to log in.", config.TokenEnv)
+	}
+	return nil
+}
+
+// PasswordFromStdin reads the password from the first line of stdin, which keeps it out
+// of the process list and the shell history.
+func PasswordFromStdin() (string, error) {
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", errors.New("no password on stdin")
+	}
+	return password, nil
+}
+
 func formalizeURL(controller string) (url.URL, error) {
 	u, err := url.Parse(controller)
 	if err != nil {
@@ -63,6 +138,10 @@ func formalizeURL(controller string) (url.URL, error) {
 }
 
 func Register(controller string, email string, password string) error {
+	if err := checkNoEnvToken(); err != nil {
+		return err
+	}
+
 	formalizedURL, err := formalizeURL(controller)
 
 	if err != nil {
@@ -73,6 +152,8 @@ func Register(controller string, email string, password string) error {
 		return err
 	}
 
+	email, password = credentialsFromEnv(email, password)
+
 	if email == "" {
 		fmt.Print("email: ")
 		fmt.Scanln(&email)
@@ -137,13 +218,6 @@ func IsValidController(apiURL url.URL) error {
 	errorMessage := `%s does not appear to be a valid Cde controller.
 Make sure that the Controller URI is correct and the server is running.`
 
-	var createHttpClient = func() *http.Client {
-		tr := &http.Transport{
-			DisableKeepAlives: true,
-		}
-		return &http.Client{Transport: tr}
-	}
-
 	baseURL := apiURL.String()
 
 	apiURL.Path += "/"
@@ -154,7 +228,7 @@ Make sure that the Controller URI is correct and the server is running.`
 		return err
 	}
 
-	res, err := createHttpClient().Do(req)
+	res, err := httpClient.Do(req)
 	if err != nil {
 		return err
 	}
@@ -169,14 +243,7 @@ Make sure that the Controller URI is correct and the server is running.`
 
 func doLogin(email string, password string) error {
 	configRepository := config.NewConfigRepository(func(err error) {})
-	authRepository := api.NewAuthRepository(
-		configRepository,
-		net.NewCloudControllerGateway(configRepository))
-	userParams := api.UserParams{
-		Email:    email,
-		Password: password,
-	}
-	auth, err := authRepository.Create(userParams)
+	auth, err := createAuth(configRepository, email, password)
 	if err != nil {
 		return err
 	}
@@ -184,7 +251,7 @@ func doLogin(email string, password string) error {
 	configRepository.SetAuth(auth.Id())
 
 	configRepository = config.NewConfigRepository(func(err error) {})
-	authRepository = api.NewAuthRepository(
+	authRepository := api.NewAuthRepository(
 		configRepository,
 		net.NewCloudControllerGateway(configRepository))
 	user, err := authRepository.Get()
@@ -198,6 +265,68 @@ func doLogin(email string, password string) error {
 	return nil
 }
 
+// sessionConfig sends requests with auth instead of the auth of the profile, which may
+// have expired. Requests that log in are sent without any.
+type sessionConfig struct {
+	config.ConfigRepository
+	auth string
+}
+
+func (c sessionConfig) Auth() string {
+	return c.auth
+}
+
+func createAuth(configRepository config.ConfigRepository, email string, password string) (api.Auth, error) {
+	anonymous := sessionConfig{ConfigRepository: configRepository}
+	authRepository := api.NewAuthRepository(anonymous, net.NewCloudControllerGateway(anonymous))
+	return authRepository.Create(api.UserParams{
+		Email:    email,
+		Password: password,
+	})
+}
+
+// passwordPrompt asks for a password on the terminal, it fails when there is none to ask.
+var passwordPrompt = func(prompt string) (string, error) {
+	if !terminal.IsTerminal(int(syscall.Stdin)) {
+		return "", errors.New("no terminal to ask for the password")
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	password, err := readPassword()
+	fmt.Fprintln(os.Stderr)
+	return password, err
+}
+
+// PassphrasePrompt asks for the passphrase of the secret store on the terminal.
+func PassphrasePrompt(prompt string) (string, error) {
+	return passwordPrompt(prompt)
+}
+
+// reauthenticate logs the user in again once the controller rejected their session,
+// asking for their password, and returns the new auth.
+func reauthenticate(req *http.Request) (string, error) {
+	// there is nothing to renew when logging out
+	if req.Method == "DELETE" && strings.HasPrefix(req.URL.Path, "/auths/") {
+		return "", errors.New("logging out")
+	}
+
+	configRepository := config.NewConfigRepository(func(err error) {})
+	email := configRepository.Email()
+	if email == "" {
+		return "", errors.New("not logged in")
+	}
+
+	password, err := passwordPrompt(fmt.Sprintf("Your session has expired, password for %s: ", email))
+	if err != nil {
+		return "", err
+	}
+	auth, err := createAuth(configRepository, email, password)
+	if err != nil {
+		return "", err
+	}
+	configRepository.SetAuth(auth.Id())
+	return auth.Id(), nil
+}
+
 func readPassword() (string, error) {
 	password, err := terminal.ReadPassword(int(syscall.Stdin))
 
@@ -212,25 +341,169 @@ func Logout() error {
 		net.NewCloudControllerGateway(configRepository))
 	err := authRepository.Delete(token)
 
-	if err != nil {
+	if err != nil && !errors.Is(err, httpclient.ErrSessionExpired) {
 		return err
 	}
 	fmt.Println("Logout successfully")
 	return nil
 }
 
+// Cancel deletes the account with email, the current user when empty, once the user
+// confirmed it unless force is set.
 func Cancel(email string, password string, force bool) error {
+	configRepository := config.NewConfigRepository(func(err error) {})
+	var currentId string
+	if current, err := currentUser(configRepository); err == nil {
+		currentId = current.Id()
+		if email == "" {
+			email = current.Email()
+		}
+	}
+	if email == "" {
+		return errors.New("You are not logged in, provide the account with --email.")
+	}
+
+	if !force && !confirm(fmt.Sprintf("Cancel the account %s? This cannot be undone. (y/N): ", email)) {
+		fmt.Println("Account not cancelled")
+		return nil
+	}
+
+	if password == "" {
+		var err error
+		password, err = passwordPrompt(fmt.Sprintf("password for %s: ", email))
+		if err != nil {
+			return fmt.Errorf("%v, provide it with --password", err)
+		}
+	}
+
+	auth, err := createAuth(configRepository, email, password)
+	if err != nil {
+		return err
+	}
+	session := sessionConfig{ConfigRepository: configRepository, auth: auth.Id()}
+	user, err := api.NewAuthRepository(session, net.NewCloudControllerGateway(session)).Get()
+	if err != nil {
+		return err
+	}
+
+	users := api.NewUserRepository(session, net.NewCloudControllerGateway(session))
+	if err := users.Delete(user.Id()); err != nil {
+		return err
+	}
+
+	if currentId == user.Id() {
+		configRepository.SetAuth("")
+		configRepository.SetEmail("")
+		configRepository.SetId("")
+	}
+
+	fmt.Printf("Account %s cancelled\n", email)
 	return nil
 }
 
-func Regenerate() error {
+// Passwd changes the password of the current user, asking for the passwords that are not
+// given.
+func Passwd(password string, newPassword string) error {
+	configRepository := config.NewConfigRepository(func(err error) {})
+	current, err := currentUser(configRepository)
+	if err != nil {
+		return err
+	}
+
+	if password == "" {
+		if password, err = passwordPrompt("current password: "); err != nil {
+			return fmt.Errorf("%v, provide it with --password", err)
+		}
+	}
+
+	if newPassword == "" {
+		if newPassword, err = passwordPrompt("new password: "); err != nil {
+			return fmt.Errorf("%v, provide it with --new-password", err)
+		}
+		confirmation, err := passwordPrompt("new password (confirm): ")
+		if err != nil {
+			return err
+		}
+		if newPassword != confirmation {
+			return errors.New("Password mismatch, password unchanged.")
+		}
+	}
+
+	users := api.NewUserRepository(configRepository, net.NewCloudControllerGateway(configRepository))
+	err = users.ChangePassword(current.Id(), api.PasswordParams{
+		Password:    password,
+		NewPassword: newPassword,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Password changed")
+	return nil
+}
+
+// currentUser returns the user of the auth in use. It is asked to the controller, as the
+// auth of CDE_TOKEN comes without the id and email of its user.
+func currentUser(configRepository config.ConfigRepository) (api.User, error) {
+	if configRepository.Auth() == "" {
+		return nil, errors.New("You are not logged in.")
+	}
+	return api.NewAuthRepository(configRepository, net.NewCloudControllerGateway(configRepository)).Get()
+}
+
+// confirm asks a yes or no question, anything but yes is a no.
+func confirm(prompt string) bool {
+	fmt.Print(prompt)
+	var answer string
+	fmt.Scanln(&answer)
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// Regenerate replaces the auth of the current user with a new one and revokes the
+// previous one, asking for the password when it is not given.
+func Regenerate(password string) error {
+	if err := checkNoEnvToken(); err != nil {
+		return err
+	}
+	configRepository := config.NewConfigRepository(func(err error) {})
+	email := configRepository.Email()
+	if email == "" {
+		return errors.New("You are not logged in.")
+	}
+
+	if password == "" {
+		var err error
+		password, err = passwordPrompt(fmt.Sprintf("password for %s: ", email))
+		if err != nil {
+			return fmt.Errorf("%v, provide it with --password", err)
+		}
+	}
+
+	previous := configRepository.Auth()
+	auth, err := createAuth(configRepository, email, password)
+	if err != nil {
+		return err
+	}
+	configRepository.SetAuth(auth.Id())
+
+	if previous != "" {
+		authRepository := api.NewAuthRepository(
+			configRepository,
+			net.NewCloudControllerGateway(configRepository))
+		if err := authRepository.Delete(previous); err != nil && !errors.Is(err, httpclient.ErrSessionExpired) {
+			return fmt.Errorf("the new token is in use but the previous one could not be revoked: %v", err)
+		}
+	}
+
+	fmt.Println("Token regenerated")
 	return nil
 }
 
 func Whoami() error {
 	configRepository := config.NewConfigRepository(func(err error) {})
 
-	fmt.Printf("You are %s at %s\n", configRepository.Email(), configRepository.Endpoint())
+	fmt.Printf("You are %s at %s (profile %s)\n", configRepository.Email(), configRepository.Endpoint(), configRepository.ProfileName())
 
 	return nil
 }
@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/cnupp/appssdk/api"
+	"github.com/cnupp/appssdk/net"
+	"github.com/olekukonko/tablewriter"
+)
+
+func BuildsList(appId string, page int, all bool) error {
+	configRepository, appId, err := load(appId)
+	if err != nil {
+		return err
+	}
+	app, err := api.NewAppRepository(configRepository,
+		net.NewCloudControllerGateway(configRepository)).GetApp(appId)
+	if err != nil {
+		return err
+	}
+
+	if page < 1 {
+		return errors.New("page should be greater than 0")
+	}
+
+	current, err := app.GetBuilds()
+	for index := 1; err == nil && index < page; index++ {
+		if current, err = current.Next(); err == nil && current == nil {
+			return fmt.Errorf("page %d is out of range", page)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	count, builds := current.Count(), current.Items()
+	next, err := current.Next()
+	for ; all && err == nil && next != nil; next, err = next.Next() {
+		builds = append(builds, next.Items()...)
+	}
+	if err != nil {
+		return err
+	}
+
+	return render(builds, func() {
+		fmt.Printf("=== %s Builds [%d]\n", app.Name(), count)
+		outputBuilds(builds)
+		if !all && next != nil {
+			fmt.Printf("more builds available, use --page %d or --all\n", page+1)
+		}
+	})
+}
+
+func outputBuilds(builds []api.BuildRef) {
+	var data [][]string
+	data = append(data, []string{"id", "git sha", "status", "verify", "created_at", "updated_at"})
+	for _, build := range builds {
+		data = append(data, []string{build.ID, build.GitSha, build.Status, build.Verify.Status(), formatTimestamp(build.CreatedAt), formatTimestamp(build.UpdatedAt)})
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetRowSeparator("-")
+	table.SetRowLine(true)
+	table.AppendBulk(data)
+	table.Render()
+}
+
+func BuildInfo(appId string, buildId string) error {
+	configRepository, appId, err := load(appId)
+	if err != nil {
+		return err
+	}
+	app, err := api.NewAppRepository(configRepository,
+		net.NewCloudControllerGateway(configRepository)).GetApp(appId)
+	if err != nil {
+		return err
+	}
+
+	build, err := app.GetBuild(buildId)
+	if err != nil {
+		return err
+	}
+
+	return render(build, func() {
+		fmt.Printf("--- Build %s\n", build.Id())
+		data := [][]string{
+			{"Git Sha", build.GitSha()},
+			{"Status", build.Status()},
+			{"Verify", build.Verify().Id()},
+			{"Verify Status", build.Verify().Status()},
+			{"Created At", formatTimestamp(build.CreatedAt())},
+			{"Updated At", formatTimestamp(build.UpdatedAt())},
+		}
+
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetAlignment(tablewriter.ALIGN_LEFT)
+		table.AppendBulk(data)
+		table.Render()
+	})
+}
+
+func BuildLogs(appId string, buildId string, verify bool, follow bool) error {
+	configRepository, appId, err := load(appId)
+	if err != nil {
+		return err
+	}
+	appRepository := api.NewAppRepository(configRepository,
+		net.NewCloudControllerGateway(configRepository))
+	app, err := appRepository.GetApp(appId)
+	if err != nil {
+		return err
+	}
+
+	logType := buildLogType
+	if verify {
+		logType = verifyLogType
+	}
+	fetch := buildLogFetcher(app, buildId, logType)
+
+	if !follow {
+		printLogFrom(fetch, 0)
+		return nil
+	}
+
+	return followProcedure(func() (bool, error) {
+		build, err := app.GetBuild(buildId)
+		if err != nil {
+			return false, err
+		}
+		if verify {
+			return build.IsVerifySuccess() || build.IsVerifyFail(), nil
+		}
+		return build.IsSuccess() || build.IsFail(), nil
+	}, fetch)
+}
+
+// formatTimestamp renders the millisecond timestamps used by the controller.
+func formatTimestamp(millis int64) string {
+	if millis == 0 {
+		return ""
+	}
+	return time.Unix(millis/1000, 0).String()
+}
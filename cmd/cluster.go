@@ -7,6 +7,7 @@ import (
 	launcherApi "github.com/cnupp/runtimesdk/api"
 	deploymentNet "github.com/cnupp/runtimesdk/net"
 	"os"
+	"strconv"
 )
 
 func ClusterList() error {
@@ -17,24 +18,24 @@ func ClusterList() error {
 		return err
 	}
 
-	fmt.Printf("=== Clusters [%d]\n", len(clusters.Items()))
+	return render(clusters.Items(), func() {
+		fmt.Printf("=== Clusters [%d]\n", len(clusters.Items()))
 
-	for _, cluster := range clusters.Items() {
-		fmt.Printf("name: %s\t id: %d \n", cluster.Name(), cluster.Id())
-	}
-	return nil
+		for _, cluster := range clusters.Items() {
+			fmt.Printf("name: %s\t id: %d \n", cluster.Name(), cluster.Id())
+		}
+	})
 }
 
-func GetCluster(clusterId string) error {
+func GetCluster(nameOrId string) error {
 	configRepository := config.NewConfigRepository(func(error) {})
-	clusterRepository := launcherApi.NewClusterRepository(configRepository, deploymentNet.NewCloudControllerGateway(configRepository))
-	cluster, err := clusterRepository.GetClusterById(clusterId)
+	cluster, err := resolveCluster(configRepository, nameOrId)
 	if err != nil {
 		return err
 	}
-	outputClusterDescription(cluster)
-
-	return nil
+	return render(cluster, func() {
+		outputClusterDescription(cluster)
+	})
 }
 
 func outputClusterDescription(cluster launcherApi.ClusterRef) {
@@ -71,11 +72,15 @@ func ClusterCreate(clusterName string, clusterType string, clusterUri string) er
 	return nil
 }
 
-func ClusterRemove(clusterId string) error {
+func ClusterRemove(nameOrId string) error {
 	configRepository := config.NewConfigRepository(func(error) {})
 	clusterRepository := launcherApi.NewClusterRepository(configRepository, deploymentNet.NewCloudControllerGateway(configRepository))
+	cluster, err := resolveCluster(configRepository, nameOrId)
+	if err != nil {
+		return err
+	}
 
-	err := clusterRepository.DeleteClusterById(clusterId)
+	err = clusterRepository.DeleteClusterById(strconv.Itoa(cluster.Id()))
 	if err != nil {
 		return err
 	}
@@ -83,11 +88,11 @@ func ClusterRemove(clusterId string) error {
 	return nil
 }
 
-func ClusterUpdate(clusterId string, clusterName string, clusterType string, clusterUri string) error {
+func ClusterUpdate(nameOrId string, clusterName string, clusterType string, clusterUri string) error {
 	configRepository := config.NewConfigRepository(func(error) {})
 	clusterRepository := launcherApi.NewClusterRepository(configRepository, deploymentNet.NewCloudControllerGateway(configRepository))
 
-	cluster, err := clusterRepository.GetClusterById(clusterId)
+	cluster, err := resolveCluster(configRepository, nameOrId)
 	if err != nil {
 		return err
 	}
@@ -107,7 +112,7 @@ func ClusterUpdate(clusterId string, clusterName string, clusterType string, clu
 		Uri:  clusterUri,
 	}
 
-	updateErr := clusterRepository.UpdateCluster(clusterId, clusterParams)
+	updateErr := clusterRepository.UpdateCluster(strconv.Itoa(cluster.Id()), clusterParams)
 	if updateErr != nil {
 		return updateErr
 	}
@@ -0,0 +1,1236 @@
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/cnupp/appssdk/api"
+	"github.com/cnupp/appssdk/net"
+	"github.com/cnupp/cli/config"
+	"github.com/cnupp/cli/httpclient"
+	"github.com/cnupp/cli/testhelpers/controller"
+	"github.com/ghodss/yaml"
+)
+
+const (
+	testEmail    = "dev@example.com"
+	testPassword = "secret"
+)
+
+// startController runs the commands against a fake controller with a fresh config
+// directory and a logged in user. The returned function restores the environment.
+func startController(t *testing.T) (*controller.Controller, func()) {
+	home, err := ioutil.TempDir("", "cde-home")
+	if err != nil {
+		t.Fatal(err)
+	}
+	previousHome := os.Getenv("CDE_HOME")
+	os.Setenv("CDE_HOME", home)
+
+	fake := controller.NewController()
+	previousTransport := http.DefaultTransport
+	http.DefaultTransport = fake.Transport()
+	previousInterval := procedurePollInterval
+	procedurePollInterval = 0
+
+	teardown := func() {
+		procedurePollInterval = previousInterval
+		http.DefaultTransport = previousTransport
+		fake.Close()
+		os.Setenv("CDE_HOME", previousHome)
+		os.RemoveAll(home)
+	}
+
+	fake.AddUser(testEmail, testPassword)
+	if _, err := captureOutput(func() error { return Login(fake.URL, testEmail, testPassword, httpclient.TLSOptions{}) }); err != nil {
+		teardown()
+		t.Fatalf("login failed: %v", err)
+	}
+	return fake, teardown
+}
+
+// captureOutput returns what run printed on stdout.
+func captureOutput(run func() error) (string, error) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return "", err
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+
+	output := make(chan string)
+	go func() {
+		var buffer bytes.Buffer
+		io.Copy(&buffer, reader)
+		output <- buffer.String()
+	}()
+
+	err = run()
+	os.Stdout = stdout
+	writer.Close()
+	return <-output, err
+}
+
+// inGitRepository runs the test from an empty git repository, as app commands expect.
+func inGitRepository(t *testing.T) func() {
+	directory, err := ioutil.TempDir("", "cde-app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command("git", "init", directory).CombinedOutput(); err != nil {
+		t.Skipf("git is not available: %v %s", err, out)
+	}
+	previous, _ := os.Getwd()
+	os.Chdir(directory)
+	return func() {
+		os.Chdir(previous)
+		os.RemoveAll(directory)
+	}
+}
+
+// keepHTTPClients lets a test configure the http clients, the returned function restores them.
+func keepHTTPClients() func() {
+	transport, client := http.DefaultTransport, httpClient
+	return func() {
+		http.DefaultTransport, httpClient = transport, client
+	}
+}
+
+// sent tells whether the controller received request, e.g. "PUT /stacks/1".
+func sent(fake *controller.Controller, request string) bool {
+	for _, received := range fake.Requests() {
+		if received == request {
+			return true
+		}
+	}
+	return false
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	directory, err := ioutil.TempDir("", "cde-file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(directory, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRender(t *testing.T) {
+	defer SetOutputFormat(OutputTable)
+
+	verify := api.VerifyModel{IDField: "7", StatusField: "SUCCESS", BuildMapper: api.NewBuildMapper(nil, net.Gateway{})}
+	build := api.BuildModel{
+		IDField:     "12",
+		GitShaField: "abc123",
+		StatusField: "SUCCESS",
+		VerifyField: verify,
+		LinksField:  []api.Link{{Relation: "self", URI: "/apps/hello/builds/12"}},
+		BuildMapper: api.NewBuildMapper(nil, net.Gateway{}),
+	}
+	document := map[string]interface{}{
+		"id":         "12",
+		"git_sha":    "abc123",
+		"status":     "SUCCESS",
+		"verify":     map[string]interface{}{"id": "7", "status": "SUCCESS"},
+		"created_at": float64(0),
+		"updated_at": float64(0),
+		"links":      []interface{}{map[string]interface{}{"rel": "self", "uri": "/apps/hello/builds/12"}},
+	}
+	fromYaml := func(data []byte, value interface{}) error { return yaml.Unmarshal(data, value) }
+	refs := []api.BuildRef{{ID: "12", GitSha: "abc123", Status: "SUCCESS", Verify: verify, Links: build.LinksField}}
+
+	tests := []struct {
+		format    string
+		value     interface{}
+		expected  interface{}
+		unmarshal func([]byte, interface{}) error
+	}{
+		{OutputJson, build, document, json.Unmarshal},
+		{OutputYaml, build, document, fromYaml},
+		{OutputJson, refs, []interface{}{document}, json.Unmarshal},
+		{OutputYaml, refs, []interface{}{document}, fromYaml},
+		{OutputJson, []api.BuildRef(nil), []interface{}{}, json.Unmarshal},
+		{OutputYaml, []api.BuildRef(nil), []interface{}{}, fromYaml},
+	}
+	for _, test := range tests {
+		SetOutputFormat(test.format)
+		tabled := false
+		output, err := captureOutput(func() error { return render(test.value, func() { tabled = true }) })
+		if err != nil || tabled {
+			t.Errorf("%s: expected a document instead of the table, got %v", test.format, err)
+			continue
+		}
+		var got interface{}
+		if err := test.unmarshal([]byte(output), &got); err != nil {
+			t.Errorf("%s: expected a document, got %q (%v)", test.format, output, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.format, test.expected, got)
+		}
+	}
+
+	SetOutputFormat("TABLE")
+	tabled := false
+	if err := render(build, func() { tabled = true }); err != nil || !tabled {
+		t.Errorf("expected the table to be printed, got %v", err)
+	}
+	SetOutputFormat("xml")
+	if err := render(build, func() {}); err == nil || !strings.Contains(err.Error(), "unsupported output format xml") {
+		t.Errorf("expected xml to be refused, got %v", err)
+	}
+}
+
+func TestLoginAndLogout(t *testing.T) {
+	fake, teardown := startController(t)
+	defer teardown()
+
+	configRepository := config.NewConfigRepository(func(error) {})
+	if configRepository.Email() != testEmail || configRepository.Auth() == "" {
+		t.Fatalf("expected %s to be logged in, got email %q and auth %q", testEmail, configRepository.Email(), configRepository.Auth())
+	}
+
+	if _, err := captureOutput(Logout); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := captureOutput(AppsList); err == nil {
+		t.Error("expected the commands to be rejected after logout")
+	}
+
+	if _, err := captureOutput(func() error { return Login(fake.URL, testEmail, "wrong", httpclient.TLSOptions{}) }); err == nil {
+		t.Error("expected login with a wrong password to fail")
+	}
+}
+
+func TestLoginWithCustomCA(t *testing.T) {
+	home, err := ioutil.TempDir("", "cde-home")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+	previousHome := os.Getenv("CDE_HOME")
+	os.Setenv("CDE_HOME", home)
+	defer os.Setenv("CDE_HOME", previousHome)
+	defer keepHTTPClients()()
+
+	fake := controller.NewTLSController()
+	defer fake.Close()
+	fake.AddUser(testEmail, testPassword)
+	caCert := writeFile(t, "ca.pem", string(fake.CertificatePEM()))
+
+	if _, err := captureOutput(func() error { return Login(fake.URL, testEmail, testPassword, httpclient.TLSOptions{}) }); err == nil {
+		t.Fatal("expected the self-signed certificate of the controller to be rejected")
+	}
+
+	if _, err := captureOutput(func() error {
+		return Login(fake.URL, testEmail, testPassword, httpclient.TLSOptions{CACert: caCert})
+	}); err != nil {
+		t.Fatal(err)
+	}
+	configRepository := config.NewConfigRepository(func(error) {})
+	if !configRepository.SSLVerify() || configRepository.CACert() != caCert {
+		t.Errorf("expected the CA certificate to be stored, got %v %s", configRepository.SSLVerify(), configRepository.CACert())
+	}
+	if _, err := captureOutput(AppsList); err != nil {
+		t.Errorf("expected the commands to trust the CA certificate, got %v", err)
+	}
+
+	if _, err := captureOutput(func() error {
+		return Login(fake.URL, testEmail, testPassword, httpclient.TLSOptions{SkipVerify: true})
+	}); err != nil {
+		t.Fatal(err)
+	}
+	configRepository = config.NewConfigRepository(func(error) {})
+	if configRepository.SSLVerify() || configRepository.CACert() != "" {
+		t.Errorf("expected the verification to be disabled, got %v %s", configRepository.SSLVerify(), configRepository.CACert())
+	}
+}
+
+func TestExpiredSession(t *testing.T) {
+	fake, teardown := startController(t)
+	defer teardown()
+	defer keepHTTPClients()()
+	ConfigureHTTP(config.NewConfigRepository(func(error) {}))
+
+	previousPrompt := passwordPrompt
+	defer func() { passwordPrompt = previousPrompt }()
+	prompts := 0
+	passwordPrompt = func(string) (string, error) {
+		prompts++
+		return "", errors.New("no terminal to ask for the password")
+	}
+
+	fake.ExpireSessions()
+	if _, err := captureOutput(AppsList); !errors.Is(err, httpclient.ErrSessionExpired) {
+		t.Errorf("expected the session to be reported as expired, got %v", err)
+	}
+
+	// a new process asks again, and the password logs the user back in
+	ConfigureHTTP(config.NewConfigRepository(func(error) {}))
+	passwordPrompt = func(string) (string, error) {
+		prompts++
+		return testPassword, nil
+	}
+	if _, err := captureOutput(AppsList); err != nil {
+		t.Errorf("expected the request to be sent again once logged in, got %v", err)
+	}
+	if prompts != 2 || fake.Sessions() != 1 {
+		t.Errorf("expected one prompt per process and a new session, got %d prompts and %d sessions", prompts, fake.Sessions())
+	}
+}
+
+func TestRegenerate(t *testing.T) {
+	fake, teardown := startController(t)
+	defer teardown()
+
+	previous := config.NewConfigRepository(func(error) {}).Auth()
+	if _, err := captureOutput(func() error { return Regenerate(testPassword) }); err != nil {
+		t.Fatal(err)
+	}
+	if auth := config.NewConfigRepository(func(error) {}).Auth(); auth == previous || fake.Sessions() != 1 {
+		t.Errorf("expected %s to be replaced and revoked, got %s and %d sessions", previous, auth, fake.Sessions())
+	}
+	if _, err := captureOutput(AppsList); err != nil {
+		t.Errorf("expected the new token to be used, got %v", err)
+	}
+	if _, err := captureOutput(func() error { return Regenerate("wrong") }); err == nil {
+		t.Error("expected a wrong password to be rejected")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	fake, teardown := startController(t)
+	defer teardown()
+
+	output, err := captureOutput(func() error { return Register(fake.URL, "new@example.com", "password") })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output, "Welcome new@example.com") {
+		t.Errorf("expected the new user to be logged in, got %q", output)
+	}
+	if _, err := captureOutput(func() error { return Register(fake.URL, "new@example.com", "password") }); err == nil {
+		t.Error("expected registering the same email twice to fail")
+	}
+}
+
+func TestStackCommands(t *testing.T) {
+	fake, teardown := startController(t)
+	defer teardown()
+
+	filename := writeFile(t, "stack.yml", `
+name: javajersey
+type: BUILD_STACK
+services:
+  main:
+    build:
+      image: jersey-build
+  web:
+    image: java:8
+    instances: 1
+`)
+	output, err := captureOutput(func() error { return StackCreate(filename) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output, "create stack javajersey") {
+		t.Errorf("unexpected output %q", output)
+	}
+
+	output, err = captureOutput(StacksList)
+	if err != nil || !strings.Contains(output, "name: javajersey") {
+		t.Errorf("expected javajersey to be listed, got %q (%v)", output, err)
+	}
+
+	output, err = captureOutput(func() error { return GetStack("javajersey") })
+	if err != nil || !strings.Contains(output, "javajersey Stack") {
+		t.Errorf("expected the stack description, got %q (%v)", output, err)
+	}
+
+	var id string
+	for _, request := range fake.Requests() {
+		if strings.HasPrefix(request, "GET /stacks/") {
+			id = strings.TrimPrefix(request, "GET /stacks/")
+		}
+	}
+	if _, err := captureOutput(func() error { return StackPublish(id) }); err != nil {
+		t.Fatal(err)
+	}
+	if stack, _ := fake.Stack(id); stack["status"] != "PUBLISHED" {
+		t.Errorf("expected the stack to be published, got %v", stack["status"])
+	}
+
+	if _, err := captureOutput(func() error { return StackRemove("javajersey") }); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fake.Stack(id); ok {
+		t.Error("expected the stack to be removed")
+	}
+}
+
+func TestInvalidStackIsNotSent(t *testing.T) {
+	fake, teardown := startController(t)
+	defer teardown()
+
+	filename := writeFile(t, "stack.yml", `
+name: javajersey
+services:
+  web:
+    image: java:8
+    links:
+    - db
+`)
+	requests := len(fake.Requests())
+	err := StackCreate(filename)
+	if err == nil || !strings.Contains(err.Error(), filename+":7: services.web.links[0]: link to undefined service db") {
+		t.Errorf("expected the undefined link to be reported with its line, got %v", err)
+	}
+	if len(fake.Requests()) != requests {
+		t.Errorf("expected the invalid stack not to be sent, got %v", fake.Requests()[requests:])
+	}
+
+	if _, err := captureOutput(func() error { return StackValidate(filename, false) }); err == nil {
+		t.Error("expected stacks:validate to fail")
+	}
+}
+
+func TestStackWithUnknownFields(t *testing.T) {
+	fake, teardown := startController(t)
+	defer teardown()
+
+	filename := writeFile(t, "stack.yml", `
+name: javajersey
+owner: team
+services:
+  main:
+    build:
+      image: jersey-build
+`)
+	if _, err := captureOutput(func() error { return StackValidate(filename, false) }); err != nil {
+		t.Errorf("expected the unknown field to be a warning, got %v", err)
+	}
+	err := StackValidate(filename, true)
+	if err == nil || !strings.Contains(err.Error(), "has 1 warnings") {
+		t.Errorf("expected --strict to fail on the unknown field, got %v", err)
+	}
+
+	if _, err := captureOutput(func() error { return StackCreate(filename) }); err != nil {
+		t.Fatalf("expected the stack to be created, got %v", err)
+	}
+	if !sent(fake, "POST /stacks") {
+		t.Errorf("expected the stack to be sent, got %v", fake.Requests())
+	}
+}
+
+func TestStackDiff(t *testing.T) {
+	fake, teardown := startController(t)
+	defer teardown()
+
+	fake.AddStack(controller.Document{"name": "javajersey", "services": map[string]interface{}{
+		"main": map[string]interface{}{"build": map[string]interface{}{"image": "jersey-build"}, "links": []string{"db"}},
+		"db":   map[string]interface{}{"image": "mysql:5.6", "expose": 3306, "health": []interface{}{map[string]interface{}{"protocol": "TCP"}}},
+	}})
+
+	filename := writeFile(t, "stack.yml", `
+name: javajersey
+type: BUILD_STACK
+services:
+  main:
+    build:
+      image: jersey-build
+    links:
+    - db
+  db:
+    image: mysql:5.6
+    expose: 3306
+    health:
+    - protocol: tcp
+`)
+	output, err := captureOutput(func() error { return StackDiff("javajersey", filename) })
+	if err != nil || !strings.Contains(output, "stack javajersey is up to date with "+filename) {
+		t.Errorf("expected no difference, got %q (%v)", output, err)
+	}
+
+	filename = writeFile(t, "stack.yml", `
+name: javajersey
+type: BUILD_STACK
+services:
+  main:
+    build:
+      image: jersey-build
+    links:
+    - db
+    environment:
+      DB_HOST: db
+  db:
+    image: mysql:5.7
+    expose: 3306
+`)
+	output, err = captureOutput(func() error { return StackDiff("javajersey", filename) })
+	if exitErr, ok := err.(ExitError); !ok || exitErr.Code != 1 {
+		t.Errorf("expected the differences to fail with code 1, got %v", err)
+	}
+	for _, expected := range []string{
+		"~ service db\n  - health[0].protocol: TCP\n  ~ image: mysql:5.6 -> mysql:5.7\n",
+		"~ service main\n  + environment.DB_HOST: db\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("expected %q in the diff, got %q", expected, output)
+		}
+	}
+
+	_, err = captureOutput(func() error { return StackDiff("unknown", filename) })
+	if exitErr, ok := err.(ExitError); !ok || exitErr.Code != 2 || exitErr.Err == nil {
+		t.Errorf("expected an unknown stack to fail with code 2, got %#v", err)
+	}
+}
+
+func TestStackExport(t *testing.T) {
+	fake, teardown := startController(t)
+	defer teardown()
+
+	id := fake.AddStack(controller.Document{"name": "javajersey", "description": "Jersey with MySQL", "services": map[string]interface{}{
+		"main": map[string]interface{}{"name": "main", "build": map[string]interface{}{"image": "jersey-build", "mem": 512}, "links": []string{"db"}},
+		"db": map[string]interface{}{
+			"image":   "mysql:5.7",
+			"expose":  3306,
+			"cpus":    0.5,
+			"health":  []interface{}{map[string]interface{}{"protocol": "TCP", "interval": 30}},
+			"volumes": []interface{}{map[string]interface{}{"container": "/var/lib/mysql", "host": "data", "mode": "RW"}},
+		},
+	}})
+
+	output, err := captureOutput(func() error { return StackExport("javajersey", "") })
+	if err != nil || !strings.Contains(output, "name: javajersey\n") || !strings.Contains(output, "    image: mysql:5.7\n") {
+		t.Errorf("expected the definition on the standard output, got %q (%v)", output, err)
+	}
+
+	filename := writeFile(t, "stack.yml", "")
+	if _, err := captureOutput(func() error { return StackExport("javajersey", filename) }); err != nil {
+		t.Fatal(err)
+	}
+	if output, err := captureOutput(func() error { return StackDiff("javajersey", filename) }); err != nil {
+		t.Errorf("expected the exported definition to match the stack, got %q (%v)", output, err)
+	}
+	if _, err := captureOutput(func() error { return StackUpdate(id, filename) }); err != nil {
+		t.Errorf("expected the exported definition to be accepted by stacks:update, got %v", err)
+	}
+	if stack, _ := fake.Stack(id); stack["description"] != "Jersey with MySQL" {
+		t.Errorf("unexpected updated stack %v", stack)
+	}
+
+	if _, err := captureOutput(func() error { return StackExport("unknown", "") }); err == nil {
+		t.Error("expected exporting an unknown stack to fail")
+	}
+}
+
+func TestNameOrIdResolution(t *testing.T) {
+	fake, teardown := startController(t)
+	defer teardown()
+
+	stack := fake.AddStack(controller.Document{"name": "javajersey"})
+	twins := []string{fake.AddStack(controller.Document{"name": "twin"}), fake.AddStack(controller.Document{"name": "twin"})}
+	up := fake.AddUp("java-up", "BUILD")
+	provider := fake.AddProvider("local", "MARATHON")
+	cluster := fake.AddCluster("dev", "MARATHON", "http://marathon.local")
+
+	if _, err := captureOutput(func() error { return StackPublish("javajersey") }); err != nil {
+		t.Fatal(err)
+	}
+	if document, _ := fake.Stack(stack); document["status"] != "PUBLISHED" {
+		t.Errorf("expected the stack to be published by name, got %v", document["status"])
+	}
+	if _, err := captureOutput(func() error { return StackUnPublish(stack) }); err != nil {
+		t.Fatal(err)
+	}
+	if document, _ := fake.Stack(stack); document["status"] != "UNPUBLISHED" {
+		t.Errorf("expected the stack to be unpublished by id, got %v", document["status"])
+	}
+
+	cases := []struct {
+		run func() error
+		err string
+	}{
+		{run: func() error { return StackRemove("unknown") }, err: "stack unknown not found"},
+		{run: func() error { return StackPublish("twin") }, err: "stack name twin is ambiguous, it matches the ids " + strings.Join(twins, ", ") + ", use one of them instead"},
+		{run: func() error { return UpPublish("java-up") }},
+		{run: func() error { return UpsInfo(up) }},
+		{run: func() error { return UpDeprecate("unknown") }, err: "unified procedure unknown not found"},
+		{run: func() error { return GetProviderByName(provider) }},
+		{run: func() error { return GetProviderByName("remote") }, err: "provider remote not found"},
+		{run: func() error { return GetCluster("unknown") }, err: "cluster unknown not found"},
+		{run: func() error { return ClusterUpdate("dev", "", "", "http://marathon.dev") }},
+		{run: func() error { return ClusterRemove(cluster) }},
+	}
+	for i, c := range cases {
+		_, err := captureOutput(c.run)
+		if c.err == "" && err != nil || c.err != "" && (err == nil || err.Error() != c.err) {
+			t.Errorf("case %d: got error %v, want %q", i, err, c.err)
+		}
+	}
+
+	if !sent(fake, "PUT /ups/"+up+"/publish") {
+		t.Errorf("expected the unified procedure to be published by name, got %v", fake.Requests())
+	}
+	if !sent(fake, "PUT /clusters/"+cluster) {
+		t.Errorf("expected the cluster to be updated by name, got %v", fake.Requests())
+	}
+	if _, ok := fake.Cluster(cluster); ok {
+		t.Error("expected the cluster to be removed by id")
+	}
+
+	// names are looked up in every page
+	fake.SetPageSize(1)
+	fake.AddCluster("production", "MARATHON", "http://marathon.production")
+	staging := fake.AddCluster("staging", "MARATHON", "http://marathon.staging")
+	if _, err := captureOutput(func() error { return ClusterUpdate("staging", "", "", "http://marathon.stage") }); err != nil || !sent(fake, "PUT /clusters/"+staging) {
+		t.Errorf("expected the cluster of the second page to be updated by name, got %v", err)
+	}
+	if _, err := captureOutput(func() error { return StackPublish("twin") }); err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Errorf("expected the stacks of every page to be matched, got %v", err)
+	}
+
+	// a failed name look up is reported as is
+	fake.FailNext(1, http.StatusInternalServerError)
+	if _, err := captureOutput(func() error { return GetStack("javajersey") }); err == nil || strings.Contains(err.Error(), "not found") {
+		t.Errorf("expected the failure of the controller to be reported, got %v", err)
+	}
+}
+
+func TestStackVersions(t *testing.T) {
+	fake, teardown := startController(t)
+	defer teardown()
+	defer inGitRepository(t)()
+
+	id := fake.AddStack(controller.Document{"name": "javajersey", "services": map[string]interface{}{
+		"main": map[string]interface{}{"build": map[string]interface{}{"image": "jersey-build:1"}},
+	}})
+	if _, err := captureOutput(func() error { return AppCreate("hello", "javajersey", "", "", "", "0") }); err != nil {
+		t.Fatal(err)
+	}
+
+	filename := writeFile(t, "stack.yml", `
+name: javajersey
+services:
+  main:
+    build:
+      image: jersey-build:2
+`)
+	output, err := captureOutput(func() error { return StackUpdate("javajersey", filename) })
+	if err != nil || !strings.Contains(output, "to version 2") {
+		t.Fatalf("expected stacks:update to create version 2, got %q (%v)", output, err)
+	}
+	if !sent(fake, "POST /stacks/"+id+"/versions") || sent(fake, "PUT /stacks/"+id) {
+		t.Errorf("expected the stack to be versioned rather than updated in place, got %v", fake.Requests())
+	}
+
+	// the versions come in several pages, oldest first
+	fake.SetPageSize(1)
+	output, err = captureOutput(func() error { return StackVersions("javajersey") })
+	if err != nil || !strings.Contains(output, "javajersey Versions [2]") || !regexp.MustCompile(`(?s)\|\s+2\s+\|.*\|\s+\*\s+\|.*\|\s+1\s+\|`).MatchString(output) {
+		t.Errorf("expected both versions newest first with version 2 the latest, got %q (%v)", output, err)
+	}
+
+	one, seven := 1, 7
+	if _, err := captureOutput(func() error { return SwitchStack("hello", "", &one) }); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := captureOutput(func() error { return StackUpdate(id, filename) }); err != nil {
+		t.Fatal(err)
+	}
+	app, _ := fake.App("hello")
+	for _, link := range app["links"].([]interface{}) {
+		if link := link.(controller.Document); link["rel"] == "stack" && link["uri"] != "/stacks/"+id+"/versions/1" {
+			t.Errorf("expected the app to be pinned to version 1, got %v", link["uri"])
+		}
+	}
+	output, err = captureOutput(func() error { return GetApp("hello") })
+	if err != nil || !regexp.MustCompile(`Stack Version\s+\|\s+1\s`).MatchString(output) {
+		t.Errorf("expected the app to keep using version 1, got %q (%v)", output, err)
+	}
+
+	err = SwitchStack("hello", "javajersey", &seven)
+	if err == nil || !strings.Contains(err.Error(), "Stack 'javajersey' has no version 7") {
+		t.Errorf("expected an unknown version to be rejected, got %v", err)
+	}
+	if _, err := captureOutput(func() error { return SwitchStack("hello", "", nil) }); err != nil {
+		t.Fatal(err)
+	}
+	output, _ = captureOutput(func() error { return GetApp("hello") })
+	if !regexp.MustCompile(`Stack Version\s+\|\s+3\s`).MatchString(output) {
+		t.Errorf("expected the app to follow the latest version again, got %q", output)
+	}
+}
+
+func TestStackUpdateWithoutVersions(t *testing.T) {
+	fake, teardown := startController(t)
+	defer teardown()
+	defer inGitRepository(t)()
+
+	fake.DisableStackVersions()
+	id := fake.AddStack(controller.Document{"name": "javajersey", "services": map[string]interface{}{
+		"main": map[string]interface{}{"build": map[string]interface{}{"image": "jersey-build:1"}},
+	}})
+	filename := writeFile(t, "stack.yml", `
+name: javajersey
+services:
+  main:
+    build:
+      image: jersey-build:2
+`)
+	output, err := captureOutput(func() error { return StackUpdate("javajersey", filename) })
+	if err != nil || !strings.Contains(output, "updated stack javajersey with uuid "+id+"\n") {
+		t.Fatalf("expected stacks:update to update the stack in place, got %q (%v)", output, err)
+	}
+	if !sent(fake, "PUT /stacks/"+id) {
+		t.Errorf("expected the stack to be updated with PUT, got %v", fake.Requests())
+	}
+	stack, _ := fake.Stack(id)
+	if !strings.Contains(fmt.Sprint(stack["services"]), "jersey-build:2") {
+		t.Errorf("expected the stack to hold the new definition, got %v", stack["services"])
+	}
+
+	if err := StackVersions("javajersey"); err == nil || !strings.Contains(err.Error(), "keeps no versions") {
+		t.Errorf("expected stacks:versions to report the controller keeps no versions, got %v", err)
+	}
+}
+
+func TestAppCommands(t *testing.T) {
+	fake, teardown := startController(t)
+	defer teardown()
+	defer inGitRepository(t)()
+
+	fake.AddStack(controller.Document{"name": "javajersey"})
+
+	if _, err := captureOutput(func() error { return AppCreate("hello", "javajersey", "", "", "", "0") }); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fake.App("hello"); !ok {
+		t.Fatal("expected app hello to be created")
+	}
+
+	output, err := captureOutput(AppsList)
+	if err != nil || !strings.Contains(output, "id: hello") {
+		t.Errorf("expected hello to be listed, got %q (%v)", output, err)
+	}
+
+	output, err = captureOutput(func() error { return GetApp("hello") })
+	if err != nil || !strings.Contains(output, "javajersey") {
+		t.Errorf("expected the app description with its stack, got %q (%v)", output, err)
+	}
+
+	if _, err := captureOutput(func() error { return DestroyApp("hello") }); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fake.App("hello"); ok {
+		t.Error("expected app hello to be destroyed")
+	}
+}
+
+func TestUpAndProviderCommands(t *testing.T) {
+	fake, teardown := startController(t)
+	defer teardown()
+
+	fake.AddUp("javajersey-up", "BUILD", "RUN")
+	fake.AddProvider("local", "DOCKER")
+
+	output, err := captureOutput(UpsList)
+	if err != nil || !strings.Contains(output, "name: javajersey-up") {
+		t.Errorf("expected the up to be listed, got %q (%v)", output, err)
+	}
+	output, err = captureOutput(func() error { return UpsInfo("javajersey-up") })
+	if err != nil || !strings.Contains(output, "BUILD") {
+		t.Errorf("expected the build procedure of the up, got %q (%v)", output, err)
+	}
+
+	output, err = captureOutput(ProviderList)
+	if err != nil || !strings.Contains(output, "local") {
+		t.Errorf("expected the provider to be listed, got %q (%v)", output, err)
+	}
+	if _, err := captureOutput(func() error { return GetProviderByName("missing") }); err == nil {
+		t.Error("expected an unknown provider to be reported")
+	}
+}
+
+func TestDeploymentAndRollback(t *testing.T) {
+	fake, teardown := startController(t)
+	defer teardown()
+	defer inGitRepository(t)()
+
+	fake.AddUp("javajersey-up", "BUILD", "RUN")
+	fake.AddProvider("local", "DOCKER")
+	if _, err := captureOutput(func() error { return AppCreate("hello", "", "javajersey-up", "local", "", "1") }); err != nil {
+		t.Fatal(err)
+	}
+
+	fake.AddRelease("hello")
+	previous := fake.AddRelease("hello")
+	current := fake.AddRelease("hello")
+
+	if _, err := captureOutput(func() error { return LaunchDeployment(current, "hello", "") }); err != nil {
+		t.Fatal(err)
+	}
+	if deployment, ok := fake.Deployment("hello"); !ok || deployment["releaseVersion"] != "registry/hello:v"+current {
+		t.Fatalf("expected release %s to be deployed, got %v", current, deployment)
+	}
+
+	// the releases come in several pages, oldest first
+	fake.SetPageSize(1)
+	if _, err := captureOutput(func() error { return ReleaseRollback("hello", "", "") }); err != nil {
+		t.Fatal(err)
+	}
+	if deployment, _ := fake.Deployment("hello"); deployment["releaseVersion"] != "registry/hello:v"+previous {
+		t.Errorf("expected release %s to be deployed after rollback, got %v", previous, deployment["releaseVersion"])
+	}
+
+	fake.SetProcedureStatus("FAILED")
+	if _, err := captureOutput(func() error { return LaunchDeployment(current, "hello", "") }); err == nil {
+		t.Error("expected a failed deployment to be reported")
+	}
+}
+
+func TestLaunchBuild(t *testing.T) {
+	fake, teardown := startController(t)
+	defer teardown()
+	defer inGitRepository(t)()
+
+	fake.AddStack(controller.Document{"name": "javajersey"})
+	if _, err := captureOutput(func() error { return AppCreate("hello", "javajersey", "", "", "", "0") }); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"main.go":     "package main",
+		"docs/README": "docs",
+		".gitignore":  "*.log\n",
+		".cdeignore":  "secrets/\n",
+		"build.log":   "left out by .gitignore",
+		"secrets/key": "left out by .cdeignore",
+	}
+	for name, content := range files {
+		os.MkdirAll(filepath.Dir(name), 0755)
+		if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := captureOutput(func() error { return LaunchBuild("", "hello", "abc123", "", 1) }); err != nil {
+		t.Fatal(err)
+	}
+	archive, err := gzip.NewReader(bytes.NewReader(fake.Upload()))
+	if err != nil {
+		t.Fatalf("expected a tar.gz to be uploaded: %v", err)
+	}
+	var names []string
+	for reader := tar.NewReader(archive); ; {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if content, _ := ioutil.ReadAll(reader); string(content) != files[header.Name] {
+			t.Errorf("expected %s to hold %q, got %q", header.Name, files[header.Name], content)
+		}
+		names = append(names, header.Name)
+	}
+	sort.Strings(names)
+	if expected := []string{".cdeignore", ".gitignore", "docs/README", "main.go"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected the archive to hold %v, got %v", expected, names)
+	}
+	if !sent(fake, "POST /apps/hello/builds") {
+		t.Errorf("expected a build to be created, got %v", fake.Requests())
+	}
+
+	// sources over the limit are refused before uploading anything
+	if err := ioutil.WriteFile("large.bin", make([]byte, 1<<20+1), 0644); err != nil {
+		t.Fatal(err)
+	}
+	uploads := strings.Count(strings.Join(fake.Requests(), "\n"), "POST /files")
+	for _, filename := range []string{"", "large.bin"} {
+		err := LaunchBuild(filename, "hello", "abc123", "", 1)
+		if err == nil || !strings.Contains(err.Error(), "more than the upload limit of 1.0 MB") {
+			t.Errorf("expected %q to be over the upload limit, got %v", filename, err)
+		}
+	}
+	if count := strings.Count(strings.Join(fake.Requests(), "\n"), "POST /files"); count != uploads {
+		t.Errorf("expected nothing to be uploaded over the limit, got %d uploads", count-uploads)
+	}
+	os.Remove("large.bin")
+
+	fake.FailNext(1, http.StatusRequestEntityTooLarge)
+	if _, err := captureOutput(func() error { return LaunchBuild("", "hello", "abc123", "", 1) }); err == nil || !strings.Contains(err.Error(), "upload source failed: 413") {
+		t.Errorf("expected the rejected upload to be reported, got %v", err)
+	}
+}
+
+func TestDeploymentLog(t *testing.T) {
+	fake, teardown := startController(t)
+	defer teardown()
+	defer inGitRepository(t)()
+
+	fake.AddUp("javajersey-up", "BUILD", "RUN")
+	fake.AddProvider("local", "DOCKER")
+	if _, err := captureOutput(func() error { return AppCreate("hello", "", "javajersey-up", "local", "", "1") }); err != nil {
+		t.Fatal(err)
+	}
+	release := fake.AddRelease("hello")
+
+	if _, err := captureOutput(func() error { return LaunchDeployment(release, "hello", "") }); err != nil {
+		t.Errorf("expected a deployment without log link to be waited for, got %v", err)
+	}
+
+	// the log spans several pages
+	var log []string
+	for i := 0; i < 2*logPageSize+10; i++ {
+		log = append(log, fmt.Sprintf("line %d", i))
+	}
+	fake.SetLog(log)
+	output, err := captureOutput(func() error { return LaunchDeployment(release, "hello", "") })
+	if err != nil || !strings.Contains(output, strings.Join(log, "\n")+"\n") {
+		t.Errorf("expected the whole log of the deployment, got %q (%v)", output, err)
+	}
+}
+
+func TestFollowProcedure(t *testing.T) {
+	// the log grows by a page and a half at every poll, the procedure ends at the third
+	var log []string
+	polls := 0
+	poll := func() (bool, error) {
+		for i := 0; i < logPageSize*3/2; i++ {
+			log = append(log, fmt.Sprintf("line %d", len(log)))
+		}
+		polls++
+		return polls == 3, nil
+	}
+	var offsets []int64
+	fetch := func(offset int64) (api.LogsModel, error) {
+		offsets = append(offsets, offset)
+		end := offset + logPageSize
+		if end > int64(len(log)) {
+			end = int64(len(log))
+		}
+		var logs api.LogsModel
+		for _, line := range log[offset:end] {
+			logs.ItemsField = append(logs.ItemsField, api.LogItemsModel{MessageField: line})
+		}
+		return logs, nil
+	}
+
+	output, err := captureOutput(func() error { return followProcedure(poll, fetch) })
+	if err != nil || output != strings.Join(log, "\n")+"\n" {
+		t.Errorf("expected every line printed once in order, got %d lines (%v)", strings.Count(output, "\n"), err)
+	}
+	page := int64(logPageSize)
+	if expected := []int64{0, page, page * 3 / 2, page * 5 / 2, page * 3, page * 4}; !reflect.DeepEqual(offsets, expected) {
+		t.Errorf("expected the log to be read from offsets %v, got %v", expected, offsets)
+	}
+
+	if err := followProcedure(func() (bool, error) { return true, errors.New("Build fail") }, noLogFetcher); err == nil {
+		t.Error("expected the failure of the procedure to be returned")
+	}
+}
+
+func TestReleaseCommands(t *testing.T) {
+	fake, teardown := startController(t)
+	defer teardown()
+	defer inGitRepository(t)()
+
+	fake.AddStack(controller.Document{"name": "javajersey"})
+	if _, err := captureOutput(func() error { return AppCreate("hello", "javajersey", "", "", "", "0") }); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReleaseRollback("hello", "", ""); err == nil || !strings.Contains(err.Error(), "no previous successful release") {
+		t.Errorf("expected an app without releases not to be rolled back, got %v", err)
+	}
+	first := fake.AddRelease("hello")
+	if err := ReleaseRollback("hello", "", ""); err == nil || !strings.Contains(err.Error(), "no previous successful release") {
+		t.Errorf("expected the only successful release not to be rolled back from, got %v", err)
+	}
+	second := fake.AddRelease("hello")
+	third := fake.AddRelease("hello")
+
+	// the releases come in several pages, every one of them is listed
+	fake.SetPageSize(1)
+	output, err := captureOutput(func() error { return ReleasesList("hello") })
+	if err != nil || !strings.Contains(output, "hello Releases [3]") {
+		t.Fatalf("expected the three releases to be listed, got %q (%v)", output, err)
+	}
+	for _, id := range []string{first, second, third} {
+		if !regexp.MustCompile(`\sv` + id + `\s`).MatchString(output) {
+			t.Errorf("expected release %s to be listed, got %q", id, output)
+		}
+	}
+
+	output, err = captureOutput(func() error { return ReleaseInfo("hello", second) })
+	if err != nil || !strings.Contains(output, "v"+second) || !strings.Contains(output, "SUCCESS") {
+		t.Errorf("expected the details of release %s, got %q (%v)", second, output, err)
+	}
+}
+
+func TestBuildCommands(t *testing.T) {
+	fake, teardown := startController(t)
+	defer teardown()
+	defer inGitRepository(t)()
+
+	fake.AddStack(controller.Document{"name": "javajersey"})
+	if _, err := captureOutput(func() error { return AppCreate("hello", "javajersey", "", "", "", "0") }); err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, sha := range []string{"aaa", "bbb", "ccc"} {
+		ids = append(ids, fake.AddBuild("hello", sha))
+	}
+	listed := regexp.MustCompile(`\|\s+(\d+)\s+\|\s+[a-z]{3}\s+\|\s+SUCCESS\s`)
+
+	fake.SetPageSize(2)
+	for _, test := range []struct {
+		page     int
+		all      bool
+		expected []string
+		more     bool
+	}{
+		{1, false, ids[:2], true},
+		{2, false, ids[2:], false},
+		{1, true, ids, false},
+		{2, true, ids[2:], false},
+	} {
+		output, err := captureOutput(func() error { return BuildsList("hello", test.page, test.all) })
+		if err != nil || !strings.Contains(output, "hello Builds [3]") {
+			t.Errorf("expected page %d (all %v) to be listed, got %q (%v)", test.page, test.all, output, err)
+			continue
+		}
+		var got []string
+		for _, match := range listed.FindAllStringSubmatch(output, -1) {
+			got = append(got, match[1])
+		}
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("expected page %d (all %v) to list builds %v, got %v", test.page, test.all, test.expected, got)
+		}
+		if more := strings.Contains(output, "more builds available, use --page"); more != test.more {
+			t.Errorf("expected page %d (all %v) to hint at more builds: %v, got %q", test.page, test.all, test.more, output)
+		}
+	}
+	if err := BuildsList("hello", 3, false); err == nil || !strings.Contains(err.Error(), "page 3 is out of range") {
+		t.Errorf("expected page 3 to be out of range, got %v", err)
+	}
+
+	output, err := captureOutput(func() error { return BuildInfo("hello", ids[1]) })
+	if err != nil || !strings.Contains(output, "bbb") || !strings.Contains(output, "SUCCESS") || !strings.Contains(output, "Created At") {
+		t.Errorf("expected the details of build %s, got %q (%v)", ids[1], output, err)
+	}
+}
+
+func TestEventCommands(t *testing.T) {
+	fake, teardown := startController(t)
+	defer teardown()
+
+	var ids []string
+	for _, app := range []string{"other", "other", "hello"} {
+		ids = append(ids, fake.AddEvent("BUILD", app))
+	}
+	fake.AddEvent("RELEASE", "hello")
+
+	// the event of hello is past the first page
+	fake.SetPageSize(2)
+	output, err := captureOutput(func() error { return EventsList("BUILD", "hello", false) })
+	if err != nil || !strings.Contains(output, "Events [1]") || !regexp.MustCompile(`\|\s+`+ids[2]+`\s+\|\s+BUILD\s+\|\s+hello\s`).MatchString(output) {
+		t.Errorf("expected the build event of hello to be listed, got %q (%v)", output, err)
+	}
+
+	feed := newEventFeed(net.NewCloudControllerGateway(config.NewConfigRepository(func(error) {})), "BUILD")
+	polled := func() (polled []string) {
+		events, err := feed.poll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, event := range events {
+			polled = append(polled, event.ID())
+		}
+		return
+	}
+	if events := polled(); !reflect.DeepEqual(events, ids) {
+		t.Errorf("expected the first poll to return %v, got %v", ids, events)
+	}
+	// the new events fill the last page and start another one
+	added := []string{fake.AddEvent("BUILD", "hello"), fake.AddEvent("BUILD", "hello")}
+	fake.AddEvent("RELEASE", "hello")
+	if events := polled(); !reflect.DeepEqual(events, added) {
+		t.Errorf("expected the next poll to return the new events %v, got %v", added, events)
+	}
+	if events := polled(); len(events) != 0 {
+		t.Errorf("expected no event without new ones, got %v", events)
+	}
+}
+
+func TestRetriesOverloadedController(t *testing.T) {
+	fake, teardown := startController(t)
+	defer teardown()
+
+	defer keepHTTPClients()()
+	os.Setenv("CDE_HTTP_BACKOFF", "1ms")
+	defer os.Unsetenv("CDE_HTTP_BACKOFF")
+	ConfigureHTTP(config.NewConfigRepository(func(error) {}))
+
+	fake.FailNext(2, http.StatusServiceUnavailable)
+	if _, err := captureOutput(AppsList); err != nil {
+		t.Errorf("expected the listing to be retried, got %v", err)
+	}
+
+	fake.FailNext(config.DefaultHTTPRetries+1, http.StatusServiceUnavailable)
+	if _, err := captureOutput(AppsList); err == nil {
+		t.Error("expected the listing to fail once the retries are exhausted")
+	}
+}
+
+func TestPasswdAndCancel(t *testing.T) {
+	fake, teardown := startController(t)
+	defer teardown()
+
+	if _, err := captureOutput(func() error { return Passwd("wrong", "changed") }); err == nil {
+		t.Error("expected a wrong current password to be rejected")
+	}
+	if _, err := captureOutput(func() error { return Passwd(testPassword, "changed") }); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := captureOutput(func() error { return Login(fake.URL, testEmail, testPassword, httpclient.TLSOptions{}) }); err == nil {
+		t.Error("expected the previous password to be rejected")
+	}
+
+	if _, err := captureOutput(func() error { return Cancel("", testPassword, true) }); err == nil {
+		t.Error("expected cancelling with a wrong password to fail")
+	}
+	if _, err := captureOutput(func() error { return Cancel("", "changed", true) }); err != nil {
+		t.Fatal(err)
+	}
+	if configRepository := config.NewConfigRepository(func(error) {}); configRepository.Auth() != "" || configRepository.Email() != "" {
+		t.Errorf("expected the session of the cancelled account to be cleared, got %s", configRepository.Email())
+	}
+	if _, err := captureOutput(func() error { return Login(fake.URL, testEmail, "changed", httpclient.TLSOptions{}) }); err == nil {
+		t.Error("expected the cancelled account not to log in")
+	}
+}
+
+func TestNonInteractiveCredentials(t *testing.T) {
+	fake, teardown := startController(t)
+	defer teardown()
+	token := config.NewConfigRepository(func(error) {}).Auth()
+
+	os.Setenv("CDE_EMAIL", testEmail)
+	os.Setenv("CDE_PASSWORD", testPassword)
+	defer os.Unsetenv("CDE_EMAIL")
+	defer os.Unsetenv("CDE_PASSWORD")
+	output, err := captureOutput(func() error { return Login(fake.URL, "", "", httpclient.TLSOptions{}) })
+	if err != nil || !strings.Contains(output, "Welcome "+testEmail) {
+		t.Errorf("expected the credentials of the environment to log in, got %q (%v)", output, err)
+	}
+	os.Unsetenv("CDE_EMAIL")
+	os.Unsetenv("CDE_PASSWORD")
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdin := os.Stdin
+	os.Stdin = reader
+	writer.WriteString(testPassword + "\n")
+	writer.Close()
+	password, err := PasswordFromStdin()
+	os.Stdin = stdin
+	if err != nil || password != testPassword {
+		t.Errorf("expected the password of stdin, got %q (%v)", password, err)
+	}
+
+	// a pre-issued token needs no login and leaves config.json alone
+	home, err := ioutil.TempDir("", "cde-home")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+	os.Setenv("CDE_HOME", home)
+	os.Setenv("CDE_TOKEN", token)
+	os.Setenv("CDE_ENDPOINT", fake.URL)
+	defer os.Unsetenv("CDE_TOKEN")
+	defer os.Unsetenv("CDE_ENDPOINT")
+
+	if _, err := captureOutput(AppsList); err != nil {
+		t.Errorf("expected CDE_TOKEN to authenticate the commands, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(home, ".cde")); !os.IsNotExist(err) {
+		t.Errorf("expected no config to be written, got %v", err)
+	}
+	if _, err := captureOutput(func() error { return Login(fake.URL, testEmail, testPassword, httpclient.TLSOptions{}) }); err == nil {
+		t.Error("expected login to be refused while CDE_TOKEN is set")
+	}
+	if _, err := captureOutput(func() error { return Regenerate(testPassword) }); err == nil {
+		t.Error("expected the token not to be regenerated while CDE_TOKEN is set")
+	}
+	if _, err := captureOutput(AppsList); err != nil {
+		t.Errorf("expected CDE_TOKEN to stay valid, got %v", err)
+	}
+
+	// the user of the token is asked to the controller
+	if _, err := captureOutput(func() error { return Passwd(testPassword, "changed") }); err != nil {
+		t.Errorf("expected CDE_TOKEN to change the password of its user, got %v", err)
+	}
+	if _, err := captureOutput(func() error { return Cancel("", "changed", true) }); err != nil {
+		t.Errorf("expected CDE_TOKEN to cancel the account of its user, got %v", err)
+	}
+}
+
+func TestDevExport(t *testing.T) {
+	fake, teardown := startController(t)
+	defer teardown()
+	defer inGitRepository(t)()
+
+	fake.AddStack(controller.Document{"name": "javajersey", "services": map[string]interface{}{
+		"main": map[string]interface{}{"build": map[string]interface{}{"image": "jersey-build"}, "links": []string{"db"}},
+		"db":   map[string]interface{}{"image": "mysql", "expose": 3306},
+	}})
+	if _, err := captureOutput(func() error { return AppCreate("hello", "javajersey", "", "", "", "0") }); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := captureOutput(func() error { return DevExport("k8s", "-", false) })
+	if err != nil || !strings.Contains(output, "name: hello-main") || !strings.Contains(output, "DB_HOST: hello-db") {
+		t.Errorf("expected the manifests of the app, got %q (%v)", output, err)
+	}
+
+	if _, err := captureOutput(func() error { return DevExport("compose", "", false) }); err != nil {
+		t.Fatal(err)
+	}
+	if content, err := ioutil.ReadFile("docker-compose.yml"); err != nil || !strings.Contains(string(content), "image: jersey-build") {
+		t.Errorf("expected docker-compose.yml to run the build image, got %q (%v)", content, err)
+	}
+	if err := DevExport("compose", "", false); err == nil {
+		t.Error("expected an existing docker-compose.yml not to be overwritten")
+	}
+	if _, err := captureOutput(func() error { return DevExport("compose", "", true) }); err != nil {
+		t.Errorf("expected --force to overwrite docker-compose.yml: %v", err)
+	}
+	if _, err := captureOutput(func() error { return DevExport("compose", "docker-compose.yml", false) }); err != nil {
+		t.Errorf("expected an explicit file to be overwritten: %v", err)
+	}
+
+	if err := DevExport("swarm", "", false); err == nil {
+		t.Error("expected an unknown format to be reported")
+	}
+}
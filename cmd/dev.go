@@ -1,358 +1,332 @@
 package cmd
 
 import (
-	"bytes"
-	"errors"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"net/url"
 	"os"
 	"os/exec"
-	"path/filepath"
-	"regexp"
-	"strconv"
+	"sort"
 	"strings"
-	"time"
 
+	"github.com/cnupp/appssdk/api"
+	"github.com/cnupp/appssdk/net"
+	"github.com/cnupp/cli/backend"
 	"github.com/cnupp/cli/backend/compose"
+	"github.com/cnupp/cli/backend/docker"
+	"github.com/cnupp/cli/backend/k8s"
 	"github.com/cnupp/cli/config"
 	"github.com/cnupp/cli/pkg"
-	"github.com/cnupp/appssdk/api"
-	"github.com/cnupp/appssdk/net"
+	"github.com/olekukonko/tablewriter"
 )
 
-func DevUp() error {
+// DevBackendEnv selects the runtime of the dev env, compose or docker. docker-compose is
+// used when it is installed, the Docker Engine API otherwise.
+const DevBackendEnv = "CDE_DEV_BACKEND"
+
+var devRuntimes = map[string]func() backend.Runtime{
+	"compose": compose.NewComposeBackend,
+	"docker":  docker.NewDockerBackend,
+}
+
+func devRuntime() (backend.Runtime, error) {
+	name := os.Getenv(DevBackendEnv)
+	if name == "" {
+		name = "docker"
+		if _, err := exec.LookPath("docker-compose"); err == nil {
+			name = "compose"
+		}
+	}
+	runtime, ok := devRuntimes[name]
+	if !ok {
+		return nil, fmt.Errorf("Unknown %s %s, use compose or docker", DevBackendEnv, name)
+	}
+	return runtime(), nil
+}
 
+// devProject returns the stack of the app of the current directory, run by the selected
+// runtime.
+func devProject() (backend.Runtime, backend.Project, error) {
+	project, err := appProject()
+	if err != nil {
+		return nil, backend.Project{}, err
+	}
+
+	runtime, err := devRuntime()
+	if err != nil {
+		return nil, backend.Project{}, err
+	}
+	return runtime, project, nil
+}
+
+// appProject returns the stack of the app of the current directory.
+func appProject() (backend.Project, error) {
 	if !git.IsGitDirectory() {
-		return fmt.Errorf("Execute inside the app dir")
+		return backend.Project{}, fmt.Errorf("Execute inside the app dir")
 	}
 
 	configRepository := config.NewConfigRepository(func(error) {})
 	appRepository := api.NewAppRepository(configRepository,
 		net.NewCloudControllerGateway(configRepository))
 	uri, err := url.Parse(configRepository.Endpoint())
+	if err != nil {
+		return backend.Project{}, err
+	}
 	appId, err := git.DetectAppName(uri.Host)
-
 	if err != nil || appId == "" {
-		return fmt.Errorf("Please use the -remote to specfiy the app")
+		return backend.Project{}, fmt.Errorf("Please use the -remote to specfiy the app")
 	}
 
 	app, err := appRepository.GetApp(appId)
 	if err != nil {
-		return err
+		return backend.Project{}, err
 	}
-
 	stack, err := app.GetStack()
 	if err != nil {
-		return err
+		return backend.Project{}, err
 	}
-
-	f, err := toCompose(stack)
+	dir, err := os.Getwd()
 	if err != nil {
-		return err
+		return backend.Project{}, err
 	}
+	return backend.Project{Name: app.Name(), Stack: stack, Dir: dir}, nil
+}
 
-	dockerComposeCreate := exec.Command("docker-compose", "-f", f, "-p", app.Name(), "up", "-d")
-	startOutput, err := CmdStart(dockerComposeCreate)
+func DevUp() error {
+	runtime, project, err := devProject()
 	if err != nil {
-		fmt.Println(err)
 		return err
 	}
-	io.Copy(os.Stdout, startOutput)
-	err = dockerComposeCreate.Wait()
-	if err != nil {
-		fmt.Println(err)
+
+	if err := runtime.Up(project); err != nil {
 		return err
 	}
-
-	for i := 0; i < 100; i++ {
-		_, err := Pipe(
-			exec.Command("docker-compose", "-f", f, "-p", app.Name(), "ps"),
-			exec.Command("grep", "-E", app.Name()),
-			exec.Command("/bin/sh", "-c", "grep -v Up"))
-
-		// All the containers are started (All containers is Up, all the lines is with Up, so the grep return error.)
-		if err != nil {
-			break
-		} else {
-			fmt.Println("starting...")
-			time.Sleep(1 * time.Second)
-		}
+	if err := backend.WaitHealthy(runtime, project); err != nil {
+		return fmt.Errorf("The dev env is not ready, see cde dev:logs <service>:\n%v", err)
 	}
 
-	containerId := func() string {
-		psOutput, err := exec.Command("docker-compose", "-f", f, "-p", app.Name(), "ps", "-q", "runtime").Output()
-		if err != nil {
-			panic(fmt.Sprintf("Can not find the proper container id: cause %v", err))
-		}
-		return strings.TrimSpace(string(psOutput))
-	}
+	_, err = runtime.Exec(project, backend.RuntimeService,
+		[]string{"bash", "-c", fmt.Sprintf("cd %s; exec ${SHELL:-bash}", backend.CodebasePath)},
+		backend.StandardStreams())
+	return err
+}
 
-	dockerExec := exec.Command("docker", "exec", "-it", containerId(), "bash", "-c", "cd /codebase; exec ${SHELL:-bash}")
-	dockerExec.Stdin = os.Stdin
-	dockerExec.Stderr = os.Stderr
-	dockerExec.Stdout = os.Stdout
-	err = dockerExec.Run()
+func DevDown() error {
+	runtime, project, err := devProject()
 	if err != nil {
 		return err
 	}
-
-	return nil
+	return runtime.Down(project)
 }
 
-func toCompose(stack api.Stack) (string, error) {
-	composeBackend := compose.NewComposeBackend()
-	composeContent := composeBackend.ToComposeFile(stack)
-
-	dir, err := filepath.Abs(filepath.Dir(os.Args[0]))
-	if err != nil {
-		panic(fmt.Sprintf("Please ensure the current dir can be accessed. %v", err))
-	}
-	os.MkdirAll(filepath.Join(dir, ".local"), 0766)
-
-	err = ioutil.WriteFile(".local/dockercompose.yml", []byte(composeContent), 0644)
+func DevDestroy() error {
+	runtime, project, err := devProject()
 	if err != nil {
-		return "", err
+		return err
 	}
 
-	return ".local/dockercompose.yml", nil
-}
-
-func DevDown() error {
-	if !git.IsGitDirectory() {
-		return fmt.Errorf("Execute inside the app dir")
+	if err := runtime.Destroy(project); err != nil {
+		return err
 	}
 
-	configRepository := config.NewConfigRepository(func(error) {})
-	appRepository := api.NewAppRepository(configRepository,
-		net.NewCloudControllerGateway(configRepository))
-	uri, err := url.Parse(configRepository.Endpoint())
-	appId, err := git.DetectAppName(uri.Host)
-
-	if err != nil || appId == "" {
-		return fmt.Errorf("Please use the -remote to specfiy the app")
+	if err := os.RemoveAll(project.LocalDir()); err != nil {
+		return fmt.Errorf("Error when remove the local dir .local %v", err)
 	}
+	return nil
+}
 
-	app, err := appRepository.GetApp(appId)
+func DevEnv() error {
+	runtime, project, err := devProject()
 	if err != nil {
 		return err
 	}
 
-	stack, err := app.GetStack()
+	env, err := runtime.Env(project)
 	if err != nil {
 		return err
 	}
 
-	f, err := toCompose(stack)
-	if err != nil {
-		return err
+	var names []string
+	for name := range env {
+		names = append(names, name)
 	}
-
-	dockerComposeUp := exec.Command("docker-compose", "-f", f, "-p", app.Name(), "stop")
-
-	var out bytes.Buffer
-	var errout bytes.Buffer
-	dockerComposeUp.Stdout = &out
-	dockerComposeUp.Stderr = &errout
-	fi, err := CmdStart(dockerComposeUp)
-
-	io.Copy(os.Stdout, fi)
-	err = dockerComposeUp.Wait()
-	if err != nil {
-		fmt.Println(err)
-		return err
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("export %s=%s;\n", name, env[name])
 	}
 	return nil
 }
 
-func DevDestroy() error {
-	if !git.IsGitDirectory() {
-		return fmt.Errorf("Execute inside the app dir")
-	}
-
-	configRepository := config.NewConfigRepository(func(error) {})
-	appRepository := api.NewAppRepository(configRepository,
-		net.NewCloudControllerGateway(configRepository))
-	uri, err := url.Parse(configRepository.Endpoint())
-	appId, err := git.DetectAppName(uri.Host)
-
-	if err != nil || appId == "" {
-		return fmt.Errorf("Please use the -remote to specfiy the app")
+func exitError(code int, err error) error {
+	if err == nil && code != 0 {
+		return ExitError{Code: code}
 	}
+	return err
+}
 
-	app, err := appRepository.GetApp(appId)
-	if err != nil {
-		return err
+// commandStreams attaches one-off commands to the standard streams, without terminal.
+// Their input is only read when piped to the cli.
+func commandStreams() backend.Streams {
+	streams := backend.StandardStreams()
+	if streams.Terminal() {
+		streams.Stdin = nil
 	}
+	return streams
+}
 
-	stack, err := app.GetStack()
-	if err != nil {
-		return err
+// DevExec runs command from the app directory in the runtime service, and fails with an
+// ExitError when command does.
+func DevExec(command []string) error {
+	if len(command) == 0 {
+		return fmt.Errorf("Please provide the command to run")
 	}
-
-	f, err := toCompose(stack)
+	runtime, project, err := devProject()
 	if err != nil {
 		return err
 	}
 
-	dockerComposeUp := exec.Command("docker-compose", "-f", f, "-p", app.Name(), "down", "-v", "--remove-orphans", "--rmi", "all")
+	return exitError(runtime.Exec(project, backend.RuntimeService, backend.CodebaseCommand(command), commandStreams()))
+}
 
-	var out bytes.Buffer
-	var errout bytes.Buffer
-	dockerComposeUp.Stdout = &out
-	dockerComposeUp.Stderr = &errout
-	fi, err := CmdStart(dockerComposeUp)
-	if err != nil {
-		return err
+// DevRun runs command in a new container of service, once the dev env is up, and fails
+// with an ExitError when command does.
+func DevRun(service string, command []string) error {
+	if len(command) == 0 {
+		return fmt.Errorf("Please provide the command to run")
 	}
-	io.Copy(os.Stdout, fi)
-	err = dockerComposeUp.Wait()
+	runtime, project, err := devProject()
 	if err != nil {
-		fmt.Println(err)
 		return err
 	}
 
-	err = os.RemoveAll(".local")
+	statuses, err := runtime.Status(project)
 	if err != nil {
-		fmt.Println("Error when remove the local dir .local %v", err)
 		return err
 	}
-
-	fmt.Println(errout.String())
-	return nil
-}
-
-func DevEnv() error {
-	if !git.IsGitDirectory() {
-		return fmt.Errorf("Execute inside the app dir")
+	known := false
+	for _, status := range statuses {
+		known = known || status.Service == service
+		if !status.Running() {
+			return fmt.Errorf("Service %s is not running, start the dev env with cde dev:up", status.Service)
+		}
+	}
+	if !known {
+		return fmt.Errorf("The stack of %s has no service %s", project.Name, service)
 	}
 
-	configRepository := config.NewConfigRepository(func(error) {})
-	appRepository := api.NewAppRepository(configRepository,
-		net.NewCloudControllerGateway(configRepository))
-	uri, err := url.Parse(configRepository.Endpoint())
-	appId, err := git.DetectAppName(uri.Host)
-
-	if err != nil || appId == "" {
-		return fmt.Errorf("Please use the -remote to specfiy the app")
+	if service == backend.RuntimeService {
+		command = backend.CodebaseCommand(command)
 	}
+	return exitError(runtime.Run(project, service, command, commandStreams()))
+}
 
-	app, err := appRepository.GetApp(appId)
+// DevStatus prints the state of the containers of every service of the app.
+func DevStatus() error {
+	runtime, project, err := devProject()
 	if err != nil {
 		return err
 	}
 
-	stack, err := app.GetStack()
+	statuses, err := runtime.Status(project)
 	if err != nil {
 		return err
 	}
 
-	services := stack.GetServices()
+	return render(statuses, func() {
+		outputDevStatus(project, statuses)
+	})
+}
 
-	var envs []string
-	var links []string
+func outputDevStatus(project backend.Project, statuses []backend.ServiceStatus) {
+	fmt.Printf("=== %s Services [%d]\n", project.Name, len(statuses))
+	var data [][]string
+	data = append(data, []string{"service", "container", "state", "ports"})
 
-	for _, service := range services {
-		if service.IsBuildable() {
-			links = service.GetLinks()
-			break
+	for _, status := range statuses {
+		container, state := status.Container, status.State
+		if len(container) > 12 {
+			container = container[:12]
 		}
-	}
-
-	for _, link := range links {
-		envs = append(envs, "export "+strings.ToUpper(link+"_HOST=")+"localhost;")
-		mappingPort, err := getServiceMappingPort(appId, link, services[link].GetExpose()[0])
-		if err != nil {
-			return err
+		if state == "" {
+			state = "not created"
 		}
-		envs = append(envs, "export "+strings.ToUpper(link+"_PORT=")+strconv.Itoa(mappingPort)+";")
 
-		linkEnvs := services[link].GetEnv()
-		for name, linkEnv := range linkEnvs {
-			envs = append(envs, "export "+strings.ToUpper(link+"_"+name+"=")+linkEnv+";")
+		var exposed []int
+		for port := range status.Ports {
+			exposed = append(exposed, port)
 		}
+		sort.Ints(exposed)
+		var ports []string
+		for _, port := range exposed {
+			ports = append(ports, fmt.Sprintf("%d->%d", status.Ports[port], port))
+		}
+		data = append(data, []string{status.Service, container, state, strings.Join(ports, ", ")})
 	}
 
-	for _, env := range envs {
-		fmt.Println(env)
-	}
-
-	return nil
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetRowSeparator("-")
+	table.SetRowLine(true)
+	table.AppendBulk(data)
+	table.Render()
 }
 
-func getServiceMappingPort(appName string, serviceName string, port int) (int, error) {
-	out, err := exec.Command("docker", "ps").Output()
-
+// DevLogs prints the logs of service, of every service when empty.
+func DevLogs(service string, follow bool) error {
+	runtime, project, err := devProject()
 	if err != nil {
-		return 0, err
+		return err
 	}
 
-	containerInfo := string(out)
-
-	for _, line := range strings.Split(containerInfo, "\n") {
-		if strings.Contains(line, strings.Replace(appName, "-", "", -1)+"_"+serviceName) {
-			containerId := strings.Split(line, " ")[0]
-			output, err := exec.Command("docker", "port", containerId).Output()
-
-			if err != nil {
-				return 0, err
-			}
-			mappingInfo := string(output)
-
-			for _, infoLine := range strings.Split(mappingInfo, "\n") {
-				re, _ := regexp.Compile(strconv.Itoa(port) + `/.*`)
-				res := re.FindSubmatch([]byte(line))
-
-				if len(res) > 0 {
-					return strconv.Atoi(strings.Split(infoLine, ":")[1])
-				}
-			}
-			return 0, errors.New(fmt.Sprintf("Cannot find mapping port for service %s port %d", serviceName, strconv.Itoa(port)))
+	if service != "" {
+		known := false
+		for _, s := range backend.Services(project) {
+			known = known || s.Name == service
+		}
+		if !known {
+			return fmt.Errorf("The stack of %s has no service %s", project.Name, service)
 		}
 	}
+	return runtime.Logs(project, service, follow)
+}
 
-	return 0, errors.New("Cannot find container for service " + serviceName)
+// The formats of dev:export, with the file they are written to by default.
+var exportFormats = map[string]struct {
+	file     string
+	generate func(project backend.Project) string
+}{
+	"compose": {"docker-compose.yml", compose.ComposeBackend{}.ToComposeFile},
+	"k8s":     {"k8s.yml", k8s.ToManifests},
 }
 
-func Pipe(cmds ...*exec.Cmd) ([]byte, error) {
-	readFile, writeFile, err := os.Pipe()
-	if err != nil {
-		return nil, err
-	}
-	execCmds := make([]*exec.Cmd, 0)
-	execCmds = append(execCmds, cmds...)
-	i := 0
-	for ; i < len(execCmds)-1; i++ {
-		stdout, _ := execCmds[i].StdoutPipe()
-		execCmds[i+1].Stdin = stdout
-		err := execCmds[i].Start()
-		if err != nil {
-			return nil, err
-		}
+// DevExport writes the stack of the app as a compose file or Kubernetes manifests to file,
+// the default file of format when empty, or to the standard output when "-". An existing
+// default file is only overwritten with force.
+func DevExport(format string, file string, force bool) error {
+	exporter, ok := exportFormats[format]
+	if !ok {
+		return fmt.Errorf("Unknown format %s, use compose or k8s", format)
 	}
-	execCmds[i].Stdout = writeFile
 
-	err = execCmds[i].Run()
+	project, err := appProject()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	for i := 0; i < len(execCmds)-1; i++ {
-		err := execCmds[i].Wait()
-		if err != nil {
-			return nil, err
+	content := exporter.generate(project)
+	if file == "-" {
+		fmt.Print(content)
+		return nil
+	}
+	if file == "" {
+		file = exporter.file
+		if _, err := os.Stat(file); err == nil && !force {
+			return fmt.Errorf("%s already exists, use --force to overwrite it or --file to write elsewhere", file)
 		}
 	}
-
-	writeFile.Close()
-
-	content, err := ioutil.ReadAll(readFile)
-	if err != nil {
-		return nil, err
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		return err
 	}
-
-	readFile.Close()
-
-	return content, nil
+	fmt.Printf("Wrote %s\n", file)
+	return nil
 }
@@ -30,12 +30,13 @@ func DomainsList() error {
 	if err != nil {
 		return err
 	}
-	fmt.Printf("=== Domains [%d]\n", len(domains.Items()))
+	return render(domains.Items(), func() {
+		fmt.Printf("=== Domains [%d]\n", len(domains.Items()))
 
-	for _, domain := range domains.Items() {
-		fmt.Printf("%s %s\n", domain.Id(), domain.Name())
-	}
-	return nil
+		for _, domain := range domains.Items() {
+			fmt.Printf("%s %s\n", domain.Id(), domain.Name())
+		}
+	})
 }
 
 func DomainsRemove(domainName string) error {
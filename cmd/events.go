@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cnupp/appssdk/api"
+	"github.com/cnupp/appssdk/net"
+	"github.com/cnupp/cli/config"
+	"github.com/olekukonko/tablewriter"
+)
+
+var eventPollInterval = 5 * time.Second
+
+// EventsList prints the events of eventType, of every type when empty, about the app
+// named appName, or about any app when empty. With follow, it keeps printing the events
+// as they are added to the feed.
+func EventsList(eventType string, appName string, follow bool) error {
+	configRepository := config.NewConfigRepository(func(error) {})
+	feed := newEventFeed(net.NewCloudControllerGateway(configRepository), eventType)
+
+	events, err := feed.poll()
+	if err != nil {
+		return err
+	}
+	events = appEvents(events, appName)
+
+	if !follow {
+		return render(events, func() {
+			fmt.Printf("=== Events [%d]\n", len(events))
+			outputEvents(events)
+		})
+	}
+
+	for {
+		for _, event := range events {
+			if err := printEvent(event); err != nil {
+				return err
+			}
+		}
+
+		time.Sleep(eventPollInterval)
+		events, err = feed.poll()
+		if err != nil {
+			return err
+		}
+		events = appEvents(events, appName)
+	}
+}
+
+// eventFeed reads the events of a type as they are added. The controller lists the events
+// oldest first and only appends to them, so the feed keeps as a cursor the page it reached
+// and how many of its events it read, rather than every event it has seen.
+type eventFeed struct {
+	gateway net.Gateway
+	uri     string
+	read    int
+}
+
+func newEventFeed(gateway net.Gateway, eventType string) *eventFeed {
+	return &eventFeed{gateway: gateway, uri: "/events?type=" + url.QueryEscape(eventType)}
+}
+
+// poll returns the events added since the previous poll, every event the first time.
+func (f *eventFeed) poll() ([]api.EventRef, error) {
+	var events []api.EventRef
+	for {
+		var page api.EventsModel
+		if err := f.gateway.Get(f.uri, &page); err != nil {
+			return nil, err
+		}
+		items := page.Items()
+		if f.read < len(items) {
+			events = append(events, items[f.read:]...)
+			f.read = len(items)
+		}
+		if page.NextField == "" {
+			return events, nil
+		}
+		f.uri, f.read = page.NextField, 0
+	}
+}
+
+// appEvents returns the events about the app named appName, all of them when empty.
+func appEvents(events []api.EventRef, appName string) []api.EventRef {
+	if appName == "" {
+		return events
+	}
+	var items []api.EventRef
+	for _, event := range events {
+		if eventApp(event) == appName {
+			items = append(items, event)
+		}
+	}
+	return items
+}
+
+// eventApp returns the name of the app an event is about, taken from the event content
+// or from its "app" link, or an empty string for events not related to an app.
+func eventApp(event api.EventRef) string {
+	switch app := event.Entity()["app"].(type) {
+	case string:
+		return app
+	case map[string]interface{}:
+		if name, ok := app["name"].(string); ok {
+			return name
+		}
+	}
+
+	if link, err := event.Links().Link("app"); err == nil {
+		segments := strings.Split(strings.TrimRight(link.URI, "/"), "/")
+		return segments[len(segments)-1]
+	}
+	return ""
+}
+
+func outputEvents(events []api.EventRef) {
+	var data [][]string
+	data = append(data, []string{"id", "type", "app", "content"})
+	for _, event := range events {
+		content, _ := json.Marshal(event.Entity())
+		data = append(data, []string{event.ID(), event.Type(), eventApp(event), string(content)})
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetRowSeparator("-")
+	table.SetRowLine(true)
+	table.AppendBulk(data)
+	table.Render()
+}
+
+// printEvent prints a single event as one line, or as a document with --output.
+func printEvent(event api.EventRef) error {
+	if outputFormat == OutputJson || outputFormat == OutputYaml {
+		return render(event, func() {})
+	}
+	content, err := json.Marshal(event.Entity())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s %s %s %s\n", event.ID(), event.Type(), eventApp(event), content)
+	return nil
+}
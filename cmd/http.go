@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"net/http"
+
+	"github.com/cnupp/cli/config"
+	"github.com/cnupp/cli/httpclient"
+)
+
+// httpClient sends the requests the commands make outside of the sdk gateways, such as
+// the source upload of launch:build.
+var httpClient = &http.Client{}
+
+// The sdk gateways send their requests with http.DefaultTransport, which configureHTTP
+// replaces with the configured transport. baseTransport is the transport it wrapped, so
+// that configuring again wraps it rather than the configured transport.
+var baseTransport, configuredTransport http.RoundTripper
+
+// ConfigureHTTP applies the timeout, retries, backoff and TLS settings of the current
+// profile to every request sent to the controller and the launcher, and has the user log
+// in again when their session expired. When the TLS
+// settings cannot be loaded the requests still go out with the default ones, and the
+// error is returned.
+func ConfigureHTTP(configRepository config.ConfigRepository) error {
+	return configureHTTP(configRepository, httpclient.TLSOptions{
+		SkipVerify: !configRepository.SSLVerify(),
+		CACert:     configRepository.CACert(),
+		ClientCert: configRepository.ClientCert(),
+		ClientKey:  configRepository.ClientKey(),
+	})
+}
+
+func configureHTTP(configRepository config.ConfigRepository, options httpclient.TLSOptions) error {
+	if http.DefaultTransport != configuredTransport {
+		baseTransport = http.DefaultTransport
+	}
+	tlsConfig, err := httpclient.TLSConfig(options)
+	configuredTransport = httpclient.NewTransport(httpclient.Settings{
+		Timeout: configRepository.HTTPTimeout(),
+		Retries: configRepository.HTTPRetries(),
+		Backoff: configRepository.HTTPBackoff(),
+		TLS:     tlsConfig,
+
+		Reauthenticate: reauthenticate,
+	}, baseTransport)
+	http.DefaultTransport = configuredTransport
+	httpClient = &http.Client{Transport: configuredTransport}
+	return err
+}
@@ -76,8 +76,9 @@ func ListKeys() error {
 		return err
 	}
 
-	printKeys(keys)
-	return err
+	return render(keys.Items(), func() {
+		printKeys(keys)
+	})
 }
 
 func getKey(filename string) (content, name string, err error) {
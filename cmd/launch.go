@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/fatih/color"
 	"github.com/cnupp/cli/config"
+	"github.com/cnupp/cli/pkg"
 	"github.com/cnupp/appssdk/api"
 	"github.com/cnupp/appssdk/net"
 	runtimeApi "github.com/cnupp/runtimesdk/api"
@@ -14,28 +15,62 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
-	"time"
 )
 
-func LaunchBuild(filename, appName string) error {
-	file, err := read(filename)
+// LaunchBuild uploads filename, or the current git working tree packaged as a tar.gz
+// when filename is empty, and builds it. maxSize is the upload limit in megabytes.
+func LaunchBuild(filename, appName, gitSha, user string, maxSize int64) error {
+	configRepository := config.NewConfigRepository(func(err error) {
 
-	if err != nil {
-		return err
+	})
+	if gitSha == "" {
+		var err error
+		gitSha, err = workingTreeSha()
+		if err != nil {
+			return err
+		}
+	}
+	if user == "" {
+		user = buildOwner(configRepository)
 	}
 
-	configRepository := config.NewConfigRepository(func(err error) {
+	var source io.ReadCloser
+	var sourceName string
+	if filename == "" {
+		archive, err := packageWorkingTree(maxSize << 20)
+		if err != nil {
+			return err
+		}
+		source, sourceName = archive, sourceArchiveName
+	} else {
+		file, err := read(filename)
+		if err != nil {
+			return err
+		}
+		info, err := file.Stat()
+		if err == nil {
+			err = checkUploadSize(filename+" weighs", info.Size(), maxSize<<20)
+		}
+		if err != nil {
+			file.Close()
+			return err
+		}
+		source, sourceName = file, filepath.Base(file.Name())
+	}
 
-	})
 	launcherEntrypoint := configRepository.DeploymentEndpoint()
 
-	request, errChannel, err := toRequest(file, launcherEntrypoint)
+	defer source.Close()
+
+	request, errChannel, err := toRequest(&sizeLimitedReader{reader: source, limit: maxSize << 20}, sourceName, launcherEntrypoint)
 	if err != nil {
 		return err
 	}
+	progress := &progressReader{body: request.Body}
+	request.Body = progress
 
-	client := http.Client{}
-	res, err := client.Do(request)
+	res, err := httpClient.Do(request)
+	progress.Done()
 	if err != nil {
 		if errc := <-errChannel; errc != nil {
 			return errors.New(fmt.Sprintf("multiple errors happend: %s %s", errc, err))
@@ -44,7 +79,12 @@ func LaunchBuild(filename, appName string) error {
 		}
 	}
 
+	defer res.Body.Close()
+
 	location := res.Header.Get("Location")
+	if res.StatusCode/100 != 2 || location == "" {
+		return fmt.Errorf("upload source failed: %s", res.Status)
+	}
 	gateway := net.NewCloudControllerGateway(configRepository)
 	apps := api.NewAppRepository(configRepository, gateway)
 
@@ -62,8 +102,8 @@ func LaunchBuild(filename, appName string) error {
 	}
 
 	build, err := app.CreateBuild(api.BuildParams{
-		GitSha: "mockedsh",
-		User:   "should_be_replaced_to_the_build_owner",
+		GitSha: gitSha,
+		User:   user,
 		Source: location,
 	})
 
@@ -71,60 +111,51 @@ func LaunchBuild(filename, appName string) error {
 		fmt.Println("create build", err)
 		return err
 	}
-	cerr := make(chan error)
-	succeed := make(chan bool)
-	cleaning := make(chan bool)
-
-	go func() {
-		for {
-			select {
-			case <-cleaning:
-				// todo: should fetch log again
-				return
-			default:
-				// todo: fetch log
-				fmt.Println("fetch log")
-				time.Sleep(time.Second * 5)
-			}
+	err = followProcedure(func() (bool, error) {
+		if build.IsSuccess() {
+			return true, nil
 		}
-	}()
-
-	go func() {
-		for {
-			if build.IsSuccess() {
-				succeed <- true
-				cleaning <- true
-				return
-			}
-			if build.IsFail() {
-				succeed <- false
-				cleaning <- true
-				cerr <- errors.New("Build is failed")
-			}
-			time.Sleep(time.Second * 5)
-			build, err = app.GetBuild(build.Id())
-			if err != nil {
-				cerr <- err
-				return
-			}
+		if build.IsFail() {
+			return true, errors.New("Build fail")
 		}
-	}()
+		build, err = app.GetBuild(build.Id())
+		return false, err
+	}, buildLogFetcher(app, build.Id(), buildLogType))
+	if err != nil {
+		return err
+	}
+	color.Green("Build Success")
+	return nil
+}
 
-	for {
-		select {
-		case succ := <-succeed:
-			if !succ {
-				return errors.New("Build fail")
-			} else {
-				color.Green("Build Success")
-				return nil
-			}
-		case err := <-cerr:
-			return err
-		default:
-			time.Sleep(time.Second * 5)
-		}
+// workingTreeSha returns the HEAD commit of the current repository. Uncommitted changes
+// are reported as a warning rather than folded into the sha. Outside a git repository the
+// build is launched without a sha, as before.
+func workingTreeSha() (string, error) {
+	if !git.IsGitDirectory() {
+		color.Yellow("Not in a git repository, the build records no commit. Use --git-sha to specify it")
+		return "", nil
+	}
+	sha, err := git.HeadCommit()
+	if err != nil {
+		return "", err
+	}
+	dirty, err := git.IsDirty()
+	if err != nil {
+		return "", err
+	}
+	if dirty {
+		color.Yellow("The working tree has uncommitted changes, commit %s does not include them", sha)
+	}
+	return sha, nil
+}
+
+// buildOwner is the logged in user, identified by email and by id when no email is known.
+func buildOwner(configRepository config.ConfigRepository) string {
+	if email := configRepository.Email(); email != "" {
+		return email
 	}
+	return configRepository.Id()
 }
 
 func LaunchVerify(buildId, appName string) error {
@@ -156,60 +187,21 @@ func LaunchVerify(buildId, appName string) error {
 		return err
 	}
 
-	cerr := make(chan error)
-	succeed := make(chan bool)
-	cleaning := make(chan bool)
-
-	go func() {
-		for {
-			select {
-			case <-cleaning:
-				// todo: should fetch log again
-				return
-			default:
-				// todo: fetch log
-				fmt.Println("fetch log")
-				time.Sleep(time.Second * 5)
-			}
+	err = followProcedure(func() (bool, error) {
+		if verify.IsSuccess() {
+			return true, nil
 		}
-	}()
-
-	go func() {
-		for {
-			if verify.IsSuccess() {
-				succeed <- true
-				cleaning <- true
-				return
-			}
-			if verify.IsFail() {
-				succeed <- false
-				cleaning <- true
-				cerr <- errors.New("Verify is failed")
-			}
-			time.Sleep(time.Second * 5)
-			verify, err = build.GetVerify(verify.Id())
-			if err != nil {
-				cerr <- err
-				return
-			}
-		}
-	}()
-
-	for {
-		select {
-		case succ := <-succeed:
-			if !succ {
-				return errors.New("Verify fail")
-			} else {
-				color.Green("Verify Success")
-				return nil
-			}
-		case err := <-cerr:
-			return err
-		default:
-			time.Sleep(time.Second * 5)
+		if verify.IsFail() {
+			return true, errors.New("Verify fail")
 		}
+		verify, err = build.GetVerify(verify.Id())
+		return false, err
+	}, buildLogFetcher(app, build.Id(), verifyLogType))
+	if err != nil {
+		return err
 	}
+	color.Green("Verify Success")
+	return nil
 }
 
 func LaunchDeployment(releaseId, appName string, providerName string) error {
@@ -236,6 +228,12 @@ func LaunchDeployment(releaseId, appName string, providerName string) error {
 		return err
 	}
 
+	return deployRelease(configRepository, app, release, providerName)
+}
+
+// deployRelease runs the RUN procedure of the app's unified procedure with the image of release,
+// on the named provider or on the provider the app is bound to when providerName is empty.
+func deployRelease(configRepository config.ConfigRepository, app api.App, release api.Release, providerName string) error {
 	runtimeGateway := runtimeNet.NewCloudControllerGateway(configRepository)
 	upsRepository := runtimeApi.NewUpsRepository(configRepository, runtimeGateway)
 	upLink, err := app.Links().Link("unified_procedure")
@@ -262,7 +260,7 @@ func LaunchDeployment(releaseId, appName string, providerName string) error {
 		}
 		provider, err = providerRepository.GetProviderByUri(providerLink.URI)
 	} else {
-		provider, err = providerRepository.GetProviderByName(providerName)
+		provider, err = resolveProvider(providerRepository, providerName)
 	}
 	if err != nil {
 		return err
@@ -293,82 +291,49 @@ func LaunchDeployment(releaseId, appName string, providerName string) error {
 	if err != nil {
 		return err
 	}
-	cerr := make(chan error)
-	succeed := make(chan bool)
-	cleaning := make(chan bool)
-
-	go func() {
-		for {
-			select {
-			case <-cleaning:
-				// todo: should fetch log again
-				return
-			default:
-				// todo: fetch log
-				fmt.Println("fetch log")
-				time.Sleep(time.Second * 5)
-			}
-		}
-	}()
-
-	go func() {
-		for {
-			if instance.Status() == "SUCCEED" {
-				succeed <- true
-				cleaning <- true
-				return
-			}
-			if instance.Status() == "FAILED" {
-				succeed <- false
-				cleaning <- true
-				cerr <- errors.New("Deployment is failed")
-			}
-			time.Sleep(time.Second * 5)
-			instance, err = upsRepository.GetProcedureInstance(instance.Id())
-			if err != nil {
-				cerr <- err
-				return
-			}
+	fetch, ok := procedureLogFetcher(runtimeGateway, instance)
+	if !ok {
+		color.Yellow("The log of the deployment is not available, waiting for the deployment to finish")
+	}
+	err = followProcedure(func() (bool, error) {
+		if instance.Status() == "SUCCEED" {
+			return true, nil
 		}
-	}()
-
-	for {
-		select {
-		case succ := <-succeed:
-			if !succ {
-				return errors.New("Deployment Fail")
-			} else {
-				color.Green("Deployment Success")
-				return nil
-			}
-		case err := <-cerr:
-			return err
-		default:
-			time.Sleep(time.Second * 5)
+		if instance.Status() == "FAILED" {
+			return true, errors.New("Deployment Fail")
 		}
+		instance, err = upsRepository.GetProcedureInstance(instance.Id())
+		return false, err
+	}, fetch)
+	if err != nil {
+		return err
 	}
+	color.Green("Deployment Success")
+	return nil
 }
 
-func toRequest(file *os.File, entrypoint string) (*http.Request, chan error, error) {
+func toRequest(content io.Reader, name string, entrypoint string) (*http.Request, chan error, error) {
 	reader, writer := io.Pipe()
 	newWriter := multipart.NewWriter(writer)
 	errChannel := make(chan error, 1)
 	go func() {
-		defer file.Close()
-		defer writer.Close()
-
-		part, err := newWriter.CreateFormFile("file", filepath.Base(file.Name()))
+		part, err := newWriter.CreateFormFile("file", name)
 		if err != nil {
+			writer.CloseWithError(err)
 			errChannel <- errors.New("unable to create multipart")
 			return
 		}
 
-		if _, err := io.Copy(part, file); err != nil {
+		if _, err := io.Copy(part, content); err != nil {
+			// fail the upload rather than sending a truncated file
+			writer.CloseWithError(err)
 			errChannel <- err
 			return
 		}
 
-		errChannel <- newWriter.Close()
+		err = newWriter.Close()
+		writer.Close()
+		errChannel <- err
 	}()
 
 	request, err := http.NewRequest("POST", fmt.Sprintf("%s/files", entrypoint), reader)
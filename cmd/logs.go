@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/cnupp/appssdk/api"
+	runtimeApi "github.com/cnupp/runtimesdk/api"
+	runtimeNet "github.com/cnupp/runtimesdk/net"
+)
+
+const (
+	buildLogType  = "BUILD"
+	verifyLogType = "VERIFY"
+	logPageSize   = 500
+)
+
+var procedurePollInterval = 5 * time.Second
+
+// logFetcher returns the log lines of a procedure starting at offset.
+type logFetcher func(offset int64) (api.LogsModel, error)
+
+// followProcedure polls a procedure until it reaches a terminal state, printing its log
+// incrementally in the meantime. The remaining log is flushed once the procedure ends.
+func followProcedure(poll func() (finished bool, err error), fetch logFetcher) error {
+	var offset int64
+	for {
+		finished, err := poll()
+		offset = printLogFrom(fetch, offset)
+		if err != nil || finished {
+			return err
+		}
+		time.Sleep(procedurePollInterval)
+	}
+}
+
+// printLogFrom prints every line available after offset and returns the new offset.
+func printLogFrom(fetch logFetcher, offset int64) int64 {
+	for {
+		logs, err := fetch(offset)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "fetch log failed: %v\n", err)
+			return offset
+		}
+		if logs.ErrorField != "" {
+			return offset
+		}
+		for _, item := range logs.ItemsField {
+			fmt.Println(item.MessageField)
+		}
+		offset += int64(len(logs.ItemsField))
+		if len(logs.ItemsField) < logPageSize {
+			return offset
+		}
+	}
+}
+
+func buildLogFetcher(app api.App, buildId string, logType string) logFetcher {
+	return func(offset int64) (api.LogsModel, error) {
+		return app.GetLogForTests(buildId, logType, logPageSize, offset)
+	}
+}
+
+// procedureLogFetcher reads the log a procedure instance exposes through its "log" link.
+// It tells when the instance has no such link, and returns noLogFetcher then.
+func procedureLogFetcher(gateway runtimeNet.Gateway, instance runtimeApi.ProcedureInstance) (logFetcher, bool) {
+	for _, link := range instance.Links() {
+		if link.RelField == "log" {
+			uri := link.UriField
+			return func(offset int64) (logs api.LogsModel, err error) {
+				err = gateway.Get(fmt.Sprintf("%s?lines=%d&offset=%d", uri, logPageSize, offset), &logs)
+				return
+			}, true
+		}
+	}
+	return noLogFetcher, false
+}
+
+// noLogFetcher follows the procedures whose log the controller does not expose.
+func noLogFetcher(offset int64) (api.LogsModel, error) {
+	return api.LogsModel{}, nil
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+const (
+	OutputTable = "table"
+	OutputJson  = "json"
+	OutputYaml  = "yaml"
+)
+
+var outputFormat = OutputTable
+
+// SetOutputFormat chooses how list and info commands print their results.
+func SetOutputFormat(format string) {
+	outputFormat = strings.ToLower(format)
+}
+
+// render prints value as a document when a machine readable output is requested,
+// otherwise it falls back to the human readable table printer.
+func render(value interface{}, table func()) error {
+	switch outputFormat {
+	case "", OutputTable:
+		table()
+		return nil
+	case OutputJson:
+		out, err := json.MarshalIndent(toDocument(reflect.ValueOf(value)), "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+		return nil
+	case OutputYaml:
+		out, err := yaml.Marshal(toDocument(reflect.ValueOf(value)))
+		if err != nil {
+			return err
+		}
+		fmt.Print(string(out))
+		return nil
+	default:
+		return fmt.Errorf("unsupported output format %s, use one of %s, %s or %s", outputFormat, OutputTable, OutputJson, OutputYaml)
+	}
+}
+
+// toDocument keeps the serializable fields of the SDK models and drops the mappers and
+// repositories they carry, so that only the data returned by the controller is printed.
+func toDocument(v reflect.Value) interface{} {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return toDocument(v.Elem())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return []interface{}{}
+		}
+		items := make([]interface{}, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			items[i] = toDocument(v.Index(i))
+		}
+		return items
+	case reflect.Map:
+		doc := make(map[string]interface{}, v.Len())
+		for _, key := range v.MapKeys() {
+			doc[fmt.Sprintf("%v", key.Interface())] = toDocument(v.MapIndex(key))
+		}
+		return doc
+	case reflect.Struct:
+		doc := make(map[string]interface{})
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			name := field.Name
+			_, tagged := field.Tag.Lookup("json")
+			if field.Anonymous && !tagged && field.Type.Kind() == reflect.Struct {
+				if embedded, ok := toDocument(v.Field(i)).(map[string]interface{}); ok {
+					for key, value := range embedded {
+						doc[key] = value
+					}
+				}
+				continue
+			}
+			if tag, ok := field.Tag.Lookup("json"); ok {
+				tagName := strings.Split(tag, ",")[0]
+				if tagName == "-" {
+					continue
+				}
+				if tagName != "" {
+					name = tagName
+				}
+			} else if field.Type.Kind() == reflect.Interface || field.Type.Kind() == reflect.Func {
+				continue
+			}
+			doc[name] = toDocument(v.Field(i))
+		}
+		return doc
+	default:
+		return v.Interface()
+	}
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/cnupp/cli/config"
+)
+
+func ProfilesList() error {
+	configRepository := config.NewConfigRepository(func(error) {})
+	names := configRepository.ProfileNames()
+	if len(names) == 0 {
+		return fmt.Errorf("no profile found, use 'cde login' to create one")
+	}
+
+	fmt.Printf("=== Profiles [%d]\n", len(names))
+	active := configRepository.ActiveProfile()
+	for _, name := range names {
+		profile, _ := configRepository.GetProfile(name)
+		marker := " "
+		if name == active {
+			marker = "*"
+		}
+		fmt.Printf("%s %s endpoint: %s email: %s\n", marker, name, profile.Endpoint, profile.Email)
+	}
+	return nil
+}
+
+func ProfileUse(name string) error {
+	configRepository := config.NewConfigRepository(func(error) {})
+	if err := configRepository.UseProfile(name); err != nil {
+		return err
+	}
+	fmt.Printf("Switched to profile %s\n", name)
+	return nil
+}
+
+func ProfileRemove(name string) error {
+	configRepository := config.NewConfigRepository(func(error) {})
+	if err := configRepository.RemoveProfile(name); err != nil {
+		return err
+	}
+	fmt.Printf("Removed profile %s\n", name)
+	return nil
+}
@@ -40,9 +40,9 @@ func ProviderList() error {
 		return err
 	}
 
-	outputProvidersListInfo(providers)
-
-	return nil
+	return render(providers.Items(), func() {
+		outputProvidersListInfo(providers)
+	})
 }
 
 func outputProvidersListInfo(providers api.Providers) {
@@ -67,14 +67,14 @@ func GetProviderByName(name string) error {
 	providerRepository := api.NewProviderRepository(configRepository,
 		net.NewCloudControllerGateway(configRepository))
 
-	provider, err := providerRepository.GetProviderByName(name)
+	provider, err := resolveProvider(providerRepository, name)
 	if err != nil {
 		return err
 	}
 
-	outputProviderInfo(provider)
-
-	return nil
+	return render(provider, func() {
+		outputProviderInfo(provider)
+	})
 }
 
 func outputProviderInfo(provider api.Provider) {
@@ -104,7 +104,7 @@ func ProviderUpdate(providerName string, updateConfigMap map[string]interface{})
 	providerRepository := api.NewProviderRepository(configRepository,
 		net.NewCloudControllerGateway(configRepository))
 
-	provider, err := providerRepository.GetProviderByName(providerName)
+	provider, err := resolveProvider(providerRepository, providerName)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/cnupp/appssdk/api"
+	"github.com/cnupp/appssdk/net"
+	"github.com/fatih/color"
+	"github.com/olekukonko/tablewriter"
+)
+
+func ReleasesList(appId string) error {
+	configRepository, appId, err := load(appId)
+	if err != nil {
+		return err
+	}
+	gateway := net.NewCloudControllerGateway(configRepository)
+	app, err := api.NewAppRepository(configRepository, gateway).GetApp(appId)
+	if err != nil {
+		return err
+	}
+
+	releases, err := appReleases(gateway, app)
+	if err != nil {
+		return err
+	}
+
+	return render(releases, func() {
+		fmt.Printf("=== %s Releases [%d]\n", app.Name(), len(releases))
+		outputReleases(releases)
+	})
+}
+
+func outputReleases(releases []releaseRecord) {
+	var data [][]string
+	data = append(data, []string{"id", "image", "version", "status"})
+	for _, release := range releases {
+		data = append(data, []string{release.Id(), release.ImageName(), release.Version(), release.Status()})
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetRowSeparator("-")
+	table.SetRowLine(true)
+	table.AppendBulk(data)
+	table.Render()
+}
+
+func ReleaseInfo(appId string, releaseId string) error {
+	configRepository, appId, err := load(appId)
+	if err != nil {
+		return err
+	}
+	app, err := api.NewAppRepository(configRepository,
+		net.NewCloudControllerGateway(configRepository)).GetApp(appId)
+	if err != nil {
+		return err
+	}
+
+	release, err := app.GetRelease(releaseId)
+	if err != nil {
+		return err
+	}
+
+	return render(release, func() {
+		fmt.Printf("--- Release %s\n", release.Id())
+		data := [][]string{
+			{"Image", release.ImageName()},
+			{"Version", release.Version()},
+			{"Status", release.Status()},
+		}
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetAlignment(tablewriter.ALIGN_LEFT)
+		table.AppendBulk(data)
+		table.Render()
+
+		fmt.Println("--- Envs")
+		var keys []string
+		for key := range release.Envs() {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Printf("%s=%s\n", key, release.Envs()[key])
+		}
+	})
+}
+
+// ReleaseRollback redeploys a release of the app. Without a release id it picks the
+// successful release preceding the latest successful one.
+func ReleaseRollback(appId string, releaseId string, providerName string) error {
+	configRepository, appId, err := load(appId)
+	if err != nil {
+		return err
+	}
+	gateway := net.NewCloudControllerGateway(configRepository)
+	app, err := api.NewAppRepository(configRepository, gateway).GetApp(appId)
+	if err != nil {
+		return err
+	}
+
+	var release api.Release
+	if releaseId == "" {
+		releases, err := appReleases(gateway, app)
+		if err != nil {
+			return err
+		}
+		release, err = previousSuccessfulRelease(releases)
+		if err != nil {
+			return err
+		}
+		release, err = app.GetRelease(release.Id())
+		if err != nil {
+			return err
+		}
+	} else {
+		release, err = app.GetRelease(releaseId)
+		if err != nil {
+			return err
+		}
+		if !release.IsSuccess() {
+			return fmt.Errorf("release %s is %s, only successful releases can be rolled back to", release.Id(), release.Status())
+		}
+	}
+
+	color.Yellow("Rolling back %s to release %s (%s:%s)", app.Name(), release.Id(), release.ImageName(), release.Version())
+	return deployRelease(configRepository, app, release, providerName)
+}
+
+// releaseRecord is a release as returned by the controller, including its creation time
+// the sdk model drops.
+type releaseRecord struct {
+	api.ReleaseModel
+	CreatedAt int64 `json:"created_at"`
+}
+
+type releasesPage struct {
+	Next  string          `json:"next"`
+	Items []releaseRecord `json:"items"`
+}
+
+// appReleases returns every release of app, following the pages of the listing.
+func appReleases(gateway net.Gateway, app api.App) ([]releaseRecord, error) {
+	var releases []releaseRecord
+	for uri := fmt.Sprintf("/apps/%s/releases", app.Name()); uri != ""; {
+		var current releasesPage
+		if err := gateway.Get(uri, &current); err != nil {
+			return nil, err
+		}
+		releases = append(releases, current.Items...)
+		uri = current.Next
+	}
+	return releases, nil
+}
+
+// previousSuccessfulRelease returns the successful release created before the latest
+// successful one.
+func previousSuccessfulRelease(releases []releaseRecord) (api.Release, error) {
+	sort.SliceStable(releases, func(i, j int) bool { return releases[i].CreatedAt > releases[j].CreatedAt })
+	current := true
+	for _, release := range releases {
+		if !release.IsSuccess() {
+			continue
+		}
+		if current {
+			current = false
+			continue
+		}
+		return release, nil
+	}
+	return nil, errors.New("no previous successful release to roll back to")
+}
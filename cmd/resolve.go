@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/cnupp/appssdk/api"
+	"github.com/cnupp/appssdk/net"
+	"github.com/cnupp/cli/config"
+	runtimeApi "github.com/cnupp/runtimesdk/api"
+	runtimeNet "github.com/cnupp/runtimesdk/net"
+)
+
+// The commands address stacks, unified procedures, providers and clusters by name or by
+// id. The resolvers look the resource up by name first, and by id when no resource has
+// that name. Failing to look a name up fails the resolution, only a missing id is reported
+// as not found.
+
+// pickId returns the id of the only resource of kind among the ones named name, or name
+// itself, to be looked up as an id, when none is.
+func pickId(kind string, name string, ids []string) (string, error) {
+	switch {
+	case name == "":
+		return "", fmt.Errorf("Please provide the %s name or id", kind)
+	case len(ids) == 0:
+		return name, nil
+	case len(ids) == 1:
+		return ids[0], nil
+	default:
+		return "", fmt.Errorf("%s name %s is ambiguous, it matches the ids %s, use one of them instead", kind, name, strings.Join(ids, ", "))
+	}
+}
+
+// resolved reports the failed look up of a resource of kind by id as not found when the
+// id is nameOrId, as it is then only a guess, and the controller answered it has no such
+// resource.
+func resolved(kind string, nameOrId string, id string, err error) error {
+	if isNotFound(err) && id == nameOrId {
+		return fmt.Errorf("%s %s not found", kind, nameOrId)
+	}
+	return err
+}
+
+// isNotFound tells whether err is the error of a gateway for a 404 response. The gateways
+// report the status of the response on the first line of their errors.
+func isNotFound(err error) bool {
+	return err != nil && strings.HasPrefix(strings.TrimLeft(err.Error(), "\n"), "404 ")
+}
+
+// resolveStack queries stacks by name with the gateway, as the sdk fails the query when
+// no stack has the name.
+func resolveStack(configRepository config.ConfigRepository, nameOrId string) (api.Stack, error) {
+	gateway := net.NewCloudControllerGateway(configRepository)
+	var ids []string
+	for uri := "/stacks?name=" + url.QueryEscape(nameOrId); uri != ""; {
+		var stacks api.StacksModel
+		if err := gateway.Get(uri, &stacks); err != nil {
+			return nil, err
+		}
+		for _, stack := range stacks.Items() {
+			ids = append(ids, stack.Id())
+		}
+		uri = stacks.NextField
+	}
+	id, err := pickId("stack", nameOrId, ids)
+	if err != nil {
+		return nil, err
+	}
+	stack, err := api.NewStackRepository(configRepository, gateway).GetStack(id)
+	if err = resolved("stack", nameOrId, id, err); err != nil {
+		return nil, err
+	}
+	return stack, nil
+}
+
+func resolveUp(repository runtimeApi.UpsRepository, nameOrId string) (runtimeApi.Up, error) {
+	ups, err := repository.GetUPByName(url.QueryEscape(nameOrId))
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, up := range ups.Items() {
+		ids = append(ids, up.Id())
+	}
+	id, err := pickId("unified procedure", nameOrId, ids)
+	if err != nil {
+		return nil, err
+	}
+	up, err := repository.GetUP(id)
+	if err = resolved("unified procedure", nameOrId, id, err); err != nil {
+		return nil, err
+	}
+	return up, nil
+}
+
+func resolveProvider(repository runtimeApi.ProviderRepository, nameOrId string) (runtimeApi.Provider, error) {
+	providers, err := repository.GetProvidersByURL("/providers?name=" + url.QueryEscape(nameOrId))
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, provider := range providers.Items() {
+		ids = append(ids, provider.ID())
+	}
+	id, err := pickId("provider", nameOrId, ids)
+	if err != nil {
+		return nil, err
+	}
+	provider, err := repository.GetProviderByUri("/providers/" + url.PathEscape(id))
+	if err = resolved("provider", nameOrId, id, err); err != nil {
+		return nil, err
+	}
+	return provider, nil
+}
+
+// resolveCluster looks clusters up among all of them, following the pages of the list with
+// the gateway, as they cannot be queried by name and the sdk only returns the first page.
+func resolveCluster(configRepository config.ConfigRepository, nameOrId string) (runtimeApi.ClusterRef, error) {
+	gateway := runtimeNet.NewCloudControllerGateway(configRepository)
+	var ids []string
+	for uri := "/clusters"; uri != ""; {
+		var clusters runtimeApi.ClustersModel
+		if err := gateway.Get(uri, &clusters); err != nil {
+			return nil, err
+		}
+		for _, cluster := range clusters.Items() {
+			if cluster.Name() == nameOrId {
+				ids = append(ids, strconv.Itoa(cluster.Id()))
+			}
+		}
+		uri = clusters.NextField
+	}
+	id, err := pickId("cluster", nameOrId, ids)
+	if err != nil {
+		return nil, err
+	}
+	cluster, err := runtimeApi.NewClusterRepository(configRepository, gateway).GetClusterById(id)
+	if err = resolved("cluster", nameOrId, id, err); err != nil {
+		return nil, err
+	}
+	return cluster, nil
+}
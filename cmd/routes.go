@@ -25,14 +25,16 @@ func RoutesList() error {
 	routeRepository := api.NewRouteRepository(configRepository, net.NewCloudControllerGateway(configRepository))
 	routes, err := routeRepository.GetRoutes()
 	if err != nil {
-		fmt.Println(err)
+		return err
 	}
-	fmt.Printf("=== Routes: [%d]\n", len(routes.Items()))
 
-	for _, route := range routes.Items() {
-		fmt.Printf("id: %s path: %s domain: %s\n", route.ID(), route.Path(), route.Domain().Name)
-	}
-	return err
+	return render(routes.Items(), func() {
+		fmt.Printf("=== Routes: [%d]\n", len(routes.Items()))
+
+		for _, route := range routes.Items() {
+			fmt.Printf("id: %s path: %s domain: %s\n", route.ID(), route.Path(), route.Domain().Name)
+		}
+	})
 }
 
 func RouteBindWithApp(route, appName string) error {
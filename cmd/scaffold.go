@@ -63,16 +63,12 @@ func ScaffoldCreate(stackName, unifiedProcedure, provider, owner string, directo
 		gateway := deploymentNet.NewCloudControllerGateway(configRepository)
 		ups := launcherApi.NewUpsRepository(configRepository, gateway)
 
-		unifiedProcedures, err := ups.GetUPByName(unifiedProcedure)
+		up, err := resolveUp(ups, unifiedProcedure)
 		if err != nil {
 			return err
 		}
 
-		if unifiedProcedures.Count() != 1 {
-			return fmt.Errorf("can not find the unified procedure by name given")
-		}
-
-		template := unifiedProcedures.Items()[0].Template()
+		template := up.Template()
 		if template.URI == "" {
 			return fmt.Errorf("git repositry is no valid, please check the definition of stack '%s' to make sure it contains valid template code.", stackName)
 		}
@@ -98,18 +94,7 @@ func ScaffoldCreate(stackName, unifiedProcedure, provider, owner string, directo
 
 func getStack(stackName string) (stackObj api.Stack, err error) {
 	configRepository := config.NewConfigRepository(func(err error) {})
-	stackRepository := api.NewStackRepository(configRepository,
-		net.NewCloudControllerGateway(configRepository))
-	stacks, err := stackRepository.GetStackByName(stackName)
-
-	if err != nil || stacks.Count() == 0 {
-		err = fmt.Errorf("stack not found")
-		return
-	}
-
-	stackId := stacks.Items()[0].Id()
-	stackObj, err = stackRepository.GetStack(stackId)
-	return
+	return resolveStack(configRepository, stackName)
 }
 
 func IsDirectoryExist(directory string) bool {
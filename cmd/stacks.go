@@ -1,50 +1,161 @@
 package cmd
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/ghodss/yaml"
 	"github.com/olekukonko/tablewriter"
 	"github.com/cnupp/cli/config"
+	"github.com/cnupp/cli/stackfile"
 	"github.com/cnupp/appssdk/api"
 	"github.com/cnupp/appssdk/net"
 	"io/ioutil"
 	"os"
 	"reflect"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 )
 
 func StackCreate(filename string) error {
 	configRepository := config.NewConfigRepository(func(err error) {})
 	stackRepository := api.NewStackRepository(configRepository,
 		net.NewCloudControllerGateway(configRepository))
-	content, err := getStackFileContent(filename)
+	file, err := readStackFile(filename)
 	if err != nil {
 		return err
 	}
-	content, err = yaml.YAMLToJSON(content)
+
+	stackModel, err := stackRepository.Create(file.Document)
 	if err != nil {
-		fmt.Printf("err: %v\n", err)
-	}
-	stackDefinition := make(map[string]interface{})
-	if err := json.Unmarshal(content, &stackDefinition); err != nil {
 		return err
 	}
+	fmt.Printf("create stack %s with uuid %s\n", stackModel.Name(), stackModel.Id())
+	return nil
+}
+
+// readStackFile reads the stack definition of filename, reporting its problems with the
+// file name and their line. The unknown fields are only warned about, for the stack files
+// of other releases to keep working.
+func readStackFile(filename string) (stackfile.File, error) {
+	file, err := stackfile.Read(filename)
+	for _, warning := range file.Warnings {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", stackProblem(filename, warning))
+	}
+	if problems, ok := err.(stackfile.Problems); ok {
+		var lines []string
+		for _, problem := range problems {
+			lines = append(lines, stackProblem(filename, problem))
+		}
+		return file, fmt.Errorf("%s is not a valid stack definition:\n%s", filename, strings.Join(lines, "\n"))
+	}
+	return file, err
+}
 
-	stackModel, err := stackRepository.Create(stackDefinition)
+func stackProblem(filename string, problem stackfile.Problem) string {
+	location := filename
+	if problem.Line > 0 {
+		location = fmt.Sprintf("%s:%d", filename, problem.Line)
+	}
+	if problem.Path == "" {
+		return fmt.Sprintf("%s: %s", location, problem.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", location, problem.Path, problem.Message)
+}
+
+// StackValidate checks the stack definition of filename, as stacks:create and
+// stacks:update do before sending it. When strict, the warnings fail it too.
+func StackValidate(filename string, strict bool) error {
+	file, err := readStackFile(filename)
 	if err != nil {
 		return err
 	}
-	fmt.Printf("create stack %s with uuid %s\n", stackModel.Name(), stackModel.Id())
+	if strict && len(file.Warnings) > 0 {
+		return fmt.Errorf("%s has %d warnings, fix them or validate it without --strict", filename, len(file.Warnings))
+	}
+	fmt.Printf("%s is a valid definition of stack %s\n", filename, file.Definition.Name)
+	return nil
+}
+
+// StackDiff shows the changes stacks:update would make to the stack with name or id from
+// the definition of filename. For CI to check drifts, it fails with an ExitError of code 1
+// when the stack differs from the file, and of code 2 when they cannot be compared.
+func StackDiff(name string, filename string) error {
+	file, err := readStackFile(filename)
+	if err != nil {
+		return ExitError{Code: 2, Err: err}
+	}
+
+	configRepository := config.NewConfigRepository(func(error) {})
+	stack, err := resolveStack(configRepository, name)
+	if err != nil {
+		return ExitError{Code: 2, Err: err}
+	}
+
+	diff := stackfile.Compare(stackfile.FromStack(stack), file.Definition)
+	err = render(diff, func() {
+		outputStackDiff(name, filename, diff)
+	})
+	if err != nil {
+		return ExitError{Code: 2, Err: err}
+	}
+	if !diff.Empty() {
+		return ExitError{Code: 1}
+	}
 	return nil
 }
 
-func getStackFileContent(filename string) (content []byte, err error) {
-	contents, err := ioutil.ReadFile(filename)
+func outputStackDiff(name string, filename string, diff stackfile.Diff) {
+	if diff.Empty() {
+		fmt.Printf("stack %s is up to date with %s\n", name, filename)
+		return
+	}
+
+	fmt.Printf("--- stack %s\n+++ %s\n", name, filename)
+	outputStackChanges(diff.Stack)
+	for _, service := range diff.Services {
+		mark := map[string]string{"added": "+", "removed": "-", "changed": "~"}[service.Status]
+		fmt.Printf("%s service %s\n", mark, service.Name)
+		outputStackChanges(service.Changes)
+	}
+}
+
+func outputStackChanges(changes []stackfile.Change) {
+	for _, change := range changes {
+		switch {
+		case change.Old == "":
+			fmt.Printf("  + %s: %s\n", change.Field, change.New)
+		case change.New == "":
+			fmt.Printf("  - %s: %s\n", change.Field, change.Old)
+		default:
+			fmt.Printf("  ~ %s: %s -> %s\n", change.Field, change.Old, change.New)
+		}
+	}
+}
+
+// StackExport writes the definition of the stack with name or id to filename, or to the
+// standard output when filename is empty, for it to be edited and sent back with
+// stacks:update.
+func StackExport(name string, filename string) error {
+	configRepository := config.NewConfigRepository(func(error) {})
+	stack, err := resolveStack(configRepository, name)
 	if err != nil {
-		return []byte{}, err
+		return err
+	}
+
+	content, err := stackfile.Marshal(stackfile.FromStack(stack))
+	if err != nil {
+		return err
+	}
+	if filename == "" {
+		fmt.Print(string(content))
+		return nil
 	}
-	return contents, err
+	if err := ioutil.WriteFile(filename, content, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("exported stack %s to %s\n", name, filename)
+	return nil
 }
 
 func StacksList() error {
@@ -56,34 +167,29 @@ func StacksList() error {
 		err = fmt.Errorf("no stack found")
 		return err
 	}
-	fmt.Printf("=== Stacks: [%d]\n", len(stacks.Items()))
+	return render(stacks.Items(), func() {
+		fmt.Printf("=== Stacks: [%d]\n", len(stacks.Items()))
 
-	for _, stack := range stacks.Items() {
-		fmt.Printf("name: %s id: %s\n", stack.Name(), stack.Id())
-	}
-	return nil
+		for _, stack := range stacks.Items() {
+			fmt.Printf("name: %s id: %s\n", stack.Name(), stack.Id())
+		}
+	})
 }
 
 func GetStack(stackName string) error {
 	configRepository := config.NewConfigRepository(func(error) {})
-	stackRepository := api.NewStackRepository(configRepository,
-		net.NewCloudControllerGateway(configRepository))
-	stacks, err := stackRepository.GetStackByName(stackName)
-
-	if err != nil || stacks.Count() == 0 {
-		err = fmt.Errorf("stack not found")
+	stackObj, err := resolveStack(configRepository, stackName)
+	if err != nil {
 		return err
 	}
 
-	stackId := stacks.Items()[0].Id()
-	stackObj, err := stackRepository.GetStack(stackId)
-
-	outputStackDescription(stackObj)
-	outputStackTemplate(stackObj.GetTemplate())
-	outputStackLanguages(stackObj.GetLanguages())
-	outputStackFrameworks(stackObj.GetFrameworks())
-	outputStackServices(stackObj.GetServices())
-	return nil
+	return render(stackObj, func() {
+		outputStackDescription(stackObj)
+		outputStackTemplate(stackObj.GetTemplate())
+		outputStackLanguages(stackObj.GetLanguages())
+		outputStackFrameworks(stackObj.GetFrameworks())
+		outputStackServices(stackObj.GetServices())
+	})
 }
 
 func outputStackDescription(stack api.Stack) {
@@ -211,16 +317,15 @@ func outputStackServices(services map[string]api.Service) {
 	}
 }
 
-func StackRemove(name string) error {
+func StackRemove(nameOrId string) error {
 	configRepository := config.NewConfigRepository(func(err error) {})
 	stackRepository := api.NewStackRepository(configRepository,
 		net.NewCloudControllerGateway(configRepository))
-	stacks, err := stackRepository.GetStackByName(name)
+	stack, err := resolveStack(configRepository, nameOrId)
 	if err != nil {
 		return err
 	}
-	stackId := stacks.Items()[0].Id()
-	err = stackRepository.Delete(stackId)
+	err = stackRepository.Delete(stack.Id())
 	if err != nil {
 		return err
 	}
@@ -228,44 +333,91 @@ func StackRemove(name string) error {
 	return nil
 }
 
-func StackUpdate(id string, filename string) error {
+// StackUpdate records the definition in filename as a new version of the stack with name or
+// id. Controllers that keep no stack versions update the stack in place instead.
+func StackUpdate(nameOrId string, filename string) error {
 	configRepository := config.NewConfigRepository(func(err error) {})
 	stackRepository := api.NewStackRepository(configRepository,
 		net.NewCloudControllerGateway(configRepository))
-	content, err := getStackFileContent(filename)
-	if err != nil {
-		fmt.Println(err)
-		return nil
-	}
-	content, err = yaml.YAMLToJSON(content)
+	file, err := readStackFile(filename)
 	if err != nil {
-		fmt.Printf("err: %v\n", err)
-	}
-	stackDefinition := make(map[string]interface{})
-	if err := json.Unmarshal(content, &stackDefinition); err != nil {
 		return err
 	}
 
-	stackModel, err := stackRepository.GetStack(id)
+	stackModel, err := resolveStack(configRepository, nameOrId)
 	if err != nil {
 		return err
 	}
 
-	err = stackModel.Update(stackDefinition)
+	version, err := stackRepository.CreateVersion(stackModel.Id(), file.Document)
+	if isNotFound(err) {
+		err = stackModel.Update(file.Document)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("updated stack %s with uuid %s\n", stackModel.Name(), stackModel.Id())
+		return nil
+	}
 	if err != nil {
 		return err
-	} else {
-		fmt.Printf("updated stack %s with uuid %s\n", stackModel.Name(), stackModel.Id())
 	}
+	fmt.Printf("updated stack %s with uuid %s to version %d\n", stackModel.Name(), stackModel.Id(), version.Version())
 	return nil
 }
 
-func StackPublish(id string) error {
+// StackVersions lists the versions of the stack with name or id, newest first, marking
+// the latest one, which the apps not pinned to a version use.
+func StackVersions(nameOrId string) error {
 	configRepository := config.NewConfigRepository(func(err error) {})
 	stackRepository := api.NewStackRepository(configRepository,
 		net.NewCloudControllerGateway(configRepository))
+	stack, err := resolveStack(configRepository, nameOrId)
+	if err != nil {
+		return err
+	}
 
-	stackModel, err := stackRepository.GetStack(id)
+	versions, err := allStackVersions(stackRepository, stack.Id())
+	if err != nil {
+		return err
+	}
+	return render(versions, func() {
+		fmt.Printf("=== %s Versions [%d]\n", stack.Name(), len(versions))
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetAlignment(tablewriter.ALIGN_LEFT)
+		table.SetHeader([]string{"Version", "Created At", "Latest"})
+		for _, version := range versions {
+			mark := ""
+			if version.Version() == stack.GetVersion() {
+				mark = "*"
+			}
+			createdAt := time.Unix(version.CreatedAt()/1000, 0).String()
+			table.Append([]string{strconv.Itoa(version.Version()), createdAt, mark})
+		}
+		table.Render()
+	})
+}
+
+// allStackVersions returns every version of the stack with id, newest first. It reports
+// the controllers that keep no stack versions.
+func allStackVersions(stackRepository api.StackRepository, id string) ([]api.StackVersion, error) {
+	page, err := stackRepository.GetStackVersions(id)
+	if isNotFound(err) {
+		return nil, errors.New("The controller keeps no versions of stacks")
+	}
+	var versions []api.StackVersion
+	for ; err == nil && page != nil; page, err = page.Next() {
+		versions = append(versions, page.Items()...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Version() > versions[j].Version() })
+	return versions, nil
+}
+
+func StackPublish(nameOrId string) error {
+	configRepository := config.NewConfigRepository(func(err error) {})
+	stackModel, err := resolveStack(configRepository, nameOrId)
 	if err != nil {
 		return err
 	}
@@ -279,12 +431,9 @@ func StackPublish(id string) error {
 	return nil
 }
 
-func StackUnPublish(id string) error {
+func StackUnPublish(nameOrId string) error {
 	configRepository := config.NewConfigRepository(func(err error) {})
-	stackRepository := api.NewStackRepository(configRepository,
-		net.NewCloudControllerGateway(configRepository))
-
-	stackModel, err := stackRepository.GetStack(id)
+	stackModel, err := resolveStack(configRepository, nameOrId)
 	if err != nil {
 		return err
 	}
@@ -28,29 +28,25 @@ func UpsList() error {
 		return err
 	}
 
-	fmt.Printf("=== Unified Procedures: [%d]\n", ups.Count())
-	for _, up := range ups.Items() {
-		fmt.Printf("name: %s; id: %s\n", up.Name(), up.Id())
-	}
-	return nil
+	return render(ups.Items(), func() {
+		fmt.Printf("=== Unified Procedures: [%d]\n", ups.Count())
+		for _, up := range ups.Items() {
+			fmt.Printf("name: %s; id: %s\n", up.Name(), up.Id())
+		}
+	})
 }
 
 func UpsInfo(upName string) error {
 	upsRepository := createUpsRepoository()
-	ups, err := upsRepository.GetUPByName(upName)
-
-	if err != nil || ups.Count() == 0 {
-		fmt.Printf("UP not found\n")
-		err = fmt.Errorf("up not found")
+	up, err := resolveUp(upsRepository, upName)
+	if err != nil {
 		return err
 	}
 
-	upId := ups.Items()[0].Id()
-	up, err := upsRepository.GetUP(upId)
-
-	outputUpDescription(up)
-	outputUpBuildProcedure(up)
-	return nil
+	return render(up, func() {
+		outputUpDescription(up)
+		outputUpBuildProcedure(up)
+	})
 }
 
 func UpCreate(filename string) error {
@@ -78,10 +74,13 @@ func UpCreate(filename string) error {
 }
 
 func UpRemove(idOrName string) error {
-	fmt.Println(idOrName)
 	upsRepository := createUpsRepoository()
+	up, err := resolveUp(upsRepository, idOrName)
+	if err != nil {
+		return err
+	}
 
-	err := upsRepository.RemoveUp(idOrName)
+	err = upsRepository.RemoveUp(up.Id())
 	if err != nil {
 		fmt.Println(err)
 		fmt.Printf("failed")
@@ -92,10 +91,13 @@ func UpRemove(idOrName string) error {
 }
 
 func UpPublish(idOrName string) error {
-	fmt.Println(idOrName)
 	upsRepository := createUpsRepoository()
+	up, err := resolveUp(upsRepository, idOrName)
+	if err != nil {
+		return err
+	}
 
-	err := upsRepository.PublishUp(idOrName)
+	err = upsRepository.PublishUp(up.Id())
 	if err != nil {
 		fmt.Println(err)
 		fmt.Printf("failed")
@@ -106,10 +108,13 @@ func UpPublish(idOrName string) error {
 }
 
 func UpDeprecate(idOrName string) error {
-	fmt.Println(idOrName)
 	upsRepository := createUpsRepoository()
+	up, err := resolveUp(upsRepository, idOrName)
+	if err != nil {
+		return err
+	}
 
-	err := upsRepository.DeprecateUp(idOrName)
+	err = upsRepository.DeprecateUp(up.Id())
 	if err != nil {
 		fmt.Println(err)
 		fmt.Printf("failed")
@@ -134,7 +139,12 @@ func UpUpdate(idOrName string, fileName string) error {
 		return err
 	}
 
-	err = upsRepository.UpdateUp(idOrName, upParams)
+	up, err := resolveUp(upsRepository, idOrName)
+	if err != nil {
+		return err
+	}
+
+	err = upsRepository.UpdateUp(up.Id(), upParams)
 	if err != nil {
 		fmt.Println(err)
 		fmt.Printf("failed")
@@ -153,7 +163,7 @@ func getUpFileContent(filename string) (content []byte, err error) {
 }
 
 func outputUpDescription(up api.Up) {
-	fmt.Println("--- Unified Procedures Detail\n")
+	fmt.Print("--- Unified Procedures Detail\n\n")
 
 	data := make([][]string, 3)
 	data[0] = []string{"id", up.Id()}
@@ -166,7 +176,7 @@ func outputUpDescription(up api.Up) {
 }
 
 func outputUpBuildProcedure(up api.Up) {
-	fmt.Println("--- Build Procedure Detail\n")
+	fmt.Print("--- Build Procedure Detail\n\n")
 
 	build, _ := up.GetProcedureByType("BUILD")
 
@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"fmt"
+
 	"github.com/cnupp/cli/config"
 	"github.com/cnupp/cli/pkg"
 )
@@ -27,3 +29,18 @@ func loadOrg(orgName string) (config.ConfigRepository, string) {
 
 	return configRepository, orgName
 }
+
+// ExitError fails a command with the code the cli exits with, e.g. the code of a command
+// run in the dev env. Err is reported when set, the command reported its failure itself
+// otherwise.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e ExitError) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("exit status %d", e.Code)
+}
@@ -1,16 +1,20 @@
 package config
 
 import (
+	"fmt"
 	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 )
 
 type ConfigRepository interface {
 	Reader
 	Writer
+	ProfileManager
 	Close()
 }
 
@@ -23,6 +27,13 @@ type Reader interface {
 	Auth() string
 	Id() string
 	Org() string
+	SSLVerify() bool
+	CACert() string
+	ClientCert() string
+	ClientKey() string
+	HTTPTimeout() time.Duration
+	HTTPRetries() int
+	HTTPBackoff() time.Duration
 }
 
 type Writer interface {
@@ -34,6 +45,27 @@ type Writer interface {
 	SetAuth(string)
 	SetId(string)
 	SetCurrentOrg(string)
+	SetSSLVerify(bool)
+	SetCACert(string)
+	SetClientCert(cert string, key string)
+}
+
+type ProfileManager interface {
+	ProfileName() string
+	ActiveProfile() string
+	ProfileNames() []string
+	GetProfile(name string) (Profile, bool)
+	UseProfile(name string) error
+	RemoveProfile(name string) error
+}
+
+// selectedProfile overrides the active profile for the current process, see SelectProfile.
+var selectedProfile string
+
+// SelectProfile makes every repository created afterwards read and write the named
+// profile instead of the active one, without switching the active profile on disk.
+func SelectProfile(name string) {
+	selectedProfile = name
 }
 
 type DefaultConfigRepository struct {
@@ -48,15 +80,18 @@ func NewConfigRepository(errorHandler func(error)) ConfigRepository {
 	if errorHandler == nil {
 		return nil
 	}
+	if os.Getenv(TokenEnv) != "" {
+		return NewRepositoryFromPersistor(NewEnvPersistor(), errorHandler)
+	}
 	path := DefaultFilePath()
-	return NewRepositoryFromPersistor(NewDiskPersistor(path), errorHandler)
+	return NewRepositoryFromPersistor(NewSecretPersistor(path), errorHandler)
 }
 
 func NewRepositoryFromFilepath(filepath string, errorHandler func(error)) ConfigRepository {
 	if errorHandler == nil {
 		return nil
 	}
-	return NewRepositoryFromPersistor(NewDiskPersistor(filepath), errorHandler)
+	return NewRepositoryFromPersistor(NewSecretPersistor(filepath), errorHandler)
 }
 
 func NewRepositoryFromPersistor(persistor Persistor, errorHandler func(error)) ConfigRepository {
@@ -102,35 +137,118 @@ func (c *DefaultConfigRepository) write(cb func()) {
 	}
 }
 
+func (c DefaultConfigRepository) profileName() string {
+	if selectedProfile != "" {
+		return selectedProfile
+	}
+	if name := os.Getenv("CDE_PROFILE"); name != "" {
+		return name
+	}
+	if c.data.Active != "" {
+		return c.data.Active
+	}
+	return DefaultProfileName
+}
+
+// profile returns the profile commands currently work against, an empty one if it does not exist.
+func (c DefaultConfigRepository) profile() *Profile {
+	if profile, ok := c.data.Profiles[c.profileName()]; ok {
+		return profile
+	}
+	return new(Profile)
+}
+
+func (c DefaultConfigRepository) writableProfile() *Profile {
+	return c.data.Profile(c.profileName())
+}
+
+func (c DefaultConfigRepository) ProfileName() (name string) {
+	c.read(func() {
+		name = c.profileName()
+	})
+	return
+}
+
+func (c DefaultConfigRepository) ActiveProfile() (name string) {
+	c.read(func() {
+		name = c.data.Active
+	})
+	return
+}
+
+func (c DefaultConfigRepository) ProfileNames() (names []string) {
+	c.read(func() {
+		for name := range c.data.Profiles {
+			names = append(names, name)
+		}
+	})
+	sort.Strings(names)
+	return
+}
+
+func (c DefaultConfigRepository) GetProfile(name string) (profile Profile, ok bool) {
+	c.read(func() {
+		var p *Profile
+		p, ok = c.data.Profiles[name]
+		if ok {
+			profile = *p
+		}
+	})
+	return
+}
+
+func (c DefaultConfigRepository) UseProfile(name string) error {
+	if _, ok := c.GetProfile(name); !ok {
+		return fmt.Errorf("profile %s not found", name)
+	}
+	c.write(func() {
+		c.data.Active = name
+	})
+	return nil
+}
+
+func (c DefaultConfigRepository) RemoveProfile(name string) error {
+	if _, ok := c.GetProfile(name); !ok {
+		return fmt.Errorf("profile %s not found", name)
+	}
+	c.write(func() {
+		delete(c.data.Profiles, name)
+		if c.data.Active == name {
+			c.data.Active = ""
+		}
+	})
+	return nil
+}
+
 func (c DefaultConfigRepository) Endpoint() (endpoint string) {
 	c.read(func() {
-		endpoint = c.data.Endpoint
+		endpoint = c.profile().Endpoint
 	})
 	return
 }
 
 func (c DefaultConfigRepository) SetEndpoint(endpoint string) {
 	c.write(func() {
-		c.data.Endpoint = endpoint
+		c.writableProfile().Endpoint = endpoint
 	})
 }
 
 func (c DefaultConfigRepository) ApiEndpoint() (endpoint string) {
 	c.read(func() {
-		endpoint = c.data.Endpoint
+		endpoint = c.profile().Endpoint
 	})
 	return
 }
 
 func (c DefaultConfigRepository) SetApiEndpoint(endpoint string) {
 	c.write(func() {
-		c.data.ApiEndpoint = endpoint
+		c.writableProfile().ApiEndpoint = endpoint
 	})
 }
 
 func (c DefaultConfigRepository) DeploymentEndpoint() (endpoint string) {
 	c.read(func() {
-		u, _ := url.Parse(c.data.Endpoint)
+		u, _ := url.Parse(c.profile().Endpoint)
 		parts := strings.Split(u.Host, ".")
 		parts[0] = "launcher"
 		host := strings.Join(parts, ".")
@@ -142,7 +260,7 @@ func (c DefaultConfigRepository) DeploymentEndpoint() (endpoint string) {
 
 func (c DefaultConfigRepository) SetDeploymentEndpoint(endpoint string) {
 	c.write(func() {
-		c.data.DeploymentEndpoint = endpoint
+		c.writableProfile().DeploymentEndpoint = endpoint
 	})
 }
 
@@ -156,62 +274,115 @@ func (c DefaultConfigRepository) GitHost() (gitHost string) {
 
 func (c DefaultConfigRepository) SetGitHost(gitHost string) {
 	c.write(func() {
-		c.data.GitHost = gitHost
+		c.writableProfile().GitHost = gitHost
 	})
 }
 
 func (c DefaultConfigRepository) SetEmail(email string) {
 	c.write(func() {
-		c.data.Email = email
+		c.writableProfile().Email = email
 	})
 }
 
 func (c DefaultConfigRepository) SetCurrentOrg(org string) {
 	c.write(func() {
-		c.data.Org = org
+		c.writableProfile().Org = org
 	})
 }
 
 func (c DefaultConfigRepository) SetAuth(auth string) {
 	c.write(func() {
-		c.data.Auth = auth
+		c.writableProfile().Auth = auth
 	})
 }
 
 func (c DefaultConfigRepository) SetId(id string) {
 	c.write(func() {
-		c.data.Id = id
+		c.writableProfile().Id = id
 	})
 }
 
 func (c DefaultConfigRepository) Email() (email string) {
 	c.read(func() {
-		email = c.data.Email
+		email = c.profile().Email
 	})
 	return
 }
 
 func (c DefaultConfigRepository) Org() (org string) {
 	c.read(func() {
-		org = c.data.Org
+		org = c.profile().Org
 	})
 	return
 }
 
 func (c DefaultConfigRepository) Auth() (auth string) {
 	c.read(func() {
-		auth = c.data.Auth
+		auth = c.profile().Auth
 	})
 	return
 }
 
 func (c DefaultConfigRepository) Id() (id string) {
 	c.read(func() {
-		id = c.data.Id
+		id = c.profile().Id
+	})
+	return
+}
+
+// SSLVerify tells whether the certificate of the controller is verified, which it is
+// unless the profile was logged in with --ssl-verify=false.
+func (c DefaultConfigRepository) SSLVerify() (verify bool) {
+	c.read(func() {
+		verify = !c.profile().SkipSSLVerify
+	})
+	return
+}
+
+func (c DefaultConfigRepository) SetSSLVerify(verify bool) {
+	c.write(func() {
+		c.writableProfile().SkipSSLVerify = !verify
+	})
+}
+
+// CACert is the path of a PEM bundle trusted on top of the system certificates.
+func (c DefaultConfigRepository) CACert() (path string) {
+	c.read(func() {
+		path = c.profile().CACert
+	})
+	return
+}
+
+func (c DefaultConfigRepository) SetCACert(path string) {
+	c.write(func() {
+		c.writableProfile().CACert = path
+	})
+}
+
+// ClientCert is the path of the PEM certificate presented to the controller, if any.
+func (c DefaultConfigRepository) ClientCert() (path string) {
+	c.read(func() {
+		path = c.profile().ClientCert
+	})
+	return
+}
+
+// ClientKey is the path of the PEM private key of ClientCert.
+func (c DefaultConfigRepository) ClientKey() (path string) {
+	c.read(func() {
+		path = c.profile().ClientKey
 	})
 	return
 }
 
+func (c DefaultConfigRepository) SetClientCert(cert string, key string) {
+	c.write(func() {
+		profile := c.writableProfile()
+		profile.ClientCert = cert
+		profile.ClientKey = key
+	})
+}
+
 func (c DefaultConfigRepository) Close() {
 	c.read(func() {
 		// perform a read to ensure write lock has been cleared
@@ -2,7 +2,9 @@ package config
 
 import "encoding/json"
 
-type Data struct {
+const DefaultProfileName = "default"
+
+type Profile struct {
 	Email              string `json:"email"`
 	Endpoint           string `json:"endpoint"`
 	ApiEndpoint        string `json:"api_endpoint"`
@@ -11,13 +13,48 @@ type Data struct {
 	Auth               string `json:"auth"`
 	Id                 string `json:"id"`
 	Org                string `json:"org"`
+	SkipSSLVerify      bool   `json:"skip_ssl_verify,omitempty"`
+	CACert             string `json:"ca_cert,omitempty"`
+	ClientCert         string `json:"client_cert,omitempty"`
+	ClientKey          string `json:"client_key,omitempty"`
+}
+
+// HTTPSettings tune the requests sent to the controllers. Durations are written the Go
+// way, e.g. "30s" or "500ms", and empty values fall back to the defaults.
+type HTTPSettings struct {
+	Timeout string `json:"timeout,omitempty"`
+	Retries *int   `json:"retries,omitempty"`
+	Backoff string `json:"backoff,omitempty"`
+}
+
+type Data struct {
+	Active   string              `json:"active"`
+	Profiles map[string]*Profile `json:"profiles"`
+	HTTP     HTTPSettings        `json:"http"`
+	// SecretStore names the store keeping the auths out of this file, see SecretPersistor.
+	SecretStore string `json:"secret_store,omitempty"`
 }
 
 func NewData() (data *Data) {
 	data = new(Data)
+	data.Profiles = make(map[string]*Profile)
 	return
 }
 
+// Profile returns the named profile, creating an empty one if it does not exist yet.
+// The first profile ever created becomes the active one.
+func (d *Data) Profile(name string) *Profile {
+	profile, ok := d.Profiles[name]
+	if !ok {
+		profile = new(Profile)
+		d.Profiles[name] = profile
+	}
+	if d.Active == "" {
+		d.Active = name
+	}
+	return profile
+}
+
 func (d *Data) JsonMarshalV3() (output []byte, err error) {
 	return json.MarshalIndent(d, "", "  ")
 }
@@ -28,5 +65,22 @@ func (d *Data) JsonUnmarshalV3(input []byte) (err error) {
 		return
 	}
 
+	if d.Profiles == nil {
+		d.Profiles = make(map[string]*Profile)
+	}
+
+	// config files written before profiles existed keep a single controller at the top level
+	if len(d.Profiles) == 0 {
+		legacy := new(Profile)
+		err = json.Unmarshal(input, legacy)
+		if err != nil {
+			return
+		}
+		if *legacy != (Profile{}) {
+			d.Profiles[DefaultProfileName] = legacy
+			d.Active = DefaultProfileName
+		}
+	}
+
 	return
 }
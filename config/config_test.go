@@ -0,0 +1,276 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func tempConfigPath(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "cde-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "config.json")
+	if content != "" {
+		if err := ioutil.WriteFile(path, []byte(content), filePermissions); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func TestLegacyConfigBecomesDefaultProfile(t *testing.T) {
+	path := tempConfigPath(t, `{"email": "a@tw.com", "endpoint": "http://cde.local", "auth": "token"}`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	repo := NewRepositoryFromFilepath(path, func(err error) { t.Fatal(err) })
+
+	if repo.ActiveProfile() != DefaultProfileName {
+		t.Errorf("Expected active profile %s, got %s", DefaultProfileName, repo.ActiveProfile())
+	}
+	if repo.Email() != "a@tw.com" || repo.Auth() != "token" || repo.Endpoint() != "http://cde.local" {
+		t.Errorf("Expected legacy fields to be kept, got %s %s %s", repo.Email(), repo.Auth(), repo.Endpoint())
+	}
+}
+
+func TestSelectedProfileDoesNotSwitchActive(t *testing.T) {
+	path := tempConfigPath(t, "")
+	defer os.RemoveAll(filepath.Dir(path))
+
+	repo := NewRepositoryFromFilepath(path, func(err error) { t.Fatal(err) })
+	repo.SetEndpoint("http://local")
+
+	SelectProfile("prod")
+	defer SelectProfile("")
+	repo = NewRepositoryFromFilepath(path, func(err error) { t.Fatal(err) })
+	repo.SetEndpoint("http://prod")
+
+	if repo.Endpoint() != "http://prod" {
+		t.Errorf("Expected selected profile endpoint, got %s", repo.Endpoint())
+	}
+	if repo.ActiveProfile() != DefaultProfileName {
+		t.Errorf("Expected active profile to stay %s, got %s", DefaultProfileName, repo.ActiveProfile())
+	}
+
+	SelectProfile("")
+	repo = NewRepositoryFromFilepath(path, func(err error) { t.Fatal(err) })
+	if repo.Endpoint() != "http://local" {
+		t.Errorf("Expected active profile endpoint, got %s", repo.Endpoint())
+	}
+	if names := repo.ProfileNames(); len(names) != 2 {
+		t.Errorf("Expected 2 profiles, got %v", names)
+	}
+}
+
+func TestUseAndRemoveProfile(t *testing.T) {
+	path := tempConfigPath(t, `{"active": "local", "profiles": {"local": {"endpoint": "http://local"}, "prod": {"endpoint": "http://prod"}}}`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	repo := NewRepositoryFromFilepath(path, func(err error) { t.Fatal(err) })
+	if err := repo.UseProfile("staging"); err == nil {
+		t.Error("Expected an error when using an unknown profile")
+	}
+	if err := repo.UseProfile("prod"); err != nil {
+		t.Fatal(err)
+	}
+	if repo.Endpoint() != "http://prod" {
+		t.Errorf("Expected prod endpoint, got %s", repo.Endpoint())
+	}
+	if err := repo.RemoveProfile("prod"); err != nil {
+		t.Fatal(err)
+	}
+	if repo.ActiveProfile() != "" {
+		t.Errorf("Expected no active profile, got %s", repo.ActiveProfile())
+	}
+}
+
+func TestHTTPSettings(t *testing.T) {
+	path := tempConfigPath(t, `{"active": "default", "profiles": {}, "http": {"timeout": "10s", "retries": 0}}`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	repo := NewRepositoryFromFilepath(path, func(err error) { t.Fatal(err) })
+	if repo.HTTPTimeout() != 10*time.Second || repo.HTTPRetries() != 0 || repo.HTTPBackoff() != DefaultHTTPBackoff {
+		t.Errorf("Expected the configured settings, got %v %d %v", repo.HTTPTimeout(), repo.HTTPRetries(), repo.HTTPBackoff())
+	}
+
+	os.Setenv("CDE_HTTP_TIMEOUT", "1m")
+	os.Setenv("CDE_HTTP_RETRIES", "5")
+	defer os.Unsetenv("CDE_HTTP_TIMEOUT")
+	defer os.Unsetenv("CDE_HTTP_RETRIES")
+	if repo.HTTPTimeout() != time.Minute || repo.HTTPRetries() != 5 {
+		t.Errorf("Expected the environment to override the config file, got %v %d", repo.HTTPTimeout(), repo.HTTPRetries())
+	}
+}
+
+func TestTLSSettingsArePerProfile(t *testing.T) {
+	path := tempConfigPath(t, `{"active": "local", "profiles": {"local": {"endpoint": "https://local"}, "prod": {"endpoint": "https://prod"}}}`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	repo := NewRepositoryFromFilepath(path, func(err error) { t.Fatal(err) })
+	if !repo.SSLVerify() {
+		t.Error("Expected certificates to be verified by default")
+	}
+	repo.SetSSLVerify(false)
+	repo.SetCACert("/etc/cde/ca.pem")
+	repo.SetClientCert("/etc/cde/client.pem", "/etc/cde/client-key.pem")
+
+	repo = NewRepositoryFromFilepath(path, func(err error) { t.Fatal(err) })
+	if repo.SSLVerify() || repo.CACert() != "/etc/cde/ca.pem" || repo.ClientCert() != "/etc/cde/client.pem" || repo.ClientKey() != "/etc/cde/client-key.pem" {
+		t.Errorf("Expected the TLS settings to be persisted, got %v %s %s %s", repo.SSLVerify(), repo.CACert(), repo.ClientCert(), repo.ClientKey())
+	}
+
+	if err := repo.UseProfile("prod"); err != nil {
+		t.Fatal(err)
+	}
+	if !repo.SSLVerify() || repo.CACert() != "" {
+		t.Errorf("Expected the TLS settings of local not to leak into prod, got %v %s", repo.SSLVerify(), repo.CACert())
+	}
+}
+
+func TestTokenFromEnvironment(t *testing.T) {
+	home, err := ioutil.TempDir("", "cde-home")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+	previousHome := os.Getenv("CDE_HOME")
+	os.Setenv("CDE_HOME", home)
+	defer os.Setenv("CDE_HOME", previousHome)
+
+	os.Setenv("CDE_TOKEN", "token")
+	os.Setenv("CDE_ENDPOINT", "https://cde.local")
+	os.Setenv("CDE_SSL_VERIFY", "false")
+	defer os.Unsetenv("CDE_TOKEN")
+	defer os.Unsetenv("CDE_ENDPOINT")
+	defer os.Unsetenv("CDE_SSL_VERIFY")
+
+	repo := NewConfigRepository(func(err error) { t.Fatal(err) })
+	if repo.Auth() != "token" || repo.Endpoint() != "https://cde.local" || repo.SSLVerify() {
+		t.Errorf("Expected the settings of the environment, got %s %s %v", repo.Auth(), repo.Endpoint(), repo.SSLVerify())
+	}
+	repo.SetAuth("renewed")
+	if repo.Auth() != "renewed" {
+		t.Errorf("Expected changes to be kept in memory, got %s", repo.Auth())
+	}
+	if _, err := os.Stat(filepath.Join(home, ".cde")); !os.IsNotExist(err) {
+		t.Errorf("Expected no config to be written, got %v", err)
+	}
+
+	os.Unsetenv("CDE_ENDPOINT")
+	failed := false
+	NewConfigRepository(func(err error) { failed = true })
+	if !failed {
+		t.Error("Expected a missing CDE_ENDPOINT to be reported")
+	}
+}
+
+func TestSecretStoreKeepsAuthOutOfConfig(t *testing.T) {
+	path := tempConfigPath(t, `{"active": "default", "secret_store": "file", "profiles": {"default": {"endpoint": "http://local", "auth": "legacy-token"}}}`)
+	defer os.RemoveAll(filepath.Dir(path))
+	os.Setenv("CDE_PASSPHRASE", "passphrase")
+	defer os.Unsetenv("CDE_PASSPHRASE")
+
+	repo := NewRepositoryFromFilepath(path, func(err error) { t.Fatal(err) })
+	if repo.Auth() != "legacy-token" {
+		t.Errorf("Expected the auth still in config.json to be read, got %s", repo.Auth())
+	}
+	repo.SetAuth("secret-token")
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), "token") || !strings.Contains(string(content), "http://local") {
+		t.Errorf("Expected only the auth to leave config.json, got %s", content)
+	}
+	secrets, err := ioutil.ReadFile(filepath.Join(filepath.Dir(path), "secrets"))
+	if err != nil || strings.Contains(string(secrets), "secret-token") {
+		t.Errorf("Expected the auth to be encrypted in the secret store, got %q (%v)", secrets, err)
+	}
+
+	repo = NewRepositoryFromFilepath(path, func(err error) { t.Fatal(err) })
+	if repo.Auth() != "secret-token" || repo.Endpoint() != "http://local" {
+		t.Errorf("Expected the auth to be read back from the store, got %s %s", repo.Auth(), repo.Endpoint())
+	}
+}
+
+func TestFileSecretStore(t *testing.T) {
+	path := filepath.Join(filepath.Dir(tempConfigPath(t, "")), "secrets")
+	defer os.RemoveAll(filepath.Dir(path))
+	passphrase := func(value string) func(bool) (string, error) {
+		return func(bool) (string, error) { return value, nil }
+	}
+
+	store := NewFileSecretStore(path, passphrase("right"))
+	if err := store.Set("prod", "token"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		passphrase string
+		value      string
+		fails      bool
+	}{
+		{"right", "token", false},
+		{"wrong", "", true},
+	}
+	for _, test := range tests {
+		value, err := NewFileSecretStore(path, passphrase(test.passphrase)).Get("prod")
+		if value != test.value || (err != nil) != test.fails {
+			t.Errorf("Passphrase %s: expected %q and failure %v, got %q (%v)", test.passphrase, test.value, test.fails, value, err)
+		}
+	}
+
+	if err := store.Delete("prod"); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := NewFileSecretStore(path, passphrase("right")).Get("prod"); value != "" {
+		t.Errorf("Expected the secret to be deleted, got %s", value)
+	}
+}
+
+func TestPassphrase(t *testing.T) {
+	passphrase, prompt := os.Getenv("CDE_PASSPHRASE"), PassphrasePrompt
+	defer func() {
+		os.Setenv("CDE_PASSPHRASE", passphrase)
+		PassphrasePrompt = prompt
+	}()
+
+	tests := []struct {
+		env     string
+		answers []string
+		create  bool
+		value   string
+		fails   bool
+	}{
+		{env: "from-env", create: true, value: "from-env"},
+		{answers: []string{"typed"}, value: "typed"},
+		{answers: []string{"typed", "typed"}, create: true, value: "typed"},
+		{answers: []string{"typed", "mistyped"}, create: true, fails: true},
+		{fails: true},
+	}
+	for _, test := range tests {
+		os.Setenv("CDE_PASSPHRASE", test.env)
+		PassphrasePrompt = nil
+		answers := test.answers
+		if answers != nil {
+			PassphrasePrompt = func(string) (string, error) {
+				if len(answers) == 0 {
+					t.Fatalf("Passphrase asked more than %v", test.answers)
+				}
+				answer := answers[0]
+				answers = answers[1:]
+				return answer, nil
+			}
+		}
+
+		value, err := Passphrase(test.create)
+		if value != test.value || (err != nil) != test.fails {
+			t.Errorf("Passphrase(%v) answering %v: expected %q and failure %v, got %q (%v)", test.create, test.answers, test.value, test.fails, value, err)
+		}
+	}
+}
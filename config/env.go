@@ -0,0 +1,66 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+)
+
+// TokenEnv holds a pre-issued auth. When it is set the configuration comes from the
+// environment only and config.json is neither read nor written.
+const TokenEnv = "CDE_TOKEN"
+
+// EnvPersistor keeps the configuration in memory, seeded from CDE_TOKEN, CDE_ENDPOINT,
+// CDE_EMAIL, CDE_CA_CERT and CDE_SSL_VERIFY.
+type EnvPersistor struct{}
+
+func NewEnvPersistor() EnvPersistor {
+	return EnvPersistor{}
+}
+
+func (EnvPersistor) Exists() bool {
+	return true
+}
+
+func (EnvPersistor) Delete() {}
+
+func (EnvPersistor) Load(data DataInterface) error {
+	endpoint := os.Getenv("CDE_ENDPOINT")
+	seed := NewData()
+	seed.Active = DefaultProfileName
+	seed.Profiles[envProfileName()] = &Profile{
+		Auth:          os.Getenv(TokenEnv),
+		Endpoint:      endpoint,
+		Email:         os.Getenv("CDE_EMAIL"),
+		CACert:        os.Getenv("CDE_CA_CERT"),
+		SkipSSLVerify: os.Getenv("CDE_SSL_VERIFY") == "false",
+	}
+
+	bytes, err := json.Marshal(seed)
+	if err != nil {
+		return err
+	}
+	if err := data.JsonUnmarshalV3(bytes); err != nil {
+		return err
+	}
+
+	if endpoint == "" {
+		return errors.New("CDE_TOKEN is set but CDE_ENDPOINT is not, set it to the controller URI")
+	}
+	return nil
+}
+
+// Save keeps the changes in memory, for the current process only.
+func (EnvPersistor) Save(DataInterface) error {
+	return nil
+}
+
+func envProfileName() string {
+	if selectedProfile != "" {
+		return selectedProfile
+	}
+	if name := os.Getenv("CDE_PROFILE"); name != "" {
+		return name
+	}
+	return DefaultProfileName
+}
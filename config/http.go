@@ -0,0 +1,58 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	DefaultHTTPTimeout = 30 * time.Second
+	DefaultHTTPRetries = 3
+	DefaultHTTPBackoff = 500 * time.Millisecond
+)
+
+// HTTPTimeout bounds connecting to a controller and waiting for its response, it is read
+// from CDE_HTTP_TIMEOUT first and then from the config file.
+func (c DefaultConfigRepository) HTTPTimeout() (timeout time.Duration) {
+	c.read(func() {
+		timeout = durationSetting("CDE_HTTP_TIMEOUT", c.data.HTTP.Timeout, DefaultHTTPTimeout)
+	})
+	return
+}
+
+// HTTPRetries is how many times a failed request is sent again, it is read from
+// CDE_HTTP_RETRIES first and then from the config file.
+func (c DefaultConfigRepository) HTTPRetries() (retries int) {
+	c.read(func() {
+		retries = DefaultHTTPRetries
+		if c.data.HTTP.Retries != nil {
+			retries = *c.data.HTTP.Retries
+		}
+		if value, err := strconv.Atoi(os.Getenv("CDE_HTTP_RETRIES")); err == nil {
+			retries = value
+		}
+		if retries < 0 {
+			retries = 0
+		}
+	})
+	return
+}
+
+// HTTPBackoff is the delay before the first retry, doubled for every further one. It is
+// read from CDE_HTTP_BACKOFF first and then from the config file.
+func (c DefaultConfigRepository) HTTPBackoff() (backoff time.Duration) {
+	c.read(func() {
+		backoff = durationSetting("CDE_HTTP_BACKOFF", c.data.HTTP.Backoff, DefaultHTTPBackoff)
+	})
+	return
+}
+
+func durationSetting(env string, configured string, defaultValue time.Duration) time.Duration {
+	for _, value := range []string{os.Getenv(env), configured} {
+		if duration, err := time.ParseDuration(value); err == nil && duration > 0 {
+			return duration
+		}
+	}
+	return defaultValue
+}
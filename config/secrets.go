@@ -0,0 +1,304 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// SecretStore keeps the secrets of the profiles, keyed by profile name, out of config.json.
+type SecretStore interface {
+	Get(key string) (string, error)
+	Set(key string, value string) error
+	Delete(key string) error
+}
+
+// secretStores opens the stores that can be selected with the secret_store setting, from
+// the path of config.json.
+var secretStores = map[string]func(configPath string) (SecretStore, error){
+	"file": func(configPath string) (SecretStore, error) {
+		return NewFileSecretStore(filepath.Join(filepath.Dir(configPath), "secrets"), Passphrase), nil
+	},
+}
+
+// RegisterSecretStore makes a store selectable with "secret_store": name in config.json.
+func RegisterSecretStore(name string, open func(configPath string) (SecretStore, error)) {
+	secretStores[name] = open
+}
+
+// SecretStoreNames returns the stores that can be selected.
+func SecretStoreNames() (names []string) {
+	for name := range secretStores {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// PassphrasePrompt asks the user for the passphrase of the file secret store. Without
+// one, the passphrase can only be read from CDE_PASSPHRASE.
+var PassphrasePrompt func(prompt string) (string, error)
+
+// Passphrase returns the passphrase of the file secret store, read from CDE_PASSPHRASE or
+// asked with PassphrasePrompt. It is asked twice when the store is created.
+func Passphrase(create bool) (string, error) {
+	if passphrase := os.Getenv("CDE_PASSPHRASE"); passphrase != "" {
+		return passphrase, nil
+	}
+	if PassphrasePrompt == nil {
+		return "", errors.New("the secret store needs a passphrase, set CDE_PASSPHRASE")
+	}
+	passphrase, err := PassphrasePrompt("passphrase of the secret store: ")
+	if err != nil || !create {
+		return passphrase, err
+	}
+	confirmation, err := PassphrasePrompt("confirm the passphrase of the new secret store: ")
+	if err != nil {
+		return "", err
+	}
+	if confirmation != passphrase {
+		return "", errors.New("the passphrases do not match")
+	}
+	return passphrase, nil
+}
+
+// openedStores keeps the stores opened by the process, so that the passphrase is asked
+// and the key derived once however many repositories are created.
+var (
+	openedStores = make(map[string]SecretStore)
+	storesMutex  sync.Mutex
+)
+
+// SecretPersistor saves config.json through DiskPersistor, leaving the auth of the profiles
+// to the secret store selected in it. Without one, the auths stay in config.json.
+type SecretPersistor struct {
+	DiskPersistor
+}
+
+func NewSecretPersistor(path string) SecretPersistor {
+	return SecretPersistor{DiskPersistor: NewDiskPersistor(path)}
+}
+
+func (sp SecretPersistor) Load(data DataInterface) error {
+	if err := sp.DiskPersistor.Load(data); err != nil {
+		return err
+	}
+	d, ok := data.(*Data)
+	if !ok || d.SecretStore == "" {
+		return nil
+	}
+
+	store, err := sp.store(d.SecretStore)
+	if err != nil {
+		return err
+	}
+	for name, profile := range d.Profiles {
+		auth, err := store.Get(name)
+		if err != nil {
+			return err
+		}
+		// auths still in config.json move to the store on the next save
+		if auth != "" {
+			profile.Auth = auth
+		}
+	}
+	return nil
+}
+
+func (sp SecretPersistor) Save(data DataInterface) error {
+	d, ok := data.(*Data)
+	if !ok || d.SecretStore == "" {
+		return sp.DiskPersistor.Save(data)
+	}
+
+	store, err := sp.store(d.SecretStore)
+	if err != nil {
+		return err
+	}
+
+	public := *d
+	public.Profiles = make(map[string]*Profile, len(d.Profiles))
+	for name, profile := range d.Profiles {
+		if profile.Auth != "" {
+			err = store.Set(name, profile.Auth)
+		} else {
+			err = store.Delete(name)
+		}
+		if err != nil {
+			return err
+		}
+		stripped := *profile
+		stripped.Auth = ""
+		public.Profiles[name] = &stripped
+	}
+	return sp.DiskPersistor.Save(&public)
+}
+
+func (sp SecretPersistor) store(name string) (SecretStore, error) {
+	storesMutex.Lock()
+	defer storesMutex.Unlock()
+
+	key := name + ":" + sp.filePath
+	if store, ok := openedStores[key]; ok {
+		return store, nil
+	}
+	open, ok := secretStores[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown secret store %s, use one of %v", name, SecretStoreNames())
+	}
+	store, err := open(sp.filePath)
+	if err != nil {
+		return nil, err
+	}
+	openedStores[key] = store
+	return store, nil
+}
+
+const (
+	saltSize = 16
+	keySize  = 32
+)
+
+// FileSecretStore keeps the secrets in a file encrypted with AES-GCM, under a key derived
+// from a passphrase with scrypt.
+type FileSecretStore struct {
+	path       string
+	passphrase func(create bool) (string, error)
+	secrets    map[string]string
+	key        []byte
+	salt       []byte
+}
+
+// NewFileSecretStore opens the store at path once it is first used, passphrase is told
+// whether the store is being created.
+func NewFileSecretStore(path string, passphrase func(create bool) (string, error)) *FileSecretStore {
+	return &FileSecretStore{path: path, passphrase: passphrase}
+}
+
+func (fs *FileSecretStore) Get(key string) (string, error) {
+	if err := fs.open(); err != nil {
+		return "", err
+	}
+	return fs.secrets[key], nil
+}
+
+func (fs *FileSecretStore) Set(key string, value string) error {
+	if err := fs.open(); err != nil {
+		return err
+	}
+	if fs.secrets[key] == value {
+		return nil
+	}
+	fs.secrets[key] = value
+	return fs.write()
+}
+
+func (fs *FileSecretStore) Delete(key string) error {
+	if err := fs.open(); err != nil {
+		return err
+	}
+	if _, ok := fs.secrets[key]; !ok {
+		return nil
+	}
+	delete(fs.secrets, key)
+	return fs.write()
+}
+
+// open decrypts the file once, an absent file is an empty store.
+func (fs *FileSecretStore) open() error {
+	if fs.secrets != nil {
+		return nil
+	}
+
+	content, err := ioutil.ReadFile(fs.path)
+	if os.IsNotExist(err) {
+		passphrase, err := fs.passphrase(true)
+		if err != nil {
+			return err
+		}
+		fs.salt = make([]byte, saltSize)
+		if _, err := rand.Read(fs.salt); err != nil {
+			return err
+		}
+		if fs.key, err = deriveKey(passphrase, fs.salt); err != nil {
+			return err
+		}
+		fs.secrets = make(map[string]string)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(content) < saltSize {
+		return fmt.Errorf("%s is not a secret store", fs.path)
+	}
+	passphrase, err := fs.passphrase(false)
+	if err != nil {
+		return err
+	}
+	fs.salt = content[:saltSize]
+	if fs.key, err = deriveKey(passphrase, fs.salt); err != nil {
+		return err
+	}
+	aead, err := newAEAD(fs.key)
+	if err != nil {
+		return err
+	}
+	sealed := content[saltSize:]
+	if len(sealed) < aead.NonceSize() {
+		return fmt.Errorf("%s is not a secret store", fs.path)
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return fmt.Errorf("cannot decrypt %s, the passphrase is wrong", fs.path)
+	}
+
+	secrets := make(map[string]string)
+	if err := json.Unmarshal(plain, &secrets); err != nil {
+		return err
+	}
+	fs.secrets = secrets
+	return nil
+}
+
+func (fs *FileSecretStore) write() error {
+	plain, err := json.Marshal(fs.secrets)
+	if err != nil {
+		return err
+	}
+	aead, err := newAEAD(fs.key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	content := append(append([]byte{}, fs.salt...), nonce...)
+	content = aead.Seal(content, nonce, plain, nil)
+	return ioutil.WriteFile(fs.path, content, filePermissions)
+}
+
+func deriveKey(passphrase string, salt []byte) ([]byte, error) {
+	return scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, keySize)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
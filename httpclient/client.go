@@ -0,0 +1,238 @@
+// Package httpclient builds the http client shared by every request sent to a controller.
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Settings tune the requests sent to a controller.
+type Settings struct {
+	// Timeout bounds connecting to the controller and waiting for its response headers.
+	// It does not apply to reading or writing bodies, so that large uploads still work.
+	Timeout time.Duration
+	// Retries is how many times a failed request is sent again.
+	Retries int
+	// Backoff is the delay before the first retry, doubled for every further one.
+	Backoff time.Duration
+	// TLS replaces the tls configuration of the base transport when set.
+	TLS *tls.Config
+	// Reauthenticate, when set, is called once the controller rejected the auth sent with
+	// req. The request is then sent again with the auth it returns.
+	Reauthenticate func(req *http.Request) (string, error)
+}
+
+// ErrSessionExpired fails the requests whose auth the controller rejected, when it could
+// not be renewed. Requests sent without auth get the response of the controller, as they
+// are rejected for their own reasons, e.g. wrong credentials.
+var ErrSessionExpired = errors.New("Your session has expired, please log in again.")
+
+const maxIdleConnsPerHost = 10
+
+// NewClient returns a client built on top of http.DefaultTransport.
+func NewClient(settings Settings) *http.Client {
+	return &http.Client{Transport: NewTransport(settings, http.DefaultTransport)}
+}
+
+// NewTransport wraps base with the timeouts and retries of settings. When base is an
+// *http.Transport it is copied, keeping its connection pool separate from base.
+func NewTransport(settings Settings, base http.RoundTripper) http.RoundTripper {
+	if transport, ok := base.(*http.Transport); ok {
+		transport = transport.Clone()
+		if settings.Timeout > 0 {
+			transport.DialContext = dialWithTimeout(transport.DialContext, settings.Timeout)
+			transport.TLSHandshakeTimeout = settings.Timeout
+			transport.ResponseHeaderTimeout = settings.Timeout
+		}
+		if settings.TLS != nil {
+			transport.TLSClientConfig = settings.TLS
+		}
+		transport.MaxIdleConnsPerHost = maxIdleConnsPerHost
+		base = transport
+	}
+
+	var transport http.RoundTripper = &retryTransport{
+		base:    reuseTransport{base},
+		retries: settings.Retries,
+		backoff: settings.Backoff,
+	}
+	return &sessionTransport{base: transport, reauthenticate: settings.Reauthenticate}
+}
+
+// reuseTransport keeps the connections of the requests asking to close them open for the
+// next requests, as the sdk gateways ask it of every request they send.
+type reuseTransport struct {
+	base http.RoundTripper
+}
+
+func (t reuseTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Close {
+		req = req.Clone(req.Context())
+		req.Close = false
+	}
+	return t.base.RoundTrip(req)
+}
+
+type dialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+func dialWithTimeout(dial dialFunc, timeout time.Duration) dialFunc {
+	if dial == nil {
+		dial = (&net.Dialer{KeepAlive: 30 * time.Second}).DialContext
+	}
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return dial(ctx, network, address)
+	}
+}
+
+// retryTransport sends a request again when it failed in a way that is safe to retry:
+// idempotent requests on connection errors and gateway errors, any request when the
+// connection could not even be established.
+type retryTransport struct {
+	base    http.RoundTripper
+	retries int
+	backoff time.Duration
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		res, err := t.base.RoundTrip(req)
+		if attempt >= t.retries || !shouldRetry(req, res, err) || !rewindable(req) {
+			return res, err
+		}
+		if res != nil {
+			io.Copy(ioutil.Discard, res.Body)
+			res.Body.Close()
+		}
+
+		select {
+		case <-time.After(t.backoff << uint(attempt)):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+
+		if req, err = rewind(req); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func shouldRetry(req *http.Request, res *http.Response, err error) bool {
+	if err != nil {
+		if req.Context().Err() != nil || isCertificateError(err) {
+			return false
+		}
+		return isIdempotent(req.Method) || isDialError(err)
+	}
+
+	switch res.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return isIdempotent(req.Method)
+	}
+	return false
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "TRACE", "PUT", "DELETE":
+		return true
+	}
+	return false
+}
+
+// isDialError tells whether err happened before anything was sent to the controller.
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// isCertificateError tells whether err is a rejected certificate, which sending the
+// request again will not fix.
+func isCertificateError(err error) bool {
+	var verificationErr *tls.CertificateVerificationError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	return errors.As(err, &verificationErr) || errors.As(err, &authorityErr) ||
+		errors.As(err, &hostnameErr) || errors.As(err, &invalidErr)
+}
+
+func rewindable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// rewind returns a copy of req that can be sent again.
+func rewind(req *http.Request) (*http.Request, error) {
+	body := req.Body
+	if body != nil && body != http.NoBody {
+		var err error
+		if body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	req = req.Clone(req.Context())
+	req.Body = body
+	return req, nil
+}
+
+// sessionTransport renews the auth of the user once the controller rejected it, and sends
+// the rejected request again. The user is asked at most once per process: requests rejected
+// with an auth that was already renewed get the new one, and a failed renewal is not retried.
+// The requests whose auth could not be renewed fail with ErrSessionExpired.
+type sessionTransport struct {
+	base           http.RoundTripper
+	reauthenticate func(req *http.Request) (string, error)
+
+	mutex   sync.Mutex
+	renewed string
+	failed  bool
+}
+
+func (t *sessionTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := t.base.RoundTrip(req)
+	expired := req.Header.Get("Authorization")
+	if err != nil || res.StatusCode != http.StatusUnauthorized || expired == "" || !rewindable(req) {
+		return res, err
+	}
+
+	io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+	auth, ok := t.renew(req, expired)
+	if !ok {
+		return nil, ErrSessionExpired
+	}
+
+	if req, err = rewind(req); err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", auth)
+	return t.base.RoundTrip(req)
+}
+
+func (t *sessionTransport) renew(req *http.Request, expired string) (string, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.renewed != "" && t.renewed != expired {
+		return t.renewed, true
+	}
+	if t.failed || t.reauthenticate == nil {
+		return "", false
+	}
+	auth, err := t.reauthenticate(req)
+	if err != nil || auth == "" {
+		t.failed = true
+		return "", false
+	}
+	t.renewed = auth
+	return auth, true
+}
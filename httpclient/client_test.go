@@ -0,0 +1,187 @@
+package httpclient
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRetries(t *testing.T) {
+	tests := []struct {
+		method   string
+		failures int
+		retries  int
+		status   int
+		calls    int
+	}{
+		{"GET", 2, 3, http.StatusOK, 3},
+		{"GET", 5, 3, http.StatusBadGateway, 4},
+		{"PUT", 1, 3, http.StatusOK, 2},
+		{"POST", 1, 3, http.StatusBadGateway, 1},
+		{"GET", 1, 0, http.StatusBadGateway, 1},
+	}
+
+	for _, test := range tests {
+		calls := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			if body, _ := ioutil.ReadAll(r.Body); string(body) != "payload" {
+				t.Errorf("%s: expected the body on every attempt, got %q", test.method, body)
+			}
+			if calls <= test.failures {
+				w.WriteHeader(http.StatusBadGateway)
+			}
+		}))
+
+		client := NewClient(Settings{Timeout: time.Second, Retries: test.retries, Backoff: time.Millisecond})
+		req, _ := http.NewRequest(test.method, server.URL, bytes.NewBufferString("payload"))
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("%s: %v", test.method, err)
+		}
+		res.Body.Close()
+		server.Close()
+
+		if res.StatusCode != test.status || calls != test.calls {
+			t.Errorf("%s with %d failures and %d retries: expected status %d after %d calls, got %d after %d",
+				test.method, test.failures, test.retries, test.status, test.calls, res.StatusCode, calls)
+		}
+	}
+}
+
+func TestRetriesConnectionErrors(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	// the controller comes up while the client is backing off
+	started := make(chan *http.Server)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		listener, err := net.Listen("tcp", address)
+		if err != nil {
+			started <- nil
+			return
+		}
+		server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})}
+		go server.Serve(listener)
+		started <- server
+	}()
+
+	client := NewClient(Settings{Timeout: time.Second, Retries: 5, Backoff: 10 * time.Millisecond})
+	res, err := client.Post("http://"+address, "application/json", bytes.NewBufferString("{}"))
+	if server := <-started; server != nil {
+		defer server.Close()
+	} else {
+		t.Skip("could not listen again on " + address)
+	}
+	if err != nil {
+		t.Fatalf("expected the request to be retried until the controller is up, got %v", err)
+	}
+	res.Body.Close()
+}
+
+func TestTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	client := NewClient(Settings{Timeout: 20 * time.Millisecond, Retries: 1, Backoff: time.Millisecond})
+	start := time.Now()
+	if _, err := client.Get(server.URL); err == nil {
+		t.Fatal("expected the request to time out")
+	}
+	if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+		t.Errorf("expected both attempts to time out quickly, took %v", elapsed)
+	}
+}
+
+func TestReauthenticate(t *testing.T) {
+	tests := []struct {
+		auth     string
+		renewed  string
+		err      error
+		status   int
+		attempts int
+	}{
+		{"expired", "renewed", nil, http.StatusOK, 1},
+		{"expired", "", errors.New("not a terminal"), 0, 1},
+		{"", "renewed", nil, http.StatusUnauthorized, 0},
+	}
+
+	for _, test := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "renewed" {
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		}))
+
+		attempts := 0
+		client := NewClient(Settings{Reauthenticate: func(req *http.Request) (string, error) {
+			attempts++
+			return test.renewed, test.err
+		}})
+
+		// the second request is rejected too, but the user is not asked again
+		for i := 0; i < 2; i++ {
+			req, _ := http.NewRequest("PUT", server.URL, bytes.NewBufferString("payload"))
+			req.Header.Set("Authorization", test.auth)
+			res, err := client.Do(req)
+			if test.status == 0 {
+				if !errors.Is(err, ErrSessionExpired) {
+					t.Errorf("auth %q: expected the session to be reported as expired, got %v", test.auth, err)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+			if res.StatusCode != test.status {
+				t.Errorf("auth %q: expected status %d, got %d", test.auth, test.status, res.StatusCode)
+			}
+		}
+		server.Close()
+
+		if attempts != test.attempts {
+			t.Errorf("auth %q: expected %d reauthentications, got %d", test.auth, test.attempts, attempts)
+		}
+	}
+}
+
+func TestReusesConnections(t *testing.T) {
+	connections := 0
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			connections++
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	client := NewClient(Settings{})
+	for i := 0; i < 3; i++ {
+		// the sdk gateways ask to close the connection of every request
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		req.Close = true
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(res.Body)
+		res.Body.Close()
+	}
+	if connections != 1 {
+		t.Errorf("expected the requests to share a connection, got %d connections", connections)
+	}
+}
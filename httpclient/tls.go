@@ -0,0 +1,58 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+)
+
+// TLSOptions describe how the identity of a controller is checked and how the client
+// identifies itself. The zero value verifies controllers against the system certificates.
+type TLSOptions struct {
+	// SkipVerify disables the verification of the controller certificate.
+	SkipVerify bool
+	// CACert is the path of a PEM bundle trusted on top of the system certificates.
+	CACert string
+	// ClientCert and ClientKey are the paths of the PEM certificate and key presented to
+	// the controller.
+	ClientCert string
+	ClientKey  string
+}
+
+// TLSConfig builds the tls configuration of options, nil when options are the defaults.
+func TLSConfig(options TLSOptions) (*tls.Config, error) {
+	if options == (TLSOptions{}) {
+		return nil, nil
+	}
+
+	config := &tls.Config{InsecureSkipVerify: options.SkipVerify}
+
+	if options.CACert != "" {
+		pem, err := ioutil.ReadFile(options.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read CA certificate: %v", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", options.CACert)
+		}
+		config.RootCAs = pool
+	}
+
+	if options.ClientCert != "" || options.ClientKey != "" {
+		if options.ClientCert == "" || options.ClientKey == "" {
+			return nil, fmt.Errorf("a client certificate needs both a certificate and a key")
+		}
+		certificate, err := tls.LoadX509KeyPair(options.ClientCert, options.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load client certificate: %v", err)
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+
+	return config, nil
+}
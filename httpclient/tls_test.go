@@ -0,0 +1,116 @@
+package httpclient
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writePEM(t *testing.T, dir, name, blockType string, bytes []byte) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: bytes}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// clientCertificate writes a self-signed client certificate and its key to dir.
+func clientCertificate(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "cde"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writePEM(t, dir, "client.pem", "CERTIFICATE", der), writePEM(t, dir, "client-key.pem", "EC PRIVATE KEY", keyDer)
+}
+
+func TestTLSOptions(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "cde-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	caCert := writePEM(t, dir, "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+	clientCert, clientKey := clientCertificate(t, dir)
+
+	tests := []struct {
+		options TLSOptions
+		status  int
+	}{
+		{TLSOptions{}, 0},
+		{TLSOptions{SkipVerify: true}, http.StatusForbidden},
+		{TLSOptions{CACert: caCert}, http.StatusForbidden},
+		{TLSOptions{CACert: caCert, ClientCert: clientCert, ClientKey: clientKey}, http.StatusOK},
+	}
+
+	for _, test := range tests {
+		config, err := TLSConfig(test.options)
+		if err != nil {
+			t.Fatalf("%+v: %v", test.options, err)
+		}
+		client := NewClient(Settings{Timeout: time.Second, TLS: config})
+		res, err := client.Get(server.URL)
+		if test.status == 0 {
+			if err == nil {
+				t.Errorf("%+v: expected the unknown certificate of the controller to be rejected", test.options)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%+v: %v", test.options, err)
+		}
+		res.Body.Close()
+		if res.StatusCode != test.status {
+			t.Errorf("%+v: expected status %d, got %d", test.options, test.status, res.StatusCode)
+		}
+	}
+}
+
+func TestInvalidTLSOptions(t *testing.T) {
+	tests := []TLSOptions{
+		{CACert: "missing.pem"},
+		{ClientCert: "client.pem"},
+		{ClientCert: "missing.pem", ClientKey: "missing-key.pem"},
+	}
+
+	for _, options := range tests {
+		if _, err := TLSConfig(options); err == nil {
+			t.Errorf("%+v: expected an error", options)
+		}
+	}
+}
@@ -127,9 +127,21 @@ func AppsCommand() *cli.Command {
 						Name:  "stack, s",
 						Usage: "Another existing stack name",
 					},
+					&cli.IntFlag{
+						Name:  "version",
+						Usage: "Pin the application to a version of the stack instead of its latest one",
+					},
+					&cli.BoolFlag{
+						Name:  "latest",
+						Usage: "Have the application follow the latest version of its stack again",
+					},
 				},
 				Action: func(c *cli.Context) error {
-					err := cmd.SwitchStack(c.String("app"), c.String("stack"))
+					version, err := stackVersion(c.String("stack"), c.IsSet("version"), c.Int("version"), c.Bool("latest"))
+					if err != nil {
+						return cli.Exit(fmt.Sprintf("%v", err), 1)
+					}
+					err = cmd.SwitchStack(c.String("app"), c.String("stack"), version)
 					if err != nil {
 						return cli.Exit(fmt.Sprintf("%v", err), 1)
 					}
@@ -479,7 +491,7 @@ Options:
 
 func appStackUpdate(argv []string) error {
 	usage := `
-Change to use another stack.
+Change to use another stack, or pin the application to a version of its stack.
 Usage: cde apps:stack-update [options]
 
 Options:
@@ -487,6 +499,10 @@ Options:
     the uniquely identifiable name for the application.
   -s --stack=<stack>
     another existing stack name.
+  --version=<version>
+    the version of the stack to pin the application to, its latest one when omitted.
+  --latest
+    have the application follow the latest version of its stack again.
 `
 	args, err := docopt.Parse(usage, argv, true, "", false, true)
 
@@ -496,11 +512,36 @@ Options:
 
 	appName := safeGetValue(args, "--app")
 	stackName := safeGetValue(args, "--stack")
+	number := 0
+	pinned := safeGetValue(args, "--version") != ""
+	if pinned {
+		if number, err = strconv.Atoi(safeGetValue(args, "--version")); err != nil {
+			return fmt.Errorf("Invalid stack version %s", safeGetValue(args, "--version"))
+		}
+	}
+	version, err := stackVersion(stackName, pinned, number, args["--latest"].(bool))
+	if err != nil {
+		return err
+	}
 
-	return cmd.SwitchStack(appName, stackName)
+	return cmd.SwitchStack(appName, stackName, version)
 
 }
 
+// stackVersion returns the stack version apps:stack-update pins the application to, nil
+// for the latest one, which --latest asks for when the stack stays the same.
+func stackVersion(stack string, pinned bool, version int, latest bool) (*int, error) {
+	switch {
+	case pinned && latest:
+		return nil, errors.New("Please provide either --version or --latest")
+	case pinned:
+		return &version, nil
+	case stack == "" && !latest:
+		return nil, errors.New("Please provide the stack, the stack version or --latest")
+	}
+	return nil, nil
+}
+
 func appLogs(argv []string) error {
 	usage := `
 Prints info about the current application.
@@ -2,9 +2,11 @@ package parser
 
 import (
 	"fmt"
+	"strconv"
 
 	docopt "github.com/docopt/docopt-go"
 	"github.com/cnupp/cli/cmd"
+	"github.com/cnupp/cli/httpclient"
 	cli "gopkg.in/urfave/cli.v2"
 )
 
@@ -26,12 +28,20 @@ func AuthCommand() *cli.Command {
 						Name:  "password, p",
 						Usage: "Provide password for the new user",
 					},
+					&cli.BoolFlag{
+						Name:  "password-stdin",
+						Usage: "Read the password from stdin",
+					},
 				},
 				Action: func(c *cli.Context) error {
 					if c.Args().Get(0) == "" {
 						return cli.Exit(fmt.Sprintf("USAGE: %s %s", c.Command.HelpName, c.Command.ArgsUsage), 1)
 					}
-					err := cmd.Register(c.Args().First(), c.String("email"), c.String("password"))
+					password, err := stdinPassword(c.String("password"), c.Bool("password-stdin"))
+					if err != nil {
+						return cli.Exit(fmt.Sprintf("%v", err), 1)
+					}
+					err = cmd.Register(c.Args().First(), c.String("email"), password)
 					if err != nil {
 						return cli.Exit(fmt.Sprintf("%v", err), 1)
 					}
@@ -59,19 +69,113 @@ func AuthCommand() *cli.Command {
 						Name:  "password, p",
 						Usage: "Provide user password",
 					},
+					&cli.BoolFlag{
+						Name:  "password-stdin",
+						Usage: "Read the password from stdin",
+					},
+					&cli.BoolFlag{
+						Name:  "ssl-verify",
+						Value: true,
+						Usage: "Verify the SSL certificate of the controller, --ssl-verify=false disables it",
+					},
 					&cli.StringFlag{
-						Name:  "ssl-verify, s",
-						Usage: "Disable SSL certificate verification for API requests",
+						Name:  "ca-cert",
+						Usage: "Trust the certificates of a PEM bundle, e.g. an internal CA",
+					},
+					&cli.StringFlag{
+						Name:  "client-cert",
+						Usage: "Present a PEM client certificate to the controller",
+					},
+					&cli.StringFlag{
+						Name:  "client-key",
+						Usage: "Private key of the client certificate",
 					},
 				},
 				Action: func(c *cli.Context) error {
 					if c.Args().Get(0) == "" {
 						return cli.Exit(fmt.Sprintf("USAGE: %s %s", c.Command.HelpName, c.Command.ArgsUsage), 1)
 					}
-					err := cmd.Login(c.Args().First(), c.String("email"), c.String("password"))
+					password, err := stdinPassword(c.String("password"), c.Bool("password-stdin"))
 					if err != nil {
 						return cli.Exit(fmt.Sprintf("%v", err), 1)
 					}
+					err = cmd.Login(c.Args().First(), c.String("email"), password, httpclient.TLSOptions{
+						SkipVerify: !c.Bool("ssl-verify"),
+						CACert:     c.String("ca-cert"),
+						ClientCert: c.String("client-cert"),
+						ClientKey:  c.String("client-key"),
+					})
+					if err != nil {
+						return cli.Exit(fmt.Sprintf("%v", err), 1)
+					}
+					return nil
+				},
+			},
+			{
+				Name:      "cancel",
+				Usage:     "Cancel and remove an account, the current one by default",
+				ArgsUsage: " ",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "email",
+						Aliases: []string{"e"},
+						Usage:   "Provide the email of the account",
+					},
+					&cli.StringFlag{
+						Name:    "password",
+						Aliases: []string{"p"},
+						Usage:   "Provide the password of the account",
+					},
+					&cli.BoolFlag{
+						Name:    "yes",
+						Aliases: []string{"y"},
+						Usage:   "Do not ask for confirmation",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if err := cmd.Cancel(c.String("email"), c.String("password"), c.Bool("yes")); err != nil {
+						return cli.Exit(fmt.Sprintf("%v", err), 1)
+					}
+					return nil
+				},
+			},
+			{
+				Name:      "passwd",
+				Usage:     "Change the password of the current user",
+				ArgsUsage: " ",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "password",
+						Aliases: []string{"p"},
+						Usage:   "Provide the current password",
+					},
+					&cli.StringFlag{
+						Name:  "new-password",
+						Usage: "Provide the new password",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if err := cmd.Passwd(c.String("password"), c.String("new-password")); err != nil {
+						return cli.Exit(fmt.Sprintf("%v", err), 1)
+					}
+					return nil
+				},
+			},
+			{
+				Name:      "regenerate",
+				Usage:     "Replace the auth token of the current user and revoke the previous one",
+				ArgsUsage: " ",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "password",
+						Aliases: []string{"p"},
+						Usage:   "Provide user password",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if err := cmd.Regenerate(c.String("password")); err != nil {
+						return cli.Exit(fmt.Sprintf("%v", err), 1)
+					}
 					return nil
 				},
 			},
@@ -100,6 +204,9 @@ auth:register          register a new user
 auth:login             authenticate against a controller
 auth:logout            clear the current user session
 auth:whoami            display the current user
+auth:passwd            change the password of the current user
+auth:cancel            remove an account
+auth:regenerate        replace the auth token of the current user
 
 Use 'cde help [command]' to learn more.
 `
@@ -111,14 +218,14 @@ Use 'cde help [command]' to learn more.
 		return authLogin(argv)
 	case "auth:logout":
 		return authLogout(argv)
-		//	case "auth:passwd":
-		//		return authPasswd(argv)
+	case "auth:passwd":
+		return authPasswd(argv)
 	case "auth:whoami":
 		return authWhoami(argv)
-		//	case "auth:cancel":
-		//		return authCancel(argv)
-		//	case "auth:regenerate":
-		//		return authRegenerate(argv)
+	case "auth:cancel":
+		return authCancel(argv)
+	case "auth:regenerate":
+		return authRegenerate(argv)
 	case "auth":
 		fmt.Print(usage)
 		return nil
@@ -143,6 +250,10 @@ Options:
     provide an email address.
   --password=<password>
     provide a password for the new account.
+  --password-stdin
+    read the password from stdin.
+
+Credentials not given are read from CDE_EMAIL and CDE_PASSWORD, then asked for.
 `
 	args, err := docopt.Parse(usage, argv, true, "", false, true)
 
@@ -151,8 +262,11 @@ Options:
 	}
 
 	controller := safeGetValue(args, "<controller>")
-	password := safeGetValue(args, "--password")
 	email := safeGetValue(args, "--email")
+	password, err := stdinPassword(safeGetValue(args, "--password"), args["--password-stdin"].(bool))
+	if err != nil {
+		return err
+	}
 
 	return cmd.Register(controller, email, password)
 }
@@ -172,8 +286,18 @@ Options:
     provide a email for the account.
   --password=<password>
     provide a password for the account.
-  --ssl-verify=false
-    disables SSL certificate verification for API requests
+  --password-stdin
+    read the password from stdin.
+  --ssl-verify=<ssl-verify>
+    verify the SSL certificate of the controller, false disables it. [default: true]
+  --ca-cert=<file>
+    trust the certificates of a PEM bundle, e.g. an internal CA.
+  --client-cert=<file>
+    present a PEM client certificate to the controller.
+  --client-key=<file>
+    the private key of the client certificate.
+
+Credentials not given are read from CDE_EMAIL and CDE_PASSWORD, then asked for.
 `
 
 	args, err := docopt.Parse(usage, argv, true, "", false, true)
@@ -184,9 +308,33 @@ Options:
 
 	controller := safeGetValue(args, "<controller>")
 	email := safeGetValue(args, "--email")
-	password := safeGetValue(args, "--password")
+	password, err := stdinPassword(safeGetValue(args, "--password"), args["--password-stdin"].(bool))
+	if err != nil {
+		return err
+	}
+	sslVerify, err := strconv.ParseBool(safeGetOrDefault(args, "--ssl-verify", "true"))
+	if err != nil {
+		return fmt.Errorf("--ssl-verify must be true or false")
+	}
+
+	return cmd.Login(controller, email, password, httpclient.TLSOptions{
+		SkipVerify: !sslVerify,
+		CACert:     safeGetValue(args, "--ca-cert"),
+		ClientCert: safeGetValue(args, "--client-cert"),
+		ClientKey:  safeGetValue(args, "--client-key"),
+	})
+}
 
-	return cmd.Login(controller, email, password)
+// stdinPassword returns the password read from stdin when fromStdin is set, password
+// otherwise.
+func stdinPassword(password string, fromStdin bool) (string, error) {
+	if !fromStdin {
+		return password, nil
+	}
+	if password != "" {
+		return "", fmt.Errorf("--password and --password-stdin cannot be used together")
+	}
+	return cmd.PasswordFromStdin()
 }
 
 func authLogout(argv []string) error {
@@ -203,33 +351,30 @@ Usage: cde auth:logout
 	return cmd.Logout()
 }
 
-//func authPasswd(argv []string) error {
-//	usage := `
-//Changes the password for the current user.
-//
-//Usage: cde auth:passwd [options]
-//
-//Options:
-//  --password=<password>
-//    the current password for the account.
-//  --new-password=<new-password>
-//    the new password for the account.
-//  --username=<username>
-//    the account's username.
-//`
-//
-//	args, err := docopt.Parse(usage, argv, true, "", false, true)
-//
-//	if err != nil {
-//		return err
-//	}
-//
-//	username := safeGetValue(args, "--username")
-//	password := safeGetValue(args, "--password")
-//	newPassword := safeGetValue(args, "--new-password")
-//
-//	return cmd.Passwd(username, password, newPassword)
-//}
+func authPasswd(argv []string) error {
+	usage := `
+Changes the password for the current user.
+
+Usage: cde auth:passwd [options]
+
+Options:
+  --password=<password>
+    the current password for the account.
+  --new-password=<new-password>
+    the new password for the account.
+`
+
+	args, err := docopt.Parse(usage, argv, true, "", false, true)
+
+	if err != nil {
+		return err
+	}
+
+	password := safeGetValue(args, "--password")
+	newPassword := safeGetValue(args, "--new-password")
+
+	return cmd.Passwd(password, newPassword)
+}
 
 func authWhoami(argv []string) error {
 	usage := `
@@ -252,8 +397,8 @@ Cancels and removes the current account.
 Usage: cde auth:cancel [options]
 
 Options:
-  --username=<username>
-    provide a username for the account.
+  --email=<email>
+    provide the email of the account, the current user by default.
   --password=<password>
     provide a password for the account.
   --yes
@@ -266,25 +411,30 @@ Options:
 		return err
 	}
 
-	username := safeGetValue(args, "--username")
+	email := safeGetValue(args, "--email")
 	password := safeGetValue(args, "--password")
 	yes := args["--yes"].(bool)
 
-	return cmd.Cancel(username, password, yes)
+	return cmd.Cancel(email, password, yes)
 }
 
 func authRegenerate(argv []string) error {
 	usage := `
 Regenerates auth token, defaults to regenerating token for the current user.
+The previous token is revoked.
+
+Usage: cde auth:regenerate [options]
 
-Usage: cde auth:regenerate
+Options:
+  --password=<password>
+    the password of the current user, asked when not given.
 `
 
-	_, err := docopt.Parse(usage, argv, true, "", false, true)
+	args, err := docopt.Parse(usage, argv, true, "", false, true)
 
 	if err != nil {
 		return err
 	}
 
-	return cmd.Regenerate()
+	return cmd.Regenerate(safeGetValue(args, "--password"))
 }
@@ -0,0 +1,96 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/cnupp/cli/cmd"
+	cli "gopkg.in/urfave/cli.v2"
+)
+
+func BuildsCommands() *cli.Command {
+	return &cli.Command{
+		Name:  "builds",
+		Usage: "Builds Commands",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "list",
+				Usage:     "List the builds of an app",
+				ArgsUsage: " ",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "app",
+						Aliases: []string{"a"},
+						Usage:   "Specify app with name",
+					},
+					&cli.IntFlag{
+						Name:    "page",
+						Aliases: []string{"p"},
+						Value:   1,
+						Usage:   "The page of builds to display",
+					},
+					&cli.BoolFlag{
+						Name:  "all",
+						Usage: "Display the builds of all pages",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if err := cmd.BuildsList(c.String("app"), c.Int("page"), c.Bool("all")); err != nil {
+						return cli.Exit(fmt.Sprintf("%v", err), 1)
+					}
+					return nil
+				},
+			},
+			{
+				Name:      "info",
+				Usage:     "Get info of a build",
+				ArgsUsage: "<build-id>",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "app",
+						Aliases: []string{"a"},
+						Usage:   "Specify app with name",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if c.Args().Get(0) == "" {
+						return cli.Exit(fmt.Sprintf("USAGE: %s %s", c.Command.HelpName, c.Command.ArgsUsage), 1)
+					}
+					if err := cmd.BuildInfo(c.String("app"), c.Args().First()); err != nil {
+						return cli.Exit(fmt.Sprintf("%v", err), 1)
+					}
+					return nil
+				},
+			},
+			{
+				Name:      "logs",
+				Usage:     "Display the log of a build",
+				ArgsUsage: "<build-id>",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "app",
+						Aliases: []string{"a"},
+						Usage:   "Specify app with name",
+					},
+					&cli.BoolFlag{
+						Name:  "verify",
+						Usage: "Display the log of the verify procedure instead",
+					},
+					&cli.BoolFlag{
+						Name:    "follow",
+						Aliases: []string{"f"},
+						Usage:   "Keep streaming the log until the build finishes",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if c.Args().Get(0) == "" {
+						return cli.Exit(fmt.Sprintf("USAGE: %s %s", c.Command.HelpName, c.Command.ArgsUsage), 1)
+					}
+					if err := cmd.BuildLogs(c.String("app"), c.Args().First(), c.Bool("verify"), c.Bool("follow")); err != nil {
+						return cli.Exit(fmt.Sprintf("%v", err), 1)
+					}
+					return nil
+				},
+			},
+		},
+	}
+}
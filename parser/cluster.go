@@ -27,7 +27,7 @@ func ClustersCommands() *cli.Command {
 			{
 				Name:      "info",
 				Usage:     "View info about a cluster",
-				ArgsUsage: "<cluster-id>",
+				ArgsUsage: "<cluster>",
 				Action: func(c *cli.Context) error {
 					if !c.Args().Present() {
 						return cli.Exit(fmt.Sprintf("USAGE: %s %s", c.Command.HelpName, c.Command.ArgsUsage), 1)
@@ -55,7 +55,7 @@ func ClustersCommands() *cli.Command {
 			{
 				Name:      "update",
 				Usage:     "Update a cluster",
-				ArgsUsage: "<cluster-id>",
+				ArgsUsage: "<cluster>",
 				Flags: []cli.Flag{
 					&cli.StringFlag{
 						Name:  "name, n",
@@ -90,7 +90,7 @@ func ClustersCommands() *cli.Command {
 			{
 				Name:      "delete",
 				Usage:     "Delete a cluster",
-				ArgsUsage: "<cluster-id>",
+				ArgsUsage: "<cluster>",
 				Action: func(c *cli.Context) error {
 					if !c.Args().Present() {
 						return cli.Exit(fmt.Sprintf("USAGE: %s %s", c.Command.HelpName, c.Command.ArgsUsage), 1)
@@ -197,11 +197,11 @@ func clustersDelete(argv []string) error {
 	usage := `
 Delete the cluster.
 
-Usage: cde clusters:delete <cluster-id>
+Usage: cde clusters:delete <cluster>
 
 Arguments:
-  <cluster-id>
-  	a cluster Id
+  <cluster>
+  	a cluster name or id
 `
 
 	args, err := docopt.Parse(usage, argv, true, "", false, true)
@@ -210,20 +210,20 @@ Arguments:
 		return err
 	}
 
-	clusterId := safeGetValue(args, "<cluster-id>")
+	cluster := safeGetValue(args, "<cluster>")
 
-	return cmd.ClusterRemove(clusterId)
+	return cmd.ClusterRemove(cluster)
 }
 
 func clusterInfo(argv []string) error {
 	usage := `
 Prints info about an cluster.
 
-Usage: cde clusters:info <cluster-id>
+Usage: cde clusters:info <cluster>
 
 Arguments:
-  <cluster-id>
-  	a cluster Id
+  <cluster>
+  	a cluster name or id
 	`
 	args, err := docopt.Parse(usage, argv, true, "", false, true)
 
@@ -231,20 +231,20 @@ Arguments:
 		return err
 	}
 
-	clusterId := safeGetValue(args, "<cluster-id>")
+	cluster := safeGetValue(args, "<cluster>")
 
-	return cmd.GetCluster(clusterId)
+	return cmd.GetCluster(cluster)
 }
 
 func clustersUpdate(argv []string) error {
 	usage := `
 Update cluster info.
 
-Usage: cde clusters:update <cluster-id> [options]
+Usage: cde clusters:update <cluster> [options]
 
 Arguments:
-  <cluster-id>
-  	a cluster Id
+  <cluster>
+  	a cluster name or id
 
 Options:
   -n --name=<name>
@@ -261,7 +261,7 @@ Options:
 		return err
 	}
 
-	clusterId := safeGetValue(args, "<cluster-id>")
+	cluster := safeGetValue(args, "<cluster>")
 	clusterName := safeGetValue(args, "--name")
 	clusterType := safeGetValue(args, "--type")
 	clusterUri := safeGetValue(args, "--uri")
@@ -270,5 +270,5 @@ Options:
 		return errors.New("name, type or uri should given")
 	}
 
-	return cmd.ClusterUpdate(clusterId, clusterName, clusterType, clusterUri)
+	return cmd.ClusterUpdate(cluster, clusterName, clusterType, clusterUri)
 }
@@ -56,10 +56,105 @@ func DevCommands() *cli.Command {
 					return nil
 				},
 			},
+			{
+				Name:      "status",
+				Usage:     "Display the state of the services of the local dev env.",
+				ArgsUsage: " ",
+				Action: func(c *cli.Context) error {
+					if err := cmd.DevStatus(); err != nil {
+						return cli.Exit(fmt.Sprintf("%v", err), 1)
+					}
+					return nil
+				},
+			},
+			{
+				Name:      "logs",
+				Usage:     "Display the logs of the services of the local dev env.",
+				ArgsUsage: "[service]",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:    "follow",
+						Aliases: []string{"f"},
+						Usage:   "Keep printing the logs as they come",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if c.Args().Len() > 1 {
+						return cli.Exit(fmt.Sprintf("USAGE: %s %s", c.Command.HelpName, c.Command.ArgsUsage), 1)
+					}
+					if err := cmd.DevLogs(c.Args().First(), c.Bool("follow")); err != nil {
+						return cli.Exit(fmt.Sprintf("%v", err), 1)
+					}
+					return nil
+				},
+			},
+			{
+				Name:      "exec",
+				Usage:     "Run a command from the app directory in the runtime container, exiting with its code.",
+				ArgsUsage: "-- <command>...",
+				Action: func(c *cli.Context) error {
+					command := trimDoubleDash(c.Args().Slice())
+					if len(command) == 0 {
+						return cli.Exit(fmt.Sprintf("USAGE: %s %s", c.Command.HelpName, c.Command.ArgsUsage), 1)
+					}
+					return exit(cmd.DevExec(command))
+				},
+			},
+			{
+				Name:      "run",
+				Usage:     "Run a command in a new container of a service, exiting with its code.",
+				ArgsUsage: "<service> -- <command>...",
+				Action: func(c *cli.Context) error {
+					args := c.Args().Slice()
+					if len(args) < 2 {
+						return cli.Exit(fmt.Sprintf("USAGE: %s %s", c.Command.HelpName, c.Command.ArgsUsage), 1)
+					}
+					command := trimDoubleDash(args[1:])
+					if len(command) == 0 {
+						return cli.Exit(fmt.Sprintf("USAGE: %s %s", c.Command.HelpName, c.Command.ArgsUsage), 1)
+					}
+					return exit(cmd.DevRun(args[0], command))
+				},
+			},
+			{
+				Name:      "export",
+				Usage:     "Write the stack of the app as a compose file or Kubernetes manifests.",
+				ArgsUsage: " ",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "format",
+						Value: "compose",
+						Usage: "Export as compose or k8s",
+					},
+					&cli.StringFlag{
+						Name:    "file",
+						Aliases: []string{"f"},
+						Usage:   "Write to file instead of docker-compose.yml or k8s.yml, - for the standard output",
+					},
+					&cli.BoolFlag{
+						Name:  "force",
+						Usage: "Overwrite an existing docker-compose.yml or k8s.yml",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if err := cmd.DevExport(c.String("format"), c.String("file"), c.Bool("force")); err != nil {
+						return cli.Exit(fmt.Sprintf("%v", err), 1)
+					}
+					return nil
+				},
+			},
 		},
 	}
 }
 
+// trimDoubleDash removes the -- separating a command from the arguments of the cli.
+func trimDoubleDash(args []string) []string {
+	if len(args) > 0 && args[0] == "--" {
+		return args[1:]
+	}
+	return args
+}
+
 // Config routes config commands to their specific function.
 func Dev(argv []string) error {
 	usage := `
@@ -69,6 +164,14 @@ dev:up        	 start up the dev env
 dev:down         shutdown the dev env
 dev:destroy      destroy the dev env
 dev:env          display the env variables
+dev:status       display the state of the services
+dev:logs         display the logs of the services
+dev:exec         run a command in the runtime container
+dev:run          run a command in a new container of a service
+dev:export       write the stack as a compose file or Kubernetes manifests
+
+The dev env runs with docker-compose when it is installed, with the Docker Engine API
+otherwise. Set CDE_DEV_BACKEND to compose or docker to choose.
 
 Use 'cde help [command]' to learn more.
 `
@@ -81,6 +184,16 @@ Use 'cde help [command]' to learn more.
 		return devDestroy(argv)
 	case "dev:env":
 		return devEnv(argv)
+	case "dev:status":
+		return devStatus(argv)
+	case "dev:logs":
+		return devLogs(argv)
+	case "dev:exec":
+		return devExec(argv)
+	case "dev:run":
+		return devRun(argv)
+	case "dev:export":
+		return devExport(argv)
 	default:
 		if printHelp(argv, usage) {
 			return nil
@@ -159,3 +272,103 @@ Usage: cde dev:env
 
 	return cmd.DevEnv()
 }
+
+func devStatus(argv []string) error {
+	usage := `
+Display the service, container, state and published ports of every service of the local
+dev env
+
+Usage: cde dev:status
+`
+
+	_, err := docopt.Parse(usage, argv, true, "", false, true)
+
+	if err != nil {
+		return err
+	}
+
+	return cmd.DevStatus()
+}
+
+func devLogs(argv []string) error {
+	usage := `
+Display the logs of the services of the local dev env
+
+Usage: cde dev:logs [<service>] [options]
+
+Arguments:
+  <service>
+    the service to display the logs of, all of them by default
+
+Options:
+  -f --follow
+    keep printing the logs as they come
+`
+
+	args, err := docopt.Parse(usage, argv, true, "", false, true)
+
+	if err != nil {
+		return err
+	}
+
+	return cmd.DevLogs(safeGetValue(args, "<service>"), args["--follow"].(bool))
+}
+
+func devExec(argv []string) error {
+	usage := `
+Run a command from the app directory in the runtime container, without terminal, and exit
+with its exit code
+
+Usage: cde dev:exec -- <command>...
+`
+
+	args, err := docopt.Parse(usage, argv, true, "", false, true)
+
+	if err != nil {
+		return err
+	}
+
+	return cmd.DevExec(safeGetValues(args, "<command>"))
+}
+
+func devRun(argv []string) error {
+	usage := `
+Run a command in a new container of a service, removed once done, and exit with its exit
+code. The container gets the environment and volumes of the service, commands of the
+runtime service start from the app directory.
+
+Usage: cde dev:run <service> -- <command>...
+`
+
+	args, err := docopt.Parse(usage, argv, true, "", false, true)
+
+	if err != nil {
+		return err
+	}
+
+	return cmd.DevRun(safeGetValue(args, "<service>"), safeGetValues(args, "<command>"))
+}
+
+func devExport(argv []string) error {
+	usage := `
+Write the stack of the app as a compose file or Kubernetes manifests, for review
+
+Usage: cde dev:export [options]
+
+Options:
+  --format=<format>
+    compose or k8s [default: compose]
+  -f --file=<file>
+    the file to write, docker-compose.yml or k8s.yml by default, - for the standard output
+  --force
+    overwrite an existing docker-compose.yml or k8s.yml
+`
+
+	args, err := docopt.Parse(usage, argv, true, "", false, true)
+
+	if err != nil {
+		return err
+	}
+
+	return cmd.DevExport(safeGetOrDefault(args, "--format", "compose"), safeGetValue(args, "--file"), args["--force"].(bool))
+}
@@ -0,0 +1,45 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/cnupp/cli/cmd"
+	cli "gopkg.in/urfave/cli.v2"
+)
+
+func EventsCommands() *cli.Command {
+	return &cli.Command{
+		Name:  "events",
+		Usage: "Events Commands",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "list",
+				Usage:     "List the build, release and deployment events",
+				ArgsUsage: " ",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "type",
+						Aliases: []string{"t"},
+						Usage:   "Only list events of this type",
+					},
+					&cli.StringFlag{
+						Name:    "app",
+						Aliases: []string{"a"},
+						Usage:   "Only list events of the app with name",
+					},
+					&cli.BoolFlag{
+						Name:    "follow",
+						Aliases: []string{"f"},
+						Usage:   "Keep polling and print new events as they arrive",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if err := cmd.EventsList(c.String("type"), c.String("app"), c.Bool("follow")); err != nil {
+						return cli.Exit(fmt.Sprintf("%v", err), 1)
+					}
+					return nil
+				},
+			},
+		},
+	}
+}
@@ -2,6 +2,7 @@ package parser
 
 import (
 	"fmt"
+	"strconv"
 
 	"github.com/docopt/docopt-go"
 	"github.com/cnupp/cli/cmd"
@@ -16,14 +17,38 @@ func LaunchCommands() *cli.Command {
 			{
 				Name:      "build",
 				Usage:     "Launch a build procedure.",
-				ArgsUsage: "<filename> <app-name>",
+				ArgsUsage: "(<filename> | --package) <app-name>",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "package",
+						Usage: "Build the current git working tree, respecting .gitignore and .cdeignore",
+					},
+					&cli.Int64Flag{
+						Name:  "max-size",
+						Value: cmd.DefaultMaxUploadSize,
+						Usage: "The largest source to upload, in megabytes",
+					},
+					&cli.StringFlag{
+						Name:  "git-sha",
+						Usage: "The commit being built, defaults to HEAD of the current git repository",
+					},
+					&cli.StringFlag{
+						Name:  "user",
+						Usage: "The owner of the build, defaults to the logged in user",
+					},
+				},
 				Action: func(c *cli.Context) error {
-					filename := c.Args().Get(0)
-					appName := c.Args().Get(1)
-					if filename == "" || appName == "" {
+					var filename, appName string
+					if c.Bool("package") {
+						appName = c.Args().Get(0)
+					} else {
+						filename = c.Args().Get(0)
+						appName = c.Args().Get(1)
+					}
+					if (filename == "" && !c.Bool("package")) || appName == "" {
 						return cli.Exit(fmt.Sprintf("USAGE: %s %s", c.Command.HelpName, c.Command.ArgsUsage), 1)
 					}
-					if err := cmd.LaunchBuild(filename, appName); err != nil {
+					if err := cmd.LaunchBuild(filename, appName, c.String("git-sha"), c.String("user"), c.Int64("max-size")); err != nil {
 						return cli.Exit(fmt.Sprintf("%v", err), 1)
 					}
 					return nil
@@ -111,13 +136,24 @@ func launchBuild(argv []string) error {
 	usage := `
 Launch a build procedure.
 
-Usage: cde launch:build (-f <filename>) (-a <app-name>)
+Usage: cde launch:build (-f <filename>) (-a <app-name>) [--git-sha=<sha>] [--user=<user>] [--max-size=<mb>]
+       cde launch:build --package (-a <app-name>) [--git-sha=<sha>] [--user=<user>] [--max-size=<mb>]
 
 Arguments:
   <filename>
   the code base to build with
   <app-name>
   the app to build with
+
+Options:
+  --package
+    build the current git working tree, respecting .gitignore and .cdeignore
+  --max-size=<mb>
+    the largest source to upload, in megabytes [default: 100]
+  --git-sha=<sha>
+    the commit being built, defaults to HEAD of the current git repository
+  --user=<user>
+    the owner of the build, defaults to the logged in user
 `
 	args, err := docopt.Parse(usage, argv, true, "", false, true)
 
@@ -126,6 +162,12 @@ Arguments:
 	}
 	filename := safeGetValue(args, "<filename>")
 	appName := safeGetValue(args, "<app-name>")
+	gitSha := safeGetValue(args, "--git-sha")
+	user := safeGetValue(args, "--user")
+	maxSize, err := strconv.ParseInt(safeGetOrDefault(args, "--max-size", "100"), 10, 64)
+	if err != nil {
+		return err
+	}
 
-	return cmd.LaunchBuild(filename, appName)
+	return cmd.LaunchBuild(filename, appName, gitSha, user, maxSize)
 }
@@ -0,0 +1,56 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/cnupp/cli/cmd"
+	cli "gopkg.in/urfave/cli.v2"
+)
+
+func ProfilesCommands() *cli.Command {
+	return &cli.Command{
+		Name:  "profiles",
+		Usage: "Profiles Commands",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "list",
+				Usage:     "List all controller profiles",
+				ArgsUsage: " ",
+				Action: func(c *cli.Context) error {
+					if err := cmd.ProfilesList(); err != nil {
+						return cli.Exit(fmt.Sprintf("%v", err), 1)
+					}
+					return nil
+				},
+			},
+			{
+				Name:      "use",
+				Usage:     "Switch the active controller profile",
+				ArgsUsage: "<profile-name>",
+				Action: func(c *cli.Context) error {
+					if c.Args().Get(0) == "" {
+						return cli.Exit(fmt.Sprintf("USAGE: %s %s", c.Command.HelpName, c.Command.ArgsUsage), 1)
+					}
+					if err := cmd.ProfileUse(c.Args().First()); err != nil {
+						return cli.Exit(fmt.Sprintf("%v", err), 1)
+					}
+					return nil
+				},
+			},
+			{
+				Name:      "remove",
+				Usage:     "Remove a controller profile",
+				ArgsUsage: "<profile-name>",
+				Action: func(c *cli.Context) error {
+					if c.Args().Get(0) == "" {
+						return cli.Exit(fmt.Sprintf("USAGE: %s %s", c.Command.HelpName, c.Command.ArgsUsage), 1)
+					}
+					if err := cmd.ProfileRemove(c.Args().First()); err != nil {
+						return cli.Exit(fmt.Sprintf("%v", err), 1)
+					}
+					return nil
+				},
+			},
+		},
+	}
+}
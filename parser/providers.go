@@ -28,7 +28,7 @@ func ProvidersCommand() *cli.Command {
 			{
 				Name:      "info",
 				Usage:     "Get info of a Provider",
-				ArgsUsage: "<provider-name>",
+				ArgsUsage: "<provider>",
 				Action: func(c *cli.Context) error {
 					if c.Args().Get(0) == "" {
 						return cli.Exit(fmt.Sprintf("USAGE: %s %s", c.Command.HelpName, c.Command.ArgsUsage), 1)
@@ -73,7 +73,7 @@ func ProvidersCommand() *cli.Command {
 			{
 				Name:      "update",
 				Usage:     "Update an existing Provider",
-				ArgsUsage: "<name>",
+				ArgsUsage: "<provider>",
 				Flags: []cli.Flag{
 					&cli.StringSliceFlag{
 						Name:  "config, c",
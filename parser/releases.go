@@ -0,0 +1,79 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/cnupp/cli/cmd"
+	cli "gopkg.in/urfave/cli.v2"
+)
+
+func ReleasesCommands() *cli.Command {
+	return &cli.Command{
+		Name:  "releases",
+		Usage: "Releases Commands",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "list",
+				Usage:     "List the releases of an app",
+				ArgsUsage: " ",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "app",
+						Aliases: []string{"a"},
+						Usage:   "Specify app with name",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if err := cmd.ReleasesList(c.String("app")); err != nil {
+						return cli.Exit(fmt.Sprintf("%v", err), 1)
+					}
+					return nil
+				},
+			},
+			{
+				Name:      "info",
+				Usage:     "Get info of a release",
+				ArgsUsage: "<release-id>",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "app",
+						Aliases: []string{"a"},
+						Usage:   "Specify app with name",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if c.Args().Get(0) == "" {
+						return cli.Exit(fmt.Sprintf("USAGE: %s %s", c.Command.HelpName, c.Command.ArgsUsage), 1)
+					}
+					if err := cmd.ReleaseInfo(c.String("app"), c.Args().First()); err != nil {
+						return cli.Exit(fmt.Sprintf("%v", err), 1)
+					}
+					return nil
+				},
+			},
+			{
+				Name:      "rollback",
+				Usage:     "Redeploy the previous successful release, or the given one",
+				ArgsUsage: "[<release-id>]",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "app",
+						Aliases: []string{"a"},
+						Usage:   "Specify app with name",
+					},
+					&cli.StringFlag{
+						Name:    "provider",
+						Aliases: []string{"p"},
+						Usage:   "Which provider to launch the deployment procedure",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if err := cmd.ReleaseRollback(c.String("app"), c.Args().First(), c.String("provider")); err != nil {
+						return cli.Exit(fmt.Sprintf("%v", err), 1)
+					}
+					return nil
+				},
+			},
+		},
+	}
+}
@@ -27,7 +27,7 @@ func StacksCommand() *cli.Command {
 			{
 				Name:      "info",
 				Usage:     "Get info of a Stack",
-				ArgsUsage: "<stack-name>",
+				ArgsUsage: "<stack>",
 				Action: func(c *cli.Context) error {
 					err := cmd.GetStack(c.Args().First())
 					if err != nil {
@@ -48,10 +48,64 @@ func StacksCommand() *cli.Command {
 					return nil
 				},
 			},
+			{
+				Name:      "validate",
+				Usage:     "Check a Stack definition before creating or updating a Stack",
+				ArgsUsage: "<stack-file>",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "strict",
+						Usage: "fail on the warnings too, e.g. unknown fields",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if c.Args().Len() != 1 {
+						return cli.Exit(fmt.Sprintf("USAGE: %s %s", c.Command.HelpName, c.Command.ArgsUsage), 1)
+					}
+					err := cmd.StackValidate(c.Args().First(), c.Bool("strict"))
+					if err != nil {
+						return cli.Exit(fmt.Sprintf("%v", err), 1)
+					}
+					return nil
+				},
+			},
+			{
+				Name:      "diff",
+				Usage:     "Show the changes a Stack definition would make to a Stack, exiting with 1 when they differ",
+				ArgsUsage: "<stack> <stack-file>",
+				Action: func(c *cli.Context) error {
+					if c.Args().Len() != 2 {
+						return cli.Exit(fmt.Sprintf("USAGE: %s %s", c.Command.HelpName, c.Command.ArgsUsage), 2)
+					}
+					return exit(cmd.StackDiff(c.Args().First(), c.Args().Get(1)))
+				},
+			},
+			{
+				Name:      "export",
+				Usage:     "Export the definition of a Stack to edit and update it",
+				ArgsUsage: "<stack>",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "out",
+						Aliases: []string{"o"},
+						Usage:   "Write to file instead of the standard output (--out, as --output is the global output format flag)",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if c.Args().Len() != 1 {
+						return cli.Exit(fmt.Sprintf("USAGE: %s %s", c.Command.HelpName, c.Command.ArgsUsage), 1)
+					}
+					err := cmd.StackExport(c.Args().First(), c.String("out"))
+					if err != nil {
+						return cli.Exit(fmt.Sprintf("%v", err), 1)
+					}
+					return nil
+				},
+			},
 			{
 				Name:      "update",
-				Usage:     "Update an existing Stack",
-				ArgsUsage: "<stack-id> <stack-file>",
+				Usage:     "Update an existing Stack, creating a new version of it",
+				ArgsUsage: "<stack> <stack-file>",
 				Action: func(c *cli.Context) error {
 					err := cmd.StackUpdate(c.Args().First(), c.Args().Get(1))
 					if err != nil {
@@ -60,10 +114,25 @@ func StacksCommand() *cli.Command {
 					return nil
 				},
 			},
+			{
+				Name:      "versions",
+				Usage:     "List the versions of a Stack",
+				ArgsUsage: "<stack>",
+				Action: func(c *cli.Context) error {
+					if c.Args().Len() != 1 {
+						return cli.Exit(fmt.Sprintf("USAGE: %s %s", c.Command.HelpName, c.Command.ArgsUsage), 1)
+					}
+					err := cmd.StackVersions(c.Args().First())
+					if err != nil {
+						return cli.Exit(fmt.Sprintf("%v", err), 1)
+					}
+					return nil
+				},
+			},
 			{
 				Name:      "remove",
 				Usage:     "Delete a Stack",
-				ArgsUsage: "<stack-name>",
+				ArgsUsage: "<stack>",
 				Action: func(c *cli.Context) error {
 					err := cmd.StackRemove(c.Args().First())
 					if err != nil {
@@ -75,7 +144,7 @@ func StacksCommand() *cli.Command {
 			{
 				Name:      "publish",
 				Usage:     "Publish a Stack",
-				ArgsUsage: "<stack-id>",
+				ArgsUsage: "<stack>",
 				Action: func(c *cli.Context) error {
 					err := cmd.StackPublish(c.Args().First())
 					if err != nil {
@@ -87,7 +156,7 @@ func StacksCommand() *cli.Command {
 			{
 				Name:      "unpublish",
 				Usage:     "Unpublish a Stack",
-				ArgsUsage: "<stack-id>",
+				ArgsUsage: "<stack>",
 				Action: func(c *cli.Context) error {
 					err := cmd.StackUnPublish(c.Args().First())
 					if err != nil {
@@ -109,7 +178,11 @@ stacks:create        create a new stack
 stacks:list          list accessible stacks
 stacks:info          view info about a stack
 stacks:remove        remove an existing stack
-stacks:update        update stack
+stacks:update        update stack, creating a new version
+stacks:versions      list the versions of a stack
+stacks:validate      check a stack file
+stacks:diff          compare a stack with a stack file
+stacks:export        export a stack to a stack file
 stacks:publish       publish stack
 stacks:unpublish     unpublish stack
 
@@ -126,6 +199,14 @@ Use 'cde help [command]' to learn more.
 		return stackRemove(argv)
 	case "stacks:update":
 		return stackUpdate(argv)
+	case "stacks:versions":
+		return stackVersions(argv)
+	case "stacks:validate":
+		return stackValidate(argv)
+	case "stacks:diff":
+		return stackDiff(argv)
+	case "stacks:export":
+		return stackExport(argv)
 	case "stacks:publish":
 		return stackPublish(argv)
 	case "stacks:unpublish":
@@ -174,11 +255,11 @@ func stackInfo(argv []string) error {
 	usage := `
 View info about a stack
 
-Usage: cde stacks:info <stack-name>
+Usage: cde stacks:info <stack>
 
 Arguments:
-  <stack-name>
-    a stack name.
+  <stack>
+    the stack name or id.
 `
 	args, err := docopt.Parse(usage, argv, true, "", false, true)
 
@@ -186,7 +267,7 @@ Arguments:
 		return err
 	}
 
-	stackName := safeGetValue(args, "<stack-name>")
+	stackName := safeGetValue(args, "<stack>")
 
 	return cmd.GetStack(stackName)
 }
@@ -199,7 +280,7 @@ Usage: cde stacks:remove <stack>
 
 Arguments:
   <stack>
-    the stack name.
+    the stack name or id.
 `
 
 	args, err := docopt.Parse(usage, argv, true, "", false, true)
@@ -213,13 +294,14 @@ Arguments:
 
 func stackUpdate(argv []string) error {
 	usage := `
-Update a stack
+Update a stack, creating a new version of it. The apps pinned to a version of the
+stack keep using it, see apps:stack-update.
 
-Usage: cde stacks:update <stack-id> <stackfile>
+Usage: cde stacks:update <stack> <stackfile>
 
 Arguments:
-  <stack-id>
-    the stack id.
+  <stack>
+    the stack name or id.
   <stackfile>
     this stackfile.
 `
@@ -230,18 +312,114 @@ Arguments:
 		return err
 	}
 
-	return cmd.StackUpdate(safeGetValue(args, "<stack-id>"), safeGetValue(args, "<stackfile>"))
+	return cmd.StackUpdate(safeGetValue(args, "<stack>"), safeGetValue(args, "<stackfile>"))
+}
+
+func stackVersions(argv []string) error {
+	usage := `
+List the versions of a stack, newest first.
+
+Usage: cde stacks:versions <stack>
+
+Arguments:
+  <stack>
+    the stack name or id.
+`
+
+	args, err := docopt.Parse(usage, argv, true, "", false, true)
+
+	if err != nil {
+		return err
+	}
+
+	return cmd.StackVersions(safeGetValue(args, "<stack>"))
+}
+
+func stackValidate(argv []string) error {
+	usage := `
+Check a stack file, reporting its problems with their line. stacks:create and
+stacks:update run the same checks, unknown fields are only warned about.
+
+Usage: cde stacks:validate <stackfile> [--strict]
+
+Arguments:
+  <stackfile>
+    the stack file.
+
+Options:
+  --strict
+    fail on the warnings too, e.g. unknown fields.
+`
+
+	args, err := docopt.Parse(usage, argv, true, "", false, true)
+
+	if err != nil {
+		return err
+	}
+
+	return cmd.StackValidate(safeGetValue(args, "<stackfile>"), args["--strict"].(bool))
+}
+
+func stackDiff(argv []string) error {
+	usage := `
+Show the changes stacks:update would make to a stack from a stack file, for each
+service: images, environment, resources, health checks and volumes.
+
+Exits with 0 when the stack is up to date with the file, 1 when they differ and 2
+when they cannot be compared, e.g. for CI to check drifts.
+
+Usage: cde stacks:diff <stack> <stackfile>
+
+Arguments:
+  <stack>
+    the stack name or id.
+  <stackfile>
+    the stack file.
+`
+
+	args, err := docopt.Parse(usage, argv, true, "", false, true)
+
+	if err != nil {
+		return err
+	}
+
+	return cmd.StackDiff(safeGetValue(args, "<stack>"), safeGetValue(args, "<stackfile>"))
+}
+
+func stackExport(argv []string) error {
+	usage := `
+Export the definition of a stack, to edit it and send it back with stacks:update.
+
+Usage: cde stacks:export <stack> [-o <file>]
+
+Arguments:
+  <stack>
+    the stack name or id.
+
+Options:
+  -o <file>, --out=<file>
+    write the definition to file instead of the standard output. The long form is
+    --out, not --output, as --output is the global flag choosing the output format.
+`
+
+	args, err := docopt.Parse(usage, argv, true, "", false, true)
+
+	if err != nil {
+		return err
+	}
+
+	return cmd.StackExport(safeGetValue(args, "<stack>"), safeGetValue(args, "--out"))
 }
 
 func stackPublish(argv []string) error {
 	usage := `
 Update a stack
 
-Usage: cde stacks:publish <stack-id>
+Usage: cde stacks:publish <stack>
 
 Arguments:
-  <stack-id>
-    the stack id.
+  <stack>
+    the stack name or id.
 `
 
 	args, err := docopt.Parse(usage, argv, true, "", false, true)
@@ -250,18 +428,18 @@ Arguments:
 		return err
 	}
 
-	return cmd.StackPublish(safeGetValue(args, "<stack-id>"))
+	return cmd.StackPublish(safeGetValue(args, "<stack>"))
 }
 
 func stackUnPublish(argv []string) error {
 	usage := `
 Update a stack
 
-Usage: cde stacks:unpublish <stack-id>
+Usage: cde stacks:unpublish <stack>
 
 Arguments:
-  <stack-id>
-    the stack id.
+  <stack>
+    the stack name or id.
 `
 
 	args, err := docopt.Parse(usage, argv, true, "", false, true)
@@ -270,5 +448,5 @@ Arguments:
 		return err
 	}
 
-	return cmd.StackUnPublish(safeGetValue(args, "<stack-id>"))
+	return cmd.StackUnPublish(safeGetValue(args, "<stack>"))
 }
@@ -21,7 +21,7 @@ func UpsCommand() *cli.Command {
 			{
 				Name:      "info",
 				Usage:     "Get info of an Unified Procedure",
-				ArgsUsage: "<up-name>",
+				ArgsUsage: "<up>",
 				Action: func(c *cli.Context) error {
 					return cmd.UpsInfo(c.Args().First())
 				},
@@ -37,7 +37,7 @@ func UpsCommand() *cli.Command {
 			{
 				Name:      "update",
 				Usage:     "Update an existing Unified Procedure",
-				ArgsUsage: "<up-id> <up-file>",
+				ArgsUsage: "<up> <up-file>",
 				Action: func(c *cli.Context) error {
 					return cmd.UpUpdate(c.Args().First(), c.Args().Get(1))
 				},
@@ -45,7 +45,7 @@ func UpsCommand() *cli.Command {
 			{
 				Name:      "remove",
 				Usage:     "Delete an Unified Procedure",
-				ArgsUsage: "<up-id>",
+				ArgsUsage: "<up>",
 				Action: func(c *cli.Context) error {
 					return cmd.UpRemove(c.Args().First())
 				},
@@ -53,7 +53,7 @@ func UpsCommand() *cli.Command {
 			{
 				Name:      "publish",
 				Usage:     "Publish an Unified Procedure",
-				ArgsUsage: "<up-id>",
+				ArgsUsage: "<up>",
 				Action: func(c *cli.Context) error {
 					return cmd.UpPublish(c.Args().First())
 				},
@@ -61,7 +61,7 @@ func UpsCommand() *cli.Command {
 			{
 				Name:      "deprecate",
 				Usage:     "Deprecate an Unified Procedure",
-				ArgsUsage: "<up-id>",
+				ArgsUsage: "<up>",
 				Action: func(c *cli.Context) error {
 					return cmd.UpDeprecate(c.Args().First())
 				},
@@ -3,6 +3,9 @@ package parser
 import (
 	"fmt"
 	"os"
+
+	"github.com/cnupp/cli/cmd"
+	cli "gopkg.in/urfave/cli.v2"
 )
 
 // PrintUsage runs if no matching command is found.
@@ -39,3 +42,18 @@ func safeGetValues(args map[string]interface{}, key string) []string {
 	}
 	return args[key].([]string)
 }
+
+// exit fails with the code of an ExitError, e.g. the code of the command run in the dev
+// env, and with 1 for other errors.
+func exit(err error) error {
+	if exitErr, ok := err.(cmd.ExitError); ok {
+		if exitErr.Err == nil {
+			return cli.Exit("", exitErr.Code)
+		}
+		return cli.Exit(fmt.Sprintf("%v", exitErr.Err), exitErr.Code)
+	}
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("%v", err), 1)
+	}
+	return nil
+}
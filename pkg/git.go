@@ -173,3 +173,44 @@ func ExecuteCmd(cmdString string) error {
 	}
 	return nil
 }
+
+// HeadCommit returns the sha of the commit checked out in the current directory.
+func HeadCommit() (string, error) {
+	out, err := exec.Command("git", "rev-parse", "HEAD").Output()
+	if err != nil {
+		return "", errors.New("Could not resolve HEAD with 'git rev-parse HEAD'")
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// IsDirty tells whether the working tree has uncommitted changes, untracked files included.
+func IsDirty() (bool, error) {
+	out, err := exec.Command("git", "status", "--porcelain").Output()
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(string(out)) != "", nil
+}
+
+// WorkingTreeFiles lists the tracked and untracked files of the current repository
+// that are not excluded by .gitignore, relative to the repository root.
+func WorkingTreeFiles() (root string, files []string, err error) {
+	out, err := exec.Command("git", "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return "", nil, errors.New("Not in a git repository")
+	}
+	root = strings.TrimSpace(string(out))
+
+	cmd := exec.Command("git", "ls-files", "-z", "--cached", "--others", "--exclude-standard")
+	cmd.Dir = root
+	out, err = cmd.Output()
+	if err != nil {
+		return "", nil, err
+	}
+	for _, file := range strings.Split(string(out), "\x00") {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return root, files, nil
+}
@@ -0,0 +1,93 @@
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+// inTempDir runs the test in a new temporary directory that no repository above
+// it is found from, and returns a function restoring the working directory.
+func inTempDir(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "cde-git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ceiling := os.Getenv("GIT_CEILING_DIRECTORIES")
+	os.Setenv("GIT_CEILING_DIRECTORIES", filepath.Dir(dir))
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	return func() {
+		os.Chdir(wd)
+		os.Setenv("GIT_CEILING_DIRECTORIES", ceiling)
+		os.RemoveAll(dir)
+	}
+}
+
+func run(t *testing.T, args ...string) {
+	if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+}
+
+func TestHeadCommitAndIsDirty(t *testing.T) {
+	defer inTempDir(t)()
+
+	if IsGitDirectory() {
+		t.Fatal("expected a directory without git not to be a git directory")
+	}
+	if _, err := HeadCommit(); err == nil {
+		t.Error("expected HeadCommit to fail without git")
+	}
+	if _, err := IsDirty(); err == nil {
+		t.Error("expected IsDirty to fail without git")
+	}
+
+	run(t, "init", "-q")
+	if !IsGitDirectory() {
+		t.Fatal("expected the repository to be a git directory")
+	}
+	if _, err := HeadCommit(); err == nil {
+		t.Error("expected HeadCommit to fail before the first commit")
+	}
+
+	if err := ioutil.WriteFile("app.go", []byte("package main"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	run(t, "add", "app.go")
+	run(t, "-c", "user.name=cde", "-c", "user.email=cde@example.com", "commit", "-q", "-m", "initial")
+
+	sha, err := HeadCommit()
+	if err != nil || !regexp.MustCompile(`^[0-9a-f]{40}$`).MatchString(sha) {
+		t.Errorf("expected the sha of HEAD, got %q (%v)", sha, err)
+	}
+
+	tests := []struct {
+		name   string
+		change func() error
+		dirty  bool
+	}{
+		{"clean", func() error { return nil }, false},
+		{"untracked file", func() error { return ioutil.WriteFile("new.go", []byte("package main"), 0644) }, true},
+		{"modified file", func() error {
+			os.Remove("new.go")
+			return ioutil.WriteFile("app.go", []byte("package app"), 0644)
+		}, true},
+	}
+	for _, test := range tests {
+		if err := test.change(); err != nil {
+			t.Fatal(err)
+		}
+		if dirty, err := IsDirty(); err != nil || dirty != test.dirty {
+			t.Errorf("%s: IsDirty() = %v (%v), expected %v", test.name, dirty, err, test.dirty)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package git
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// IgnoreFile is the file listing the paths left out when cde packages a working tree.
+const IgnoreFile = ".cdeignore"
+
+type ignoreRule struct {
+	pattern  string
+	negate   bool
+	anchored bool
+	dirOnly  bool
+}
+
+// IgnoreRules holds gitignore style patterns. The last rule matching a path decides
+// whether it is ignored.
+type IgnoreRules struct {
+	rules []ignoreRule
+}
+
+// LoadIgnoreRules reads the rules of filename, a missing file ignores nothing.
+func LoadIgnoreRules(filename string) (*IgnoreRules, error) {
+	file, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return &IgnoreRules{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseIgnoreRules(file)
+}
+
+func ParseIgnoreRules(reader io.Reader) (*IgnoreRules, error) {
+	rules := &IgnoreRules{}
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule := ignoreRule{}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if strings.HasPrefix(line, "/") {
+			line = strings.TrimLeft(line, "/")
+			rule.anchored = true
+		}
+		if strings.Contains(line, "/") {
+			rule.anchored = true
+		}
+		if line == "" {
+			continue
+		}
+		rule.pattern = line
+		rules.rules = append(rules.rules, rule)
+	}
+	return rules, scanner.Err()
+}
+
+// Ignored tells whether the file at name, a slash separated path relative to the
+// root of the working tree, is excluded by the rules.
+func (r *IgnoreRules) Ignored(name string) bool {
+	ignored := false
+	for _, rule := range r.rules {
+		if rule.matches(name) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+func (rule ignoreRule) matches(name string) bool {
+	segments := strings.Split(name, "/")
+	if rule.anchored {
+		// a match on a parent directory excludes everything below it
+		for i := 1; i <= len(segments); i++ {
+			if rule.dirOnly && i == len(segments) {
+				break
+			}
+			if matched, _ := path.Match(rule.pattern, strings.Join(segments[:i], "/")); matched {
+				return true
+			}
+		}
+		return false
+	}
+
+	for i, segment := range segments {
+		if rule.dirOnly && i == len(segments)-1 {
+			break
+		}
+		if matched, _ := path.Match(rule.pattern, segment); matched {
+			return true
+		}
+	}
+	return false
+}
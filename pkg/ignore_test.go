@@ -0,0 +1,42 @@
+package git
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIgnored(t *testing.T) {
+	rules, err := ParseIgnoreRules(strings.NewReader(`
+# comments and blank lines are skipped
+
+*.log
+build/
+/docs/*.md
+!docs/keep.md
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		ignored bool
+	}{
+		{"app.log", true},
+		{"logs/app.log", true},
+		{"app.go", false},
+		{"build/app", true},
+		{"src/build/app", true},
+		{"build", false},
+		{"docs/index.md", true},
+		{"docs/keep.md", false},
+		{"src/docs/index.md", false},
+		{"docs/api/index.md", false},
+	}
+
+	for _, test := range tests {
+		if ignored := rules.Ignored(test.name); ignored != test.ignored {
+			t.Errorf("Ignored(%q) = %v, expected %v", test.name, ignored, test.ignored)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package stackfile
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Change is a value differing between two definitions, Old is empty for added values and
+// New for removed ones.
+type Change struct {
+	Field string `json:"field"`
+	Old   string `json:"old,omitempty"`
+	New   string `json:"new,omitempty"`
+}
+
+// ServiceDiff lists the changes of a service, with status added, removed or changed.
+type ServiceDiff struct {
+	Name    string   `json:"name"`
+	Status  string   `json:"status"`
+	Changes []Change `json:"changes"`
+}
+
+// Diff lists the changes between two definitions, the ones of the stack itself and the
+// ones of each of its services.
+type Diff struct {
+	Stack    []Change      `json:"stack"`
+	Services []ServiceDiff `json:"services"`
+}
+
+// Empty tells whether both definitions are the same.
+func (d Diff) Empty() bool {
+	return len(d.Stack) == 0 && len(d.Services) == 0
+}
+
+// Compare returns the changes turning definition from into to. Both definitions are
+// normalized first, so that values written differently but meaning the same, e.g. the
+// case of health check protocols, the order of links or zero values, are not reported.
+// Tags and tools are not compared, as the controller does not keep them.
+func Compare(from, to Definition) Diff {
+	diff := Diff{Stack: compareValues(stackValues(from), stackValues(to))}
+
+	names := sortedNames(from.Services)
+	for _, name := range sortedNames(to.Services) {
+		if _, ok := from.Services[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		before, inFrom := from.Services[name]
+		after, inTo := to.Services[name]
+		service := ServiceDiff{Name: name, Status: "changed"}
+		switch {
+		case !inFrom:
+			service.Status = "added"
+			service.Changes = compareValues(nil, serviceValues(name, after))
+		case !inTo:
+			service.Status = "removed"
+			service.Changes = compareValues(serviceValues(name, before), nil)
+		default:
+			service.Changes = compareValues(serviceValues(name, before), serviceValues(name, after))
+		}
+		if len(service.Changes) > 0 || service.Status != "changed" {
+			diff.Services = append(diff.Services, service)
+		}
+	}
+	return diff
+}
+
+// compareValues compares the flattened values of two definitions.
+func compareValues(from, to values) []Change {
+	var fields []string
+	for field := range from {
+		fields = append(fields, field)
+	}
+	for field := range to {
+		if _, ok := from[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	var changes []Change
+	for _, field := range fields {
+		if from[field] != to[field] {
+			changes = append(changes, Change{Field: field, Old: from[field], New: to[field]})
+		}
+	}
+	return changes
+}
+
+// values flattens a definition to its normalized values, by path, skipping zero values.
+type values map[string]string
+
+func (v values) set(path string, value string) {
+	if value != "" {
+		v[path] = value
+	}
+}
+
+func (v values) setInt(path string, value int) {
+	if value != 0 {
+		v[path] = strconv.Itoa(value)
+	}
+}
+
+func (v values) setFloat(path string, value float64) {
+	if value != 0 {
+		v[path] = strconv.FormatFloat(value, 'g', -1, 64)
+	}
+}
+
+func (v values) setImage(path string, image *Image) {
+	if image == nil {
+		return
+	}
+	v.set(path+".image", image.Image)
+	v.setInt(path+".mem", image.Mem)
+	v.setFloat(path+".cpus", image.Cpus)
+}
+
+func stackValues(definition Definition) values {
+	v := make(values)
+	v.set("name", definition.Name)
+	v.set("type", definition.Type)
+	v.set("description", definition.Description)
+	if definition.Template != nil {
+		v.set("template.type", definition.Template.Type)
+		v.set("template.uri", definition.Template.URI)
+	}
+	for i, language := range definition.Languages {
+		v.set(fmt.Sprintf("languages[%d]", i), strings.TrimSpace(language.Name+" "+language.Version))
+	}
+	for i, framework := range definition.Frameworks {
+		v.set(fmt.Sprintf("frameworks[%d]", i), strings.TrimSpace(framework.Name+" "+framework.Version))
+	}
+	return v
+}
+
+func serviceValues(name string, service Service) values {
+	v := make(values)
+	if service.Name != "" && service.Name != name {
+		v.set("name", service.Name)
+	}
+	v.set("image", service.Image)
+	v.setImage("build", service.Build)
+	v.setImage("verify", service.Verify)
+	for key, value := range service.Environment {
+		v.set("environment."+key, value)
+	}
+	links := append([]string(nil), service.Links...)
+	sort.Strings(links)
+	v.set("links", strings.Join(links, ", "))
+	v.setInt("expose", service.Expose)
+	v.setFloat("cpus", service.Cpus)
+	v.setFloat("mem", service.Mem)
+	v.setInt("instances", service.Instances)
+
+	for i, check := range service.Health {
+		path := fmt.Sprintf("health[%d]", i)
+		protocol := strings.ToUpper(check.Protocol)
+		if protocol == "" {
+			protocol = "HTTP"
+		}
+		v.set(path+".protocol", protocol)
+		v.set(path+".command", check.Command)
+		v.set(path+".path", check.Path)
+		v.setInt(path+".grace", check.Grace)
+		v.setInt(path+".timeout", check.Timeout)
+		v.setInt(path+".interval", check.Interval)
+		v.setInt(path+".port", check.Port)
+		v.setInt(path+".portIndex", check.PortIndex)
+		v.setInt(path+".maxConsecutiveFailures", check.MaxConsecutiveFailures)
+	}
+	for i, volume := range service.Volumes {
+		path := fmt.Sprintf("volumes[%d]", i)
+		v.set(path+".container", volume.Container)
+		v.set(path+".host", volume.Host)
+		v.set(path+".mode", strings.ToUpper(volume.Mode))
+	}
+	return v
+}
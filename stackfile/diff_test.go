@@ -0,0 +1,103 @@
+package stackfile
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cnupp/appssdk/api"
+)
+
+func TestFromStack(t *testing.T) {
+	stack := api.StackModel{
+		NameField:      "javajersey",
+		TypeField:      "BUILD_STACK",
+		Template:       api.Template{Type: "git", URI: "https://github.com/aisensiy/javajersey_api.git"},
+		LanguagesField: []api.Language{{Name: "java", Version: "1.8"}},
+		Services: map[string]api.ServiceDefinition{
+			"main": {Build: api.Image{Name: "hub.deepi.cn/jersey-mysql-build", Mem: 512}, Links: []string{"db"}, Env: map[string]string{"DB_DATABASE": "datastore"}},
+			"db": {
+				Image:   "mysql:5.7",
+				Exposes: 3306,
+				Health:  []api.HealthCheck{{Protocol: "TCP", Interval: 30}},
+				Volumes: []api.Volume{{ContainerPath: "/var/lib/mysql", HostPath: "data", Mode: "RW"}},
+			},
+		},
+	}
+
+	file, err := Parse([]byte(valid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := Compare(FromStack(stack), file.Definition); !diff.Empty() {
+		t.Errorf("expected the stack to match its definition, got %+v", diff)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	base := func() Definition {
+		return Definition{
+			Name: "javajersey",
+			Services: map[string]Service{
+				"main": {Build: &Image{Image: "jersey-build"}, Links: []string{"db", "cache"}},
+				"db":   {Image: "mysql:5.6", Mem: 512, Health: []HealthCheck{{Protocol: "tcp"}}},
+			},
+		}
+	}
+
+	cases := []struct {
+		change func(d *Definition)
+		diff   Diff
+	}{
+		{
+			change: func(d *Definition) {
+				d.Services["main"] = Service{Build: &Image{Image: "jersey-build"}, Links: []string{"cache", "db"}}
+				d.Services["db"] = Service{Name: "db", Image: "mysql:5.6", Mem: 512, Health: []HealthCheck{{Protocol: "TCP"}}}
+			},
+		},
+		{
+			change: func(d *Definition) {
+				d.Description = "Jersey with MySQL"
+				d.Tags = []string{"java"}
+				d.Services["db"] = Service{
+					Image:       "mysql:5.7",
+					Mem:         1024,
+					Environment: map[string]string{"MYSQL_DATABASE": "datastore"},
+					Health:      []HealthCheck{{Protocol: "TCP", Interval: 30}},
+				}
+			},
+			diff: Diff{
+				Stack: []Change{{Field: "description", New: "Jersey with MySQL"}},
+				Services: []ServiceDiff{{Name: "db", Status: "changed", Changes: []Change{
+					{Field: "environment.MYSQL_DATABASE", New: "datastore"},
+					{Field: "health[0].interval", New: "30"},
+					{Field: "image", Old: "mysql:5.6", New: "mysql:5.7"},
+					{Field: "mem", Old: "512", New: "1024"},
+				}}},
+			},
+		},
+		{
+			change: func(d *Definition) {
+				delete(d.Services, "db")
+				d.Services["cache"] = Service{Image: "redis", Cpus: 0.5}
+			},
+			diff: Diff{
+				Services: []ServiceDiff{
+					{Name: "cache", Status: "added", Changes: []Change{{Field: "cpus", New: "0.5"}, {Field: "image", New: "redis"}}},
+					{Name: "db", Status: "removed", Changes: []Change{
+						{Field: "health[0].protocol", Old: "TCP"},
+						{Field: "image", Old: "mysql:5.6"},
+						{Field: "mem", Old: "512"},
+					}},
+				},
+			},
+		},
+	}
+
+	for i, c := range cases {
+		to := base()
+		c.change(&to)
+		if diff := Compare(base(), to); !reflect.DeepEqual(diff, c.diff) {
+			t.Errorf("case %d: Compare() = %+v, want %+v", i, diff, c.diff)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package stackfile
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// node is a key or a list item of a yaml document, opened at indent.
+type node struct {
+	indent int
+	path   string
+	item   bool
+	items  int
+}
+
+var yamlKey = regexp.MustCompile(`^("[^"]*"|'[^']*'|[^\s#'"{\[][^:#]*?)\s*:(\s|$)`)
+
+// lineLocator returns a function telling the line a path of the yaml document content
+// starts at, or the line of its closest parent when the path cannot be found, e.g. for
+// values written in flow style. The document is only read as far as the block style of
+// stack definitions needs, with keys and list items told apart by their indentation.
+func lineLocator(content []byte) func(path string) int {
+	lines := make(map[string]int)
+	var stack []*node
+
+	parent := func() *node {
+		if len(stack) == 0 {
+			return &node{indent: -1}
+		}
+		return stack[len(stack)-1]
+	}
+
+	for number, raw := range strings.Split(string(content), "\n") {
+		line := strings.TrimRight(raw, " \t\r")
+		text := strings.TrimLeft(line, " ")
+		if text == "" || strings.HasPrefix(text, "#") || text == "---" {
+			continue
+		}
+		indent := len(line) - len(text)
+
+		for text == "-" || strings.HasPrefix(text, "- ") {
+			for len(stack) > 0 && (parent().indent > indent || (parent().indent == indent && parent().item)) {
+				stack = stack[:len(stack)-1]
+			}
+			list := parent()
+			item := &node{indent: indent, path: fmt.Sprintf("%s[%d]", list.path, list.items), item: true}
+			list.items++
+			lines[item.path] = number + 1
+			stack = append(stack, item)
+
+			rest := strings.TrimLeft(strings.TrimPrefix(text, "-"), " ")
+			indent += len(text) - len(rest)
+			text = rest
+		}
+
+		match := yamlKey.FindStringSubmatch(text)
+		if match == nil {
+			continue
+		}
+		for len(stack) > 0 && parent().indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+		key := node{indent: indent, path: join(parent().path, strings.Trim(match[1], `"'`))}
+		lines[key.path] = number + 1
+		stack = append(stack, &key)
+	}
+
+	return func(path string) int {
+		for path != "" {
+			if line, ok := lines[path]; ok {
+				return line
+			}
+			index := strings.LastIndexAny(path, ".[")
+			if index == -1 {
+				break
+			}
+			path = path[:index]
+		}
+		return 0
+	}
+}
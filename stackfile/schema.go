@@ -0,0 +1,168 @@
+package stackfile
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+type kind int
+
+const (
+	kindString kind = iota
+	kindInt
+	kindNumber
+	kindObject
+	kindList
+	kindMap
+)
+
+var kindNames = map[kind]string{
+	kindString: "a string",
+	kindInt:    "an integer",
+	kindNumber: "a number",
+	kindObject: "an object",
+	kindList:   "a list",
+	kindMap:    "an object",
+}
+
+// schema describes the values a stack definition accepts, for the definition to be
+// checked before it is decoded to a Definition.
+type schema struct {
+	kind     kind
+	fields   map[string]*schema
+	required []string
+	// elem is the schema of the items of a list or the values of a map.
+	elem *schema
+}
+
+var (
+	stringSchema = &schema{kind: kindString}
+	intSchema    = &schema{kind: kindInt}
+	numberSchema = &schema{kind: kindNumber}
+
+	componentSchema = &schema{kind: kindObject, required: []string{"name"}, fields: map[string]*schema{
+		"name":    stringSchema,
+		"version": stringSchema,
+	}}
+
+	imageSchema = &schema{kind: kindObject, required: []string{"image"}, fields: map[string]*schema{
+		"image": stringSchema,
+		"mem":   intSchema,
+		"cpus":  numberSchema,
+	}}
+
+	serviceSchema = &schema{kind: kindObject, fields: map[string]*schema{
+		"name":        stringSchema,
+		"image":       stringSchema,
+		"build":       imageSchema,
+		"verify":      imageSchema,
+		"environment": {kind: kindMap, elem: stringSchema},
+		"links":       {kind: kindList, elem: stringSchema},
+		"health": {kind: kindList, elem: &schema{kind: kindObject, fields: map[string]*schema{
+			"protocol":               stringSchema,
+			"command":                stringSchema,
+			"path":                   stringSchema,
+			"grace":                  intSchema,
+			"timeout":                intSchema,
+			"interval":               intSchema,
+			"port":                   intSchema,
+			"portIndex":              intSchema,
+			"maxConsecutiveFailures": intSchema,
+		}}},
+		"volumes": {kind: kindList, elem: &schema{kind: kindObject, required: []string{"container"}, fields: map[string]*schema{
+			"container": stringSchema,
+			"host":      stringSchema,
+			"mode":      stringSchema,
+		}}},
+		"expose":    intSchema,
+		"cpus":      numberSchema,
+		"mem":       numberSchema,
+		"instances": intSchema,
+	}}
+
+	stackSchema = &schema{kind: kindObject, required: []string{"name", "services"}, fields: map[string]*schema{
+		"name":        stringSchema,
+		"type":        stringSchema,
+		"description": stringSchema,
+		"template": {kind: kindObject, fields: map[string]*schema{
+			"type": stringSchema,
+			"uri":  stringSchema,
+		}},
+		"tags":       {kind: kindList, elem: stringSchema},
+		"languages":  {kind: kindList, elem: componentSchema},
+		"frameworks": {kind: kindList, elem: componentSchema},
+		"tools":      {kind: kindList, elem: componentSchema},
+		"services":   {kind: kindMap, elem: serviceSchema},
+	}}
+)
+
+// check reports the values of value, decoded from json, that do not match s. Unknown fields
+// are only warned about, releases of the controller may know fields this one does not.
+func (s *schema) check(path string, value interface{}, report, warn func(path string, format string, args ...interface{})) {
+	if value == nil {
+		return
+	}
+
+	switch s.kind {
+	case kindString:
+		if _, ok := value.(string); !ok {
+			report(path, "must be a string, quote %v", value)
+		}
+	case kindInt, kindNumber:
+		number, ok := value.(float64)
+		if !ok || (s.kind == kindInt && number != math.Trunc(number)) {
+			report(path, "must be %s, got %v", kindNames[s.kind], value)
+		}
+	case kindList:
+		items, ok := value.([]interface{})
+		if !ok {
+			report(path, "must be a list")
+			return
+		}
+		for i, item := range items {
+			s.elem.check(fmt.Sprintf("%s[%d]", path, i), item, report, warn)
+		}
+	case kindObject, kindMap:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			report(path, "must be %s", kindNames[s.kind])
+			return
+		}
+		for _, name := range s.required {
+			if object[name] == nil {
+				report(path, "%s is required", name)
+			}
+		}
+		var keys []string
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if s.kind == kindMap {
+				s.elem.check(join(path, key), object[key], report, warn)
+			} else if field, ok := s.fields[key]; ok {
+				field.check(join(path, key), object[key], report, warn)
+			} else {
+				warn(join(path, key), "unknown field")
+			}
+		}
+	}
+}
+
+func join(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func sortedNames(services map[string]Service) []string {
+	var names []string
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,76 @@
+package stackfile
+
+import (
+	"github.com/cnupp/appssdk/api"
+)
+
+// FromStack returns the definition of a stack of the controller. The controller does
+// not keep the tags and tools of stacks, they are left empty, as are the names of the
+// services named after their key, as in stack files.
+func FromStack(stack api.Stack) Definition {
+	definition := Definition{
+		Name:        stack.Name(),
+		Type:        stack.Type(),
+		Description: stack.GetDescription(),
+		Services:    make(map[string]Service),
+	}
+	if template := stack.GetTemplate(); template != (api.Template{}) {
+		definition.Template = &Template{Type: template.Type, URI: template.URI}
+	}
+	for _, language := range stack.GetLanguages() {
+		definition.Languages = append(definition.Languages, Component{Name: language.Name, Version: language.Version})
+	}
+	for _, framework := range stack.GetFrameworks() {
+		definition.Frameworks = append(definition.Frameworks, Component{Name: framework.Name, Version: framework.Version})
+	}
+
+	for name, service := range stack.GetServices() {
+		converted := fromService(service)
+		if converted.Name == name {
+			converted.Name = ""
+		}
+		definition.Services[name] = converted
+	}
+	return definition
+}
+
+func fromService(service api.Service) Service {
+	converted := Service{
+		Name:        service.GetName(),
+		Image:       service.GetImage(),
+		Build:       fromImage(service.GetBuild()),
+		Verify:      fromImage(service.GetVerify()),
+		Environment: service.GetEnv(),
+		Links:       service.GetLinks(),
+		Cpus:        service.GetCpu(),
+		Mem:         service.GetMem(),
+		Instances:   service.GetInstances(),
+	}
+	if expose := service.GetExpose(); len(expose) > 0 {
+		converted.Expose = expose[0]
+	}
+	for _, check := range service.GetHealthChecks() {
+		converted.Health = append(converted.Health, HealthCheck{
+			Protocol:               check.Protocol,
+			Command:                check.Command,
+			Path:                   check.Path,
+			Grace:                  check.Grace,
+			Timeout:                check.Timeout,
+			Interval:               check.Interval,
+			Port:                   check.Port,
+			PortIndex:              check.PortIndex,
+			MaxConsecutiveFailures: check.MaxConsecutiveFailures,
+		})
+	}
+	for _, volume := range service.GetVolumes() {
+		converted.Volumes = append(converted.Volumes, Volume{Container: volume.ContainerPath, Host: volume.HostPath, Mode: volume.Mode})
+	}
+	return converted
+}
+
+func fromImage(image api.Image) *Image {
+	if !api.NotEmptyImage(image) {
+		return nil
+	}
+	return &Image{Image: image.Name, Mem: image.Mem, Cpus: image.Cpus}
+}
@@ -0,0 +1,277 @@
+// Package stackfile reads and validates the stack definitions sent to the controller by
+// stacks:create and stacks:update, and reports their problems with the line they are at.
+// It also compares definitions and writes the definitions of existing stacks back.
+package stackfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+// Definition is the typed schema of a stack definition.
+type Definition struct {
+	Name        string             `json:"name"`
+	Type        string             `json:"type,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Template    *Template          `json:"template,omitempty"`
+	Tags        []string           `json:"tags,omitempty"`
+	Languages   []Component        `json:"languages,omitempty"`
+	Frameworks  []Component        `json:"frameworks,omitempty"`
+	Tools       []Component        `json:"tools,omitempty"`
+	Services    map[string]Service `json:"services"`
+}
+
+type Template struct {
+	Type string `json:"type,omitempty"`
+	URI  string `json:"uri,omitempty"`
+}
+
+// Component is a language, framework or tool of a stack.
+type Component struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type Service struct {
+	Name        string            `json:"name,omitempty"`
+	Image       string            `json:"image,omitempty"`
+	Build       *Image            `json:"build,omitempty"`
+	Verify      *Image            `json:"verify,omitempty"`
+	Environment map[string]string `json:"environment,omitempty"`
+	Links       []string          `json:"links,omitempty"`
+	Health      []HealthCheck     `json:"health,omitempty"`
+	Volumes     []Volume          `json:"volumes,omitempty"`
+	Expose      int               `json:"expose,omitempty"`
+	Cpus        float64           `json:"cpus,omitempty"`
+	Mem         float64           `json:"mem,omitempty"`
+	Instances   int               `json:"instances,omitempty"`
+}
+
+// Buildable tells whether the code of the app is built in the service.
+func (s Service) Buildable() bool {
+	return s.Build != nil && s.Build.Image != ""
+}
+
+// Image is the image building or verifying the code of the app.
+type Image struct {
+	Image string  `json:"image"`
+	Mem   int     `json:"mem,omitempty"`
+	Cpus  float64 `json:"cpus,omitempty"`
+}
+
+type HealthCheck struct {
+	Protocol               string `json:"protocol,omitempty"`
+	Command                string `json:"command,omitempty"`
+	Path                   string `json:"path,omitempty"`
+	Grace                  int    `json:"grace,omitempty"`
+	Timeout                int    `json:"timeout,omitempty"`
+	Interval               int    `json:"interval,omitempty"`
+	Port                   int    `json:"port,omitempty"`
+	PortIndex              int    `json:"portIndex,omitempty"`
+	MaxConsecutiveFailures int    `json:"maxConsecutiveFailures,omitempty"`
+}
+
+type Volume struct {
+	Container string `json:"container"`
+	Host      string `json:"host,omitempty"`
+	Mode      string `json:"mode,omitempty"`
+}
+
+// File is a valid stack definition.
+type File struct {
+	Definition Definition
+	// Document is the definition as it is sent to the controller.
+	Document map[string]interface{}
+	// Warnings are the problems the definition is accepted with, its unknown fields.
+	Warnings Problems
+}
+
+// Problem is an error of a stack definition.
+type Problem struct {
+	// Line is the line of the definition the problem is at, 0 when it is unknown.
+	Line int
+	// Path locates the faulty value, e.g. services.web.links[0].
+	Path    string
+	Message string
+}
+
+func (p Problem) String() string {
+	location := ""
+	if p.Line > 0 {
+		location = fmt.Sprintf("line %d: ", p.Line)
+	}
+	if p.Path == "" {
+		return location + p.Message
+	}
+	return fmt.Sprintf("%s%s: %s", location, p.Path, p.Message)
+}
+
+// Problems is the error returned for an invalid stack definition.
+type Problems []Problem
+
+func (p Problems) Error() string {
+	var lines []string
+	for _, problem := range p {
+		lines = append(lines, problem.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Read parses and validates the stack definition of filename.
+func Read(filename string) (File, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return File{}, err
+	}
+	return Parse(content)
+}
+
+var yamlErrorLine = regexp.MustCompile(`^(?:yaml|error converting YAML to JSON: yaml): line (\d+): (.*)$`)
+
+// Parse parses and validates a stack definition written in yaml or json. The returned
+// error is Problems when the definition is invalid, the warnings are returned either way.
+func Parse(content []byte) (File, error) {
+	converted, err := yaml.YAMLToJSON(content)
+	if err != nil {
+		problem := Problem{Message: err.Error()}
+		if match := yamlErrorLine.FindStringSubmatch(err.Error()); match != nil {
+			fmt.Sscanf(match[1], "%d", &problem.Line)
+			problem.Message = match[2]
+		}
+		return File{}, Problems{problem}
+	}
+
+	var document interface{}
+	if err := json.Unmarshal(converted, &document); err != nil {
+		return File{}, Problems{{Message: err.Error()}}
+	}
+
+	locate := lineLocator(content)
+	var problems, warnings Problems
+	collect := func(problems *Problems) func(path string, format string, args ...interface{}) {
+		return func(path string, format string, args ...interface{}) {
+			*problems = append(*problems, Problem{Line: locate(path), Path: path, Message: fmt.Sprintf(format, args...)})
+		}
+	}
+	report := collect(&problems)
+
+	stackSchema.check("", document, report, collect(&warnings))
+	if len(problems) > 0 {
+		return File{Warnings: warnings}, problems
+	}
+
+	file := File{Warnings: warnings}
+	file.Document = document.(map[string]interface{})
+	if err := json.Unmarshal(converted, &file.Definition); err != nil {
+		return File{Warnings: warnings}, Problems{{Message: err.Error()}}
+	}
+	validate(file.Definition, report)
+	if len(problems) > 0 {
+		return File{Warnings: warnings}, problems
+	}
+	return file, nil
+}
+
+// Marshal writes definition as a yaml stack file, which Parse reads back as definition.
+func Marshal(definition Definition) ([]byte, error) {
+	return yaml.Marshal(definition)
+}
+
+var healthProtocols = []string{"HTTP", "HTTPS", "TCP", "COMMAND", "MESOS_HTTP", "MESOS_HTTPS", "MESOS_TCP"}
+
+// validate checks the rules of definition the schema cannot tell.
+func validate(definition Definition, report func(path string, format string, args ...interface{})) {
+	if len(definition.Services) == 0 {
+		report("services", "a stack needs at least one service")
+		return
+	}
+
+	var buildable []string
+	for _, name := range sortedNames(definition.Services) {
+		service := definition.Services[name]
+		path := "services." + name
+
+		if service.Buildable() {
+			buildable = append(buildable, name)
+		} else if service.Build != nil {
+			report(path+".build.image", "is required")
+		} else if service.Image == "" {
+			report(path, "needs an image, or a build image for the service the app is built in")
+		}
+		if service.Verify != nil && service.Verify.Image == "" {
+			report(path+".verify.image", "is required")
+		}
+
+		for i, link := range service.Links {
+			if link == name {
+				report(fmt.Sprintf("%s.links[%d]", path, i), "service %s cannot link to itself", name)
+			} else if _, ok := definition.Services[link]; !ok {
+				report(fmt.Sprintf("%s.links[%d]", path, i), "link to undefined service %s", link)
+			}
+		}
+
+		if service.Expose < 0 || service.Expose > 65535 {
+			report(path+".expose", "%d is not a valid port", service.Expose)
+		}
+		if service.Instances < 0 {
+			report(path+".instances", "cannot be negative")
+		}
+
+		for i, volume := range service.Volumes {
+			volumePath := fmt.Sprintf("%s.volumes[%d]", path, i)
+			if !strings.HasPrefix(volume.Container, "/") {
+				report(volumePath+".container", "%q is not an absolute path", volume.Container)
+			}
+			if mode := strings.ToUpper(volume.Mode); mode != "" && mode != "RO" && mode != "RW" {
+				report(volumePath+".mode", "unknown mode %s, use RO or RW", volume.Mode)
+			}
+		}
+
+		for i, check := range service.Health {
+			checkPath := fmt.Sprintf("%s.health[%d]", path, i)
+			protocol := strings.ToUpper(check.Protocol)
+			if protocol == "" {
+				protocol = "HTTP"
+			}
+			if !contains(healthProtocols, protocol) {
+				report(checkPath+".protocol", "unknown protocol %s, use one of %s", check.Protocol, strings.Join(healthProtocols, ", "))
+				continue
+			}
+			if protocol == "COMMAND" {
+				if check.Command == "" {
+					report(checkPath+".command", "is required by COMMAND health checks")
+				}
+			} else if check.Port == 0 && (service.Expose == 0 || check.PortIndex > 0) {
+				report(checkPath, "no port to check, set port or expose one from the service")
+			}
+		}
+	}
+
+	if len(buildable) == 0 {
+		report("services", "missing buildable service, one service needs a build image")
+	}
+	for _, name := range buildable[min(1, len(buildable)):] {
+		report("services."+name+".build", "only one service can be buildable, %s already is", buildable[0])
+	}
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
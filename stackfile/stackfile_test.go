@@ -0,0 +1,163 @@
+package stackfile
+
+import (
+	"reflect"
+	"testing"
+)
+
+const valid = `
+name: javajersey
+type: BUILD_STACK
+template:
+  type: git
+  uri: https://github.com/aisensiy/javajersey_api.git
+languages:
+  - name: java
+    version: "1.8"
+services:
+  main:
+    build:
+      image: hub.deepi.cn/jersey-mysql-build
+      mem: 512
+    links:
+    - db
+    environment:
+      DB_DATABASE: datastore
+  db:
+    image: mysql:5.7
+    expose: 3306
+    health:
+      - protocol: TCP
+        interval: 30
+    volumes:
+      - container: /var/lib/mysql
+        host: data
+        mode: RW
+`
+
+func TestParse(t *testing.T) {
+	file, err := Parse([]byte(valid))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if main := file.Definition.Services["main"]; !main.Buildable() || !reflect.DeepEqual(main.Links, []string{"db"}) {
+		t.Errorf("unexpected main service %+v", main)
+	}
+	if file.Document["name"] != "javajersey" {
+		t.Errorf("unexpected document %v", file.Document)
+	}
+}
+
+func TestParseProblems(t *testing.T) {
+	cases := []struct {
+		content  string
+		problems Problems
+		warnings Problems
+	}{
+		{
+			content: `
+name: javajersey
+services:
+  main:
+    build:
+      image: jersey-build
+    links:
+    - db
+    - cache
+  db:
+    image: mysql
+    volumes:
+    - container: /var/lib/mysql
+      mode: rx
+`,
+			problems: Problems{
+				{Line: 14, Path: "services.db.volumes[0].mode", Message: "unknown mode rx, use RO or RW"},
+				{Line: 9, Path: "services.main.links[1]", Message: "link to undefined service cache"},
+			},
+		},
+		{
+			content: `
+name: javajersey
+services:
+  db:
+    image: mysql
+    expose: "3306"
+    imgae: mysql
+`,
+			problems: Problems{
+				{Line: 6, Path: "services.db.expose", Message: "must be an integer, got 3306"},
+			},
+			warnings: Problems{
+				{Line: 7, Path: "services.db.imgae", Message: "unknown field"},
+			},
+		},
+		{
+			content: `
+name: javajersey
+services:
+  db:
+    image: mysql
+    health:
+    - protocol: COMMAND
+`,
+			problems: Problems{
+				{Line: 7, Path: "services.db.health[0].command", Message: "is required by COMMAND health checks"},
+				{Line: 3, Path: "services", Message: "missing buildable service, one service needs a build image"},
+			},
+		},
+		{
+			content: "name: javajersey\nservices:\n  db: [\n",
+			problems: Problems{
+				{Line: 3, Message: "did not find expected node content"},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		file, err := Parse([]byte(c.content))
+		if !reflect.DeepEqual(err, c.problems) {
+			t.Errorf("Parse(%q) = %v, want %v", c.content, err, c.problems)
+		}
+		if !reflect.DeepEqual(file.Warnings, c.warnings) {
+			t.Errorf("Parse(%q) warned %v, want %v", c.content, file.Warnings, c.warnings)
+		}
+	}
+}
+
+func TestParseUnknownFields(t *testing.T) {
+	content := valid + `    replicas: 2
+owner: team
+`
+	file, err := Parse([]byte(content))
+	if err != nil {
+		t.Fatalf("expected the unknown fields not to fail, got %v", err)
+	}
+	expected := Problems{
+		{Line: 30, Path: "owner", Message: "unknown field"},
+		{Line: 29, Path: "services.db.replicas", Message: "unknown field"},
+	}
+	if !reflect.DeepEqual(file.Warnings, expected) {
+		t.Errorf("Parse() warned %v, want %v", file.Warnings, expected)
+	}
+	if file.Document["owner"] != "team" {
+		t.Errorf("expected the unknown fields to be sent as is, got %v", file.Document)
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	file, err := Parse([]byte(valid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := Marshal(file.Definition)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exported, err := Parse(content)
+	if err != nil {
+		t.Fatalf("Parse(%q) failed: %v", content, err)
+	}
+	if !reflect.DeepEqual(exported.Definition, file.Definition) {
+		t.Errorf("Marshal() = %q, read back as %+v, want %+v", content, exported.Definition, file.Definition)
+	}
+}
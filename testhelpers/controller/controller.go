@@ -0,0 +1,491 @@
+// Package controller provides an in-process fake of the cde controller. It keeps apps,
+// stacks, unified procedures, providers, clusters, deployments and auths in memory, so that the
+// commands can be exercised end to end without a live controller.
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Document is a resource as it is exchanged with the controller.
+type Document map[string]interface{}
+
+// Controller serves the apps api and, for requests sent to a host starting with
+// "launcher.", the runtime api.
+type Controller struct {
+	URL string
+
+	server   *httptest.Server
+	mutex    sync.Mutex
+	sequence int
+	clock    int64
+	pageSize int
+	status   string
+	failures int
+	failure  int
+	requests []string
+	routes   []route
+	// unversioned serves no stack versions, as the controllers predating them
+	unversioned bool
+	log         []string
+	upload      []byte
+
+	users       map[string]Document
+	passwords   map[string]string
+	auths       map[string]string
+	apps        map[string]Document
+	builds      map[string][]Document
+	releases    map[string][]Document
+	stacks      map[string]Document
+	versions    map[string][]Document
+	ups         map[string]Document
+	instances   map[string]Document
+	providers   map[string]Document
+	clusters    map[string]Document
+	deployments map[string]Document
+	events      []Document
+}
+
+type handler func(request *request) (int, interface{})
+
+type route struct {
+	runtime bool
+	method  string
+	pattern []string
+	handle  handler
+}
+
+type request struct {
+	*http.Request
+	// body is the raw body of multipart requests, the others are decoded in params
+	body   []byte
+	params Document
+	vars   map[string]string
+	user   string
+}
+
+// NewController starts a fake controller listening on a local port.
+func NewController() *Controller {
+	c := newController()
+	c.server = httptest.NewServer(c)
+	c.URL = c.server.URL
+	return c
+}
+
+// NewTLSController starts a fake controller serving https with a self-signed certificate,
+// see CertificatePEM.
+func NewTLSController() *Controller {
+	c := newController()
+	c.server = httptest.NewTLSServer(c)
+	c.URL = c.server.URL
+	return c
+}
+
+func newController() *Controller {
+	c := &Controller{
+		status:      "SUCCEED",
+		users:       make(map[string]Document),
+		passwords:   make(map[string]string),
+		auths:       make(map[string]string),
+		apps:        make(map[string]Document),
+		builds:      make(map[string][]Document),
+		releases:    make(map[string][]Document),
+		stacks:      make(map[string]Document),
+		versions:    make(map[string][]Document),
+		ups:         make(map[string]Document),
+		instances:   make(map[string]Document),
+		providers:   make(map[string]Document),
+		clusters:    make(map[string]Document),
+		deployments: make(map[string]Document),
+	}
+	c.registerRoutes()
+	return c
+}
+
+// Close shuts the controller down.
+func (c *Controller) Close() {
+	c.server.Close()
+}
+
+// CertificatePEM returns the certificate of a controller started with NewTLSController.
+func (c *Controller) CertificatePEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.server.Certificate().Raw})
+}
+
+// Transport sends every request to the controller whatever its host, which lets the
+// runtime gateway reach it through the launcher host derived from the endpoint.
+func (c *Controller) Transport() http.RoundTripper {
+	address := c.server.Listener.Addr().String()
+	return &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, address)
+		},
+	}
+}
+
+// SetPageSize has the lists come in pages of size items, rather than in a single page.
+func (c *Controller) SetPageSize(size int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.pageSize = size
+}
+
+// DisableStackVersions has the controller keep no stack versions, as the controllers
+// predating them: stacks are only updated in place.
+func (c *Controller) DisableStackVersions() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.unversioned = true
+}
+
+// SetProcedureStatus sets the status procedure instances get once created, SUCCEED
+// unless told otherwise.
+func (c *Controller) SetProcedureStatus(status string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.status = status
+}
+
+// SetLog sets the log of every build, and of the procedure instances created from then
+// on, which only have a log link once a log is set.
+func (c *Controller) SetLog(lines []string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.log = lines
+}
+
+// Upload returns the content of the file last uploaded to the launcher.
+func (c *Controller) Upload() []byte {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.upload
+}
+
+// FailNext answers the next count requests with status, as an overloaded controller would.
+func (c *Controller) FailNext(count, status int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.failures, c.failure = count, status
+}
+
+// ExpireSessions revokes every auth, as the controller does once they expired.
+func (c *Controller) ExpireSessions() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.auths = make(map[string]string)
+}
+
+// Sessions returns how many auths are valid.
+func (c *Controller) Sessions() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.auths)
+}
+
+// Requests returns the "METHOD path" of every request served so far.
+func (c *Controller) Requests() []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]string{}, c.requests...)
+}
+
+// AddUser registers a user that can log in with password and returns its id.
+func (c *Controller) AddUser(email, password string) string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.addUser(email, password)
+}
+
+// AddStack stores a stack built from definition and returns its id.
+func (c *Controller) AddStack(definition Document) string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.addStack(definition)
+}
+
+// AddUp stores a unified procedure with a procedure of each of the given types and
+// returns its id.
+func (c *Controller) AddUp(name string, procedureTypes ...string) string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var procedures []interface{}
+	for _, procedureType := range procedureTypes {
+		procedures = append(procedures, map[string]interface{}{"id": c.nextId(), "type": procedureType, "links": []interface{}{}})
+	}
+	return c.addUp(Document{"name": name, "procedures": procedures})
+}
+
+// AddProvider stores a provider and returns its id.
+func (c *Controller) AddProvider(name, providerType string) string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.addProvider(Document{"name": name, "type": providerType, "config": map[string]interface{}{}})
+}
+
+// AddCluster stores a cluster and returns its id.
+func (c *Controller) AddCluster(name, clusterType, uri string) string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.addCluster(Document{"name": name, "type": clusterType, "uri": uri})
+}
+
+// Cluster returns the stored cluster with id.
+func (c *Controller) Cluster(id string) (Document, bool) {
+	return c.find(c.clusters, id)
+}
+
+// AddRelease creates a successful release of the app with name and returns its id.
+func (c *Controller) AddRelease(app string) string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.addRelease(app)
+}
+
+// AddBuild creates a successful build of gitSha for the app with name and returns its id.
+func (c *Controller) AddBuild(app, gitSha string) string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.addBuild(app, gitSha, "")
+}
+
+// AddEvent appends an event of eventType about the app with name to the event feed and
+// returns its id.
+func (c *Controller) AddEvent(eventType, app string) string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	id := c.nextId()
+	c.events = append(c.events, Document{"id": id, "type": eventType, "content": Document{"app": app}})
+	return id
+}
+
+// App returns the stored app with name.
+func (c *Controller) App(name string) (Document, bool) {
+	return c.find(c.apps, name)
+}
+
+// Stack returns the stored stack with id.
+func (c *Controller) Stack(id string) (Document, bool) {
+	return c.find(c.stacks, id)
+}
+
+// Deployment returns the deployment of the app with name.
+func (c *Controller) Deployment(app string) (Document, bool) {
+	return c.find(c.deployments, app)
+}
+
+func (c *Controller) find(collection map[string]Document, key string) (Document, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	document, ok := collection[key]
+	return document, ok
+}
+
+func (c *Controller) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.requests = append(c.requests, r.Method+" "+r.URL.Path)
+	if c.failures > 0 {
+		c.failures--
+		writeJson(w, c.failure, Document{"message": http.StatusText(c.failure)})
+		return
+	}
+
+	runtime := strings.HasPrefix(r.Host, "launcher.")
+	segments := split(r.URL.Path)
+	for _, route := range c.routes {
+		if route.runtime != runtime || route.method != r.Method {
+			continue
+		}
+		vars, ok := match(route.pattern, segments)
+		if !ok {
+			continue
+		}
+
+		req := &request{Request: r, vars: vars}
+		if !c.authenticate(req, route) {
+			writeJson(w, http.StatusUnauthorized, Document{"message": "authentication required"})
+			return
+		}
+		if body, _ := ioutil.ReadAll(r.Body); strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+			req.body = body
+		} else if len(body) > 0 {
+			if err := json.Unmarshal(body, &req.params); err != nil {
+				writeJson(w, http.StatusBadRequest, Document{"message": err.Error()})
+				return
+			}
+		}
+
+		status, body := route.handle(req)
+		if location, ok := body.(location); ok {
+			w.Header().Set("Location", string(location))
+			w.WriteHeader(status)
+			return
+		}
+		writeJson(w, status, body)
+		return
+	}
+
+	writeJson(w, http.StatusNotFound, Document{"message": fmt.Sprintf("%s %s not found", r.Method, r.URL.Path)})
+}
+
+// authenticate resolves the user of the request, only login, registration, the controller
+// probe and the uploads of sources can be reached anonymously.
+func (c *Controller) authenticate(req *request, route route) bool {
+	if userId, ok := c.auths[req.Header.Get("Authorization")]; ok {
+		req.user = userId
+		return true
+	}
+	if route.runtime {
+		return route.method == "POST" && len(route.pattern) == 1 && route.pattern[0] == "files"
+	}
+	if len(route.pattern) == 0 {
+		return true
+	}
+	return route.method == "POST" && len(route.pattern) == 1 &&
+		(route.pattern[0] == "auths" || route.pattern[0] == "users")
+}
+
+// location is returned by handlers of created resources.
+type location string
+
+func writeJson(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if body != nil {
+		json.NewEncoder(w).Encode(body)
+	}
+}
+
+func split(path string) []string {
+	var segments []string
+	for _, segment := range strings.Split(path, "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
+
+func match(pattern, segments []string) (map[string]string, bool) {
+	if len(pattern) != len(segments) {
+		return nil, false
+	}
+	vars := make(map[string]string)
+	for i, segment := range pattern {
+		if strings.HasPrefix(segment, ":") {
+			vars[segment[1:]] = segments[i]
+		} else if segment != segments[i] {
+			return nil, false
+		}
+	}
+	return vars, true
+}
+
+func (c *Controller) handle(method, path string, handle handler) {
+	c.routes = append(c.routes, route{method: method, pattern: split(path), handle: handle})
+}
+
+func (c *Controller) handleRuntime(method, path string, handle handler) {
+	c.routes = append(c.routes, route{runtime: true, method: method, pattern: split(path), handle: handle})
+}
+
+// now returns the current time in milliseconds, later than any time it returned before,
+// so that resources created in a row are ordered by their creation time.
+func (c *Controller) now() int64 {
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	if now <= c.clock {
+		now = c.clock + 1
+	}
+	c.clock = now
+	return now
+}
+
+func (c *Controller) nextId() string {
+	c.sequence++
+	return fmt.Sprintf("%d", c.sequence)
+}
+
+func links(rels ...string) []interface{} {
+	var result []interface{}
+	for i := 0; i+1 < len(rels); i += 2 {
+		result = append(result, Document{"rel": rels[i], "uri": rels[i+1]})
+	}
+	return result
+}
+
+// page returns the page of items r asks for with its page parameter, linked to the next
+// and previous ones. The items come in a single page unless a page size is set.
+func (c *Controller) page(r *request, items []Document) Document {
+	number, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if number < 1 {
+		number = 1
+	}
+	start, end := 0, len(items)
+	if c.pageSize > 0 {
+		start = (number - 1) * c.pageSize
+		if start > len(items) {
+			start = len(items)
+		}
+		if end = start + c.pageSize; end > len(items) {
+			end = len(items)
+		}
+	}
+
+	list := make([]interface{}, 0, end-start)
+	for _, item := range items[start:end] {
+		list = append(list, item)
+	}
+	document := Document{"count": len(items), "items": list, "next": "", "prev": ""}
+	if end < len(items) {
+		document["next"] = pageURI(r, number+1)
+	}
+	if start > 0 {
+		document["prev"] = pageURI(r, number-1)
+	}
+	return document
+}
+
+// logPage answers the log requests, reading the number of lines and the offset to start
+// from in the query.
+func (c *Controller) logPage(r *request) (int, interface{}) {
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	lines, _ := strconv.Atoi(r.URL.Query().Get("lines"))
+	if offset > len(c.log) {
+		offset = len(c.log)
+	}
+	end := len(c.log)
+	if lines > 0 && offset+lines < end {
+		end = offset + lines
+	}
+	items := make([]interface{}, 0, end-offset)
+	for _, line := range c.log[offset:end] {
+		items = append(items, Document{"message": line})
+	}
+	return http.StatusOK, Document{"items": items, "total": len(c.log), "size": len(items)}
+}
+
+func pageURI(r *request, number int) string {
+	query := r.URL.Query()
+	query.Set("page", strconv.Itoa(number))
+	return r.URL.Path + "?" + query.Encode()
+}
+
+func (r *request) param(name string) string {
+	value, _ := r.params[name].(string)
+	return value
+}
+
+func notFound(kind, id string) (int, interface{}) {
+	return http.StatusNotFound, Document{"message": fmt.Sprintf("%s %s not found", kind, id)}
+}
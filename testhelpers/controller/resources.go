@@ -0,0 +1,637 @@
+package controller
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+func (c *Controller) registerRoutes() {
+	c.handle("GET", "/", func(r *request) (int, interface{}) {
+		return http.StatusOK, Document{}
+	})
+
+	c.registerAuthRoutes()
+	c.registerAppRoutes()
+	c.registerStackRoutes()
+	c.registerUpRoutes()
+	c.registerProviderRoutes()
+	c.registerClusterRoutes()
+	c.registerDeploymentRoutes()
+	c.registerEventRoutes()
+	c.registerFileRoutes()
+}
+
+func (c *Controller) registerAuthRoutes() {
+	c.handle("POST", "/users", func(r *request) (int, interface{}) {
+		for _, user := range c.users {
+			if user["email"] == r.param("email") {
+				return http.StatusConflict, Document{"message": "email already registered"}
+			}
+		}
+		id := c.addUser(r.param("email"), r.param("password"))
+		return http.StatusCreated, location("/users/" + id)
+	})
+	c.handle("GET", "/users", func(r *request) (int, interface{}) {
+		var users []Document
+		for _, user := range c.users {
+			if email := r.URL.Query().Get("email"); email == "" || user["email"] == email {
+				users = append(users, user)
+			}
+		}
+		return http.StatusOK, c.page(r, users)
+	})
+	c.handle("GET", "/users/:id", func(r *request) (int, interface{}) {
+		if user, ok := c.users[r.vars["id"]]; ok {
+			return http.StatusOK, user
+		}
+		return notFound("user", r.vars["id"])
+	})
+	c.handle("DELETE", "/users/:id", func(r *request) (int, interface{}) {
+		id := r.vars["id"]
+		if id != r.user {
+			return http.StatusForbidden, Document{"message": "only the user can cancel their account"}
+		}
+		delete(c.users, id)
+		delete(c.passwords, id)
+		for token, user := range c.auths {
+			if user == id {
+				delete(c.auths, token)
+			}
+		}
+		return http.StatusNoContent, nil
+	})
+	c.handle("PUT", "/users/:id/password", func(r *request) (int, interface{}) {
+		id := r.vars["id"]
+		if id != r.user {
+			return http.StatusForbidden, Document{"message": "only the user can change their password"}
+		}
+		if c.passwords[id] != r.param("password") {
+			return http.StatusBadRequest, Document{"message": "password is incorrect"}
+		}
+		c.passwords[id] = r.param("new_password")
+		return http.StatusNoContent, nil
+	})
+
+	c.handle("POST", "/auths", func(r *request) (int, interface{}) {
+		for id, user := range c.users {
+			if user["email"] == r.param("email") && c.passwords[id] == r.param("password") {
+				token := "token-" + c.nextId()
+				c.auths[token] = id
+				return http.StatusCreated, location("/auths/" + token)
+			}
+		}
+		return http.StatusBadRequest, Document{"message": "email or password is incorrect"}
+	})
+	c.handle("GET", "/auths", func(r *request) (int, interface{}) {
+		return http.StatusOK, c.users[r.user]
+	})
+	c.handle("DELETE", "/auths/:id", func(r *request) (int, interface{}) {
+		delete(c.auths, r.vars["id"])
+		return http.StatusNoContent, nil
+	})
+}
+
+func (c *Controller) registerAppRoutes() {
+	c.handle("POST", "/apps", func(r *request) (int, interface{}) {
+		name := r.param("name")
+		if _, ok := c.apps[name]; ok {
+			return http.StatusConflict, Document{"message": "app " + name + " already exists"}
+		}
+
+		rels := []string{"self", "/apps/" + name}
+		if stack := r.param("stackId"); stack != "" {
+			rels = append(rels, "stack", "/stacks/"+stack)
+		}
+		if up := r.param("unified_procedure_id"); up != "" {
+			rels = append(rels, "unified_procedure", "/ups/"+up)
+		}
+		if provider := r.param("provider_uri"); provider != "" {
+			rels = append(rels, "provider", provider)
+		}
+		c.apps[name] = Document{
+			"id":         c.nextId(),
+			"name":       name,
+			"needDeploy": r.params["needDeploy"],
+			"envs":       map[string]string{},
+			"links":      links(rels...),
+		}
+		return http.StatusCreated, location("/apps/" + name)
+	})
+	c.handle("GET", "/apps", func(r *request) (int, interface{}) {
+		return http.StatusOK, c.page(r, sorted(c.apps))
+	})
+	c.handle("GET", "/apps/:app", c.withApp(func(r *request, app Document) (int, interface{}) {
+		return http.StatusOK, app
+	}))
+	c.handle("DELETE", "/apps/:app", c.withApp(func(r *request, app Document) (int, interface{}) {
+		delete(c.apps, r.vars["app"])
+		return http.StatusNoContent, nil
+	}))
+	c.handle("POST", "/apps/:app/env", c.withApp(func(r *request, app Document) (int, interface{}) {
+		envs := app["envs"].(map[string]string)
+		if values, ok := r.params["envs"].(map[string]interface{}); ok {
+			for key, value := range values {
+				envs[key], _ = value.(string)
+			}
+		}
+		return http.StatusOK, nil
+	}))
+	c.handle("PUT", "/apps/:app/env", c.withApp(func(r *request, app Document) (int, interface{}) {
+		envs := app["envs"].(map[string]string)
+		if keys, ok := r.params["envs"].([]interface{}); ok {
+			for _, key := range keys {
+				delete(envs, key.(string))
+			}
+		}
+		return http.StatusOK, nil
+	}))
+	c.handle("PUT", "/apps/:app/switch-stack", c.withApp(func(r *request, app Document) (int, interface{}) {
+		var stack string
+		for id, candidate := range c.stacks {
+			if candidate["name"] == r.param("stack") {
+				stack = "/stacks/" + id
+			}
+		}
+		if stack == "" {
+			return notFound("stack", r.param("stack"))
+		}
+		if version, ok := r.params["version"]; ok {
+			stack = fmt.Sprintf("%s/versions/%v", stack, version)
+		}
+		rels := []string{"self", "/apps/" + r.vars["app"], "stack", stack}
+		for _, link := range app["links"].([]interface{}) {
+			if rel := link.(Document)["rel"]; rel != "self" && rel != "stack" {
+				rels = append(rels, rel.(string), link.(Document)["uri"].(string))
+			}
+		}
+		app["links"] = links(rels...)
+		return http.StatusOK, nil
+	}))
+	c.handle("GET", "/apps/:app/routes", c.withApp(func(r *request, app Document) (int, interface{}) {
+		return http.StatusOK, c.page(r, nil)
+	}))
+
+	c.handle("POST", "/apps/:app/builds", c.withApp(func(r *request, app Document) (int, interface{}) {
+		name := r.vars["app"]
+		id := c.addBuild(name, r.param("git_sha"), r.param("user"))
+		return http.StatusCreated, location("/apps/" + name + "/builds/" + id)
+	}))
+	c.handle("GET", "/apps/:app/builds", c.withApp(func(r *request, app Document) (int, interface{}) {
+		return http.StatusOK, c.page(r, c.builds[r.vars["app"]])
+	}))
+	c.handle("GET", "/apps/:app/builds/:id", c.withApp(func(r *request, app Document) (int, interface{}) {
+		return findById(c.builds[r.vars["app"]], "build", r.vars["id"])
+	}))
+	c.handle("GET", "/apps/:app/builds/:id/log", c.withApp(func(r *request, app Document) (int, interface{}) {
+		return c.logPage(r)
+	}))
+
+	c.handle("POST", "/apps/:app/releases", c.withApp(func(r *request, app Document) (int, interface{}) {
+		id := c.addRelease(r.vars["app"])
+		return http.StatusCreated, location("/apps/" + r.vars["app"] + "/releases/" + id)
+	}))
+	c.handle("GET", "/apps/:app/releases", c.withApp(func(r *request, app Document) (int, interface{}) {
+		return http.StatusOK, c.page(r, c.releases[r.vars["app"]])
+	}))
+	c.handle("GET", "/apps/:app/releases/:id", c.withApp(func(r *request, app Document) (int, interface{}) {
+		return findById(c.releases[r.vars["app"]], "release", r.vars["id"])
+	}))
+}
+
+func (c *Controller) withApp(handle func(r *request, app Document) (int, interface{})) handler {
+	return func(r *request) (int, interface{}) {
+		app, ok := c.apps[r.vars["app"]]
+		if !ok {
+			return notFound("app", r.vars["app"])
+		}
+		return handle(r, app)
+	}
+}
+
+func (c *Controller) registerStackRoutes() {
+	c.handle("POST", "/stacks", func(r *request) (int, interface{}) {
+		for _, stack := range c.stacks {
+			if stack["name"] == r.param("name") {
+				return http.StatusConflict, Document{"message": "stack " + r.param("name") + " already exists"}
+			}
+		}
+		return http.StatusCreated, location("/stacks/" + c.addStack(r.params))
+	})
+	c.handle("GET", "/stacks", func(r *request) (int, interface{}) {
+		var stacks []Document
+		for _, stack := range sorted(c.stacks) {
+			if name := r.URL.Query().Get("name"); name == "" || stack["name"] == name {
+				stacks = append(stacks, stack)
+			}
+		}
+		return http.StatusOK, c.page(r, stacks)
+	})
+	c.handle("GET", "/stacks/:id", c.withStack(func(r *request, stack Document) (int, interface{}) {
+		return http.StatusOK, stack
+	}))
+	c.handle("PUT", "/stacks/:id", c.withStack(func(r *request, stack Document) (int, interface{}) {
+		for key, value := range r.params {
+			stack[key] = value
+		}
+		return http.StatusOK, nil
+	}))
+	c.handle("DELETE", "/stacks/:id", c.withStack(func(r *request, stack Document) (int, interface{}) {
+		delete(c.stacks, r.vars["id"])
+		delete(c.versions, r.vars["id"])
+		return http.StatusNoContent, nil
+	}))
+	c.handle("POST", "/stacks/:id/versions", c.withVersions(func(r *request, stack Document) (int, interface{}) {
+		for key, value := range r.params {
+			stack[key] = value
+		}
+		return http.StatusCreated, location(c.addStackVersion(r.vars["id"]))
+	}))
+	c.handle("GET", "/stacks/:id/versions", c.withVersions(func(r *request, stack Document) (int, interface{}) {
+		return http.StatusOK, c.page(r, c.versions[r.vars["id"]])
+	}))
+	c.handle("GET", "/stacks/:id/versions/:version", c.withVersions(func(r *request, stack Document) (int, interface{}) {
+		versions := c.versions[r.vars["id"]]
+		number, err := strconv.Atoi(r.vars["version"])
+		if err != nil || number < 1 || number > len(versions) {
+			return notFound("stack version", r.vars["version"])
+		}
+		return http.StatusOK, versions[number-1]
+	}))
+	c.handle("PUT", "/stacks/:id/published", c.withStack(func(r *request, stack Document) (int, interface{}) {
+		stack["status"] = "PUBLISHED"
+		return http.StatusOK, nil
+	}))
+	c.handle("PUT", "/stacks/:id/unpublished", c.withStack(func(r *request, stack Document) (int, interface{}) {
+		stack["status"] = "UNPUBLISHED"
+		return http.StatusOK, nil
+	}))
+}
+
+// withVersions serves the stack version routes, unknown to the controllers that keep no
+// stack versions.
+func (c *Controller) withVersions(handle func(r *request, stack Document) (int, interface{})) handler {
+	return c.withStack(func(r *request, stack Document) (int, interface{}) {
+		if c.unversioned {
+			return notFound(r.Method, r.URL.Path)
+		}
+		return handle(r, stack)
+	})
+}
+
+func (c *Controller) withStack(handle func(r *request, stack Document) (int, interface{})) handler {
+	return func(r *request) (int, interface{}) {
+		stack, ok := c.stacks[r.vars["id"]]
+		if !ok {
+			return notFound("stack", r.vars["id"])
+		}
+		return handle(r, stack)
+	}
+}
+
+func (c *Controller) registerUpRoutes() {
+	c.handleRuntime("GET", "/ups", func(r *request) (int, interface{}) {
+		var ups []Document
+		for _, up := range sorted(c.ups) {
+			if name := r.URL.Query().Get("name"); name == "" || up["name"] == name {
+				ups = append(ups, up)
+			}
+		}
+		return http.StatusOK, c.page(r, ups)
+	})
+	c.handleRuntime("POST", "/ups", func(r *request) (int, interface{}) {
+		return http.StatusCreated, location("/ups/" + c.addUp(r.params))
+	})
+	c.handleRuntime("GET", "/ups/:id", c.withUp(func(r *request, up Document) (int, interface{}) {
+		return http.StatusOK, up
+	}))
+	c.handleRuntime("PUT", "/ups/:id", c.withUp(func(r *request, up Document) (int, interface{}) {
+		for key, value := range r.params {
+			up[key] = value
+		}
+		return http.StatusOK, nil
+	}))
+	c.handleRuntime("DELETE", "/ups/:id", c.withUp(func(r *request, up Document) (int, interface{}) {
+		delete(c.ups, r.vars["id"])
+		return http.StatusNoContent, nil
+	}))
+	c.handleRuntime("PUT", "/ups/:id/publish", c.withUp(func(r *request, up Document) (int, interface{}) {
+		up["status"] = "PUBLISHED"
+		return http.StatusOK, nil
+	}))
+	c.handleRuntime("PUT", "/ups/:id/deprecate", c.withUp(func(r *request, up Document) (int, interface{}) {
+		up["status"] = "DEPRECATED"
+		return http.StatusOK, nil
+	}))
+
+	c.handleRuntime("POST", "/ups/:id/procedures/:procedure/instances", c.withUp(func(r *request, up Document) (int, interface{}) {
+		var procedure interface{}
+		for _, candidate := range up["procedures"].([]interface{}) {
+			if candidate.(map[string]interface{})["id"] == r.vars["procedure"] {
+				procedure = candidate
+			}
+		}
+		if procedure == nil {
+			return notFound("procedure", r.vars["procedure"])
+		}
+
+		id := c.nextId()
+		owner, _ := r.params["owner"].(map[string]interface{})
+		rels := []string{"self", "/procedures/" + id}
+		if c.log != nil {
+			rels = append(rels, "log", "/procedures/"+id+"/log")
+		}
+		c.instances[id] = Document{
+			"id":        id,
+			"status":    c.status,
+			"owner":     owner,
+			"procedure": procedure,
+			"links":     links(rels...),
+		}
+
+		// a successful RUN procedure leaves the app deployed
+		if procedure.(map[string]interface{})["type"] == "RUN" && c.status == "SUCCEED" && owner != nil {
+			name, _ := owner["name"].(string)
+			app, _ := r.params["procedure"].(map[string]interface{})["app"].(map[string]interface{})
+			c.deployments[name] = Document{
+				"id":             c.nextId(),
+				"releaseVersion": app["image"],
+				"status":         "RUNNING",
+				"links":          links("self", "/deployments/"+name),
+			}
+		}
+		return http.StatusCreated, location("/procedures/" + id)
+	}))
+	c.handleRuntime("GET", "/procedures/:id", func(r *request) (int, interface{}) {
+		if instance, ok := c.instances[r.vars["id"]]; ok {
+			return http.StatusOK, instance
+		}
+		return notFound("procedure instance", r.vars["id"])
+	})
+	c.handleRuntime("GET", "/procedures/:id/log", func(r *request) (int, interface{}) {
+		if _, ok := c.instances[r.vars["id"]]; !ok {
+			return notFound("procedure instance", r.vars["id"])
+		}
+		return c.logPage(r)
+	})
+}
+
+func (c *Controller) withUp(handle func(r *request, up Document) (int, interface{})) handler {
+	return func(r *request) (int, interface{}) {
+		up, ok := c.ups[r.vars["id"]]
+		if !ok {
+			return notFound("unified procedure", r.vars["id"])
+		}
+		return handle(r, up)
+	}
+}
+
+func (c *Controller) registerProviderRoutes() {
+	c.handleRuntime("GET", "/providers", func(r *request) (int, interface{}) {
+		var providers []Document
+		for _, provider := range sorted(c.providers) {
+			if name := r.URL.Query().Get("name"); name == "" || provider["name"] == name {
+				providers = append(providers, provider)
+			}
+		}
+		return http.StatusOK, c.page(r, providers)
+	})
+	c.handleRuntime("POST", "/providers", func(r *request) (int, interface{}) {
+		return http.StatusCreated, location("/providers/" + c.addProvider(r.params))
+	})
+	c.handleRuntime("GET", "/providers/:id", func(r *request) (int, interface{}) {
+		if provider, ok := c.providers[r.vars["id"]]; ok {
+			return http.StatusOK, provider
+		}
+		return notFound("provider", r.vars["id"])
+	})
+	c.handleRuntime("PUT", "/providers/:id", func(r *request) (int, interface{}) {
+		provider, ok := c.providers[r.vars["id"]]
+		if !ok {
+			return notFound("provider", r.vars["id"])
+		}
+		config := provider["config"].(map[string]interface{})
+		for key, value := range r.params {
+			config[key] = value
+		}
+		return http.StatusOK, nil
+	})
+}
+
+func (c *Controller) registerClusterRoutes() {
+	c.handleRuntime("GET", "/clusters", func(r *request) (int, interface{}) {
+		return http.StatusOK, c.page(r, sorted(c.clusters))
+	})
+	c.handleRuntime("POST", "/clusters", func(r *request) (int, interface{}) {
+		return http.StatusCreated, location("/clusters/" + c.addCluster(r.params))
+	})
+	c.handleRuntime("GET", "/clusters/:id", c.withCluster(func(r *request, cluster Document) (int, interface{}) {
+		return http.StatusOK, cluster
+	}))
+	c.handleRuntime("PUT", "/clusters/:id", c.withCluster(func(r *request, cluster Document) (int, interface{}) {
+		for key, value := range r.params {
+			cluster[key] = value
+		}
+		return http.StatusOK, nil
+	}))
+	c.handleRuntime("DELETE", "/clusters/:id", c.withCluster(func(r *request, cluster Document) (int, interface{}) {
+		delete(c.clusters, r.vars["id"])
+		return http.StatusNoContent, nil
+	}))
+}
+
+func (c *Controller) withCluster(handle func(r *request, cluster Document) (int, interface{})) handler {
+	return func(r *request) (int, interface{}) {
+		cluster, ok := c.clusters[r.vars["id"]]
+		if !ok {
+			return notFound("cluster", r.vars["id"])
+		}
+		return handle(r, cluster)
+	}
+}
+
+func (c *Controller) registerDeploymentRoutes() {
+	c.handleRuntime("GET", "/deployments/:app", func(r *request) (int, interface{}) {
+		if deployment, ok := c.deployments[r.vars["app"]]; ok {
+			return http.StatusOK, deployment
+		}
+		return notFound("deployment", r.vars["app"])
+	})
+	c.handleRuntime("GET", "/deployments/:app/services", func(r *request) (int, interface{}) {
+		return http.StatusOK, []interface{}{}
+	})
+	c.handleRuntime("DELETE", "/deployments/:app", func(r *request) (int, interface{}) {
+		if _, ok := c.deployments[r.vars["app"]]; !ok {
+			return notFound("deployment", r.vars["app"])
+		}
+		delete(c.deployments, r.vars["app"])
+		return http.StatusNoContent, nil
+	})
+}
+
+// registerFileRoutes serves the uploads of sources to the launcher.
+func (c *Controller) registerFileRoutes() {
+	c.handleRuntime("POST", "/files", func(r *request) (int, interface{}) {
+		_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil {
+			return http.StatusBadRequest, Document{"message": err.Error()}
+		}
+		part, err := multipart.NewReader(bytes.NewReader(r.body), params["boundary"]).NextPart()
+		if err != nil {
+			return http.StatusBadRequest, Document{"message": err.Error()}
+		}
+		if c.upload, err = ioutil.ReadAll(part); err != nil {
+			return http.StatusBadRequest, Document{"message": err.Error()}
+		}
+		return http.StatusCreated, location("/files/" + c.nextId())
+	})
+}
+
+// registerEventRoutes serves the event feed, oldest first, of the type given in the query
+// or of every type.
+func (c *Controller) registerEventRoutes() {
+	c.handle("GET", "/events", func(r *request) (int, interface{}) {
+		var events []Document
+		for _, event := range c.events {
+			if eventType := r.URL.Query().Get("type"); eventType == "" || event["type"] == eventType {
+				events = append(events, event)
+			}
+		}
+		return http.StatusOK, c.page(r, events)
+	})
+}
+
+func (c *Controller) addUser(email, password string) string {
+	id := c.nextId()
+	c.users[id] = Document{"id": id, "email": email, "links": links("self", "/users/"+id)}
+	c.passwords[id] = password
+	return id
+}
+
+func (c *Controller) addStack(definition Document) string {
+	id := c.nextId()
+	stack := Document{"type": "BUILD_STACK", "status": "UNPUBLISHED"}
+	for key, value := range definition {
+		stack[key] = value
+	}
+	stack["id"] = id
+	stack["links"] = links("self", "/stacks/"+id)
+	c.stacks[id] = stack
+	c.addStackVersion(id)
+	return id
+}
+
+// addStackVersion records the current definition of the stack with id as its next
+// version and returns the uri of the version.
+func (c *Controller) addStackVersion(id string) string {
+	stack := c.stacks[id]
+	number := len(c.versions[id]) + 1
+	uri := fmt.Sprintf("/stacks/%s/versions/%d", id, number)
+	stack["version"] = number
+
+	version := Document{}
+	for key, value := range stack {
+		version[key] = value
+	}
+	version["created_at"] = time.Now().UnixNano() / int64(time.Millisecond)
+	version["links"] = links("self", uri, "stack", "/stacks/"+id)
+	c.versions[id] = append(c.versions[id], version)
+	return uri
+}
+
+func (c *Controller) addUp(definition Document) string {
+	id := c.nextId()
+	up := Document{"status": "UNPUBLISHED", "procedures": []interface{}{}}
+	for key, value := range definition {
+		up[key] = value
+	}
+	up["id"] = id
+	up["links"] = links("self", "/ups/"+id)
+	c.ups[id] = up
+	return id
+}
+
+func (c *Controller) addProvider(definition Document) string {
+	id := c.nextId()
+	provider := Document{"config": map[string]interface{}{}}
+	for key, value := range definition {
+		provider[key] = value
+	}
+	provider["id"] = id
+	provider["links"] = links("self", "/providers/"+id)
+	c.providers[id] = provider
+	return id
+}
+
+// addCluster stores a cluster, with the numeric id the runtime api gives clusters.
+func (c *Controller) addCluster(definition Document) string {
+	id := c.nextId()
+	cluster := Document{}
+	for key, value := range definition {
+		cluster[key] = value
+	}
+	cluster["id"] = c.sequence
+	c.clusters[id] = cluster
+	return id
+}
+
+// addRelease adds a new release to the releases of app, which are kept oldest first.
+func (c *Controller) addRelease(app string) string {
+	id := c.nextId()
+	c.releases[app] = append(c.releases[app], Document{
+		"id":         id,
+		"imageName":  "registry/" + app,
+		"version":    "v" + id,
+		"envs":       c.apps[app]["envs"],
+		"status":     "SUCCESS",
+		"created_at": c.now(),
+		"links":      links("self", "/apps/"+app+"/releases/"+id),
+	})
+	return id
+}
+
+// addBuild adds a new successful build to the builds of app, which are kept oldest first.
+func (c *Controller) addBuild(app, gitSha, user string) string {
+	id := c.nextId()
+	c.builds[app] = append(c.builds[app], Document{
+		"id":         id,
+		"git_sha":    gitSha,
+		"user":       user,
+		"status":     "SUCCESS",
+		"verify":     Document{},
+		"created_at": c.now(),
+		"updated_at": c.now(),
+		"links":      links("self", "/apps/"+app+"/builds/"+id),
+	})
+	return id
+}
+
+func findById(documents []Document, kind, id string) (int, interface{}) {
+	for _, document := range documents {
+		if document["id"] == id {
+			return http.StatusOK, document
+		}
+	}
+	return notFound(kind, id)
+}
+
+// sorted returns the documents of collection ordered by key, keeping listings stable.
+func sorted(collection map[string]Document) []Document {
+	var keys []string
+	for key := range collection {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	documents := make([]Document, len(keys))
+	for i, key := range keys {
+		documents[i] = collection[key]
+	}
+	return documents
+}